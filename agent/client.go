@@ -27,6 +27,7 @@ var colors = map[string]string{
 	runner.JobResultFailure:   colorRed,
 	runner.JobResultCancelled: colorGray,
 	runner.JobResultUnknown:   colorYellow,
+	runner.JobResultTimeout:   colorRed,
 }
 
 var captions = map[string]string{
@@ -34,6 +35,7 @@ var captions = map[string]string{
 	runner.JobResultFailure:   "Failure",
 	runner.JobResultCancelled: "Cancelled",
 	runner.JobResultUnknown:   "Finished(Unknown)",
+	runner.JobResultTimeout:   "Timeout",
 }
 
 func makePayload(result string, namespaceName, podName string, info *runner.JobInfo) *resultAPIPayload {
@@ -116,7 +118,7 @@ func (c *Client) PostResult(ctx context.Context, channel, result string, extend
 		return err
 	}
 
-	u, err := c.serverURL.Parse(resultAPIPath)
+	u, err := c.serverURL.Parse(ResultAPIPath)
 	if err != nil {
 		return err
 	}
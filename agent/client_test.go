@@ -99,6 +99,21 @@ func TestMakePayload(t *testing.T) {
 				Pod:   "my-namespace/my-pod",
 			},
 		},
+		{
+			title: "timeout (info=nil)",
+
+			inputResult:    "timeout",
+			inputNamespace: "my-namespace",
+			inputPod:       "my-pod",
+			inputJobInfo:   nil,
+
+			expected: &resultAPIPayload{
+				Color: colorRed,
+				Text:  "Timeout: (failed to get job status)",
+				Job:   "(unknown)",
+				Pod:   "my-namespace/my-pod",
+			},
+		},
 		{
 			title: "unexpected (info=nil)",
 
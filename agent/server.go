@@ -19,10 +19,10 @@ import (
 	"k8s.io/client-go/rest"
 )
 
-const (
-	resultAPIPath    = "/result"
-	slackPostTimeout = 3 * time.Second
-)
+// ResultAPIPath is the path of the API to post job results.
+const ResultAPIPath = "/result"
+
+const slackPostTimeout = 3 * time.Second
 
 type resultAPIPayload struct {
 	Color   string `json:"color"`
@@ -116,7 +116,7 @@ func errorResponse(w http.ResponseWriter, statusCode int, messages ...string) {
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != resultAPIPath {
+	if r.URL.Path != ResultAPIPath {
 		errorResponse(w, http.StatusNotFound)
 		return
 	}
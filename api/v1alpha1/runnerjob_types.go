@@ -0,0 +1,116 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RunnerJobSpec is the record of a job executed by a runner pod.
+type RunnerJobSpec struct {
+	// RunnerPool is the name of the RunnerPool that the runner pod belonged to.
+	RunnerPool string `json:"runnerPool"`
+
+	// PodName is the name of the runner pod that executed the job.
+	PodName string `json:"podName"`
+
+	// NodeName is the name of the node where the runner pod ran.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Result is the result of the job.
+	// +optional
+	Result string `json:"result,omitempty"`
+
+	// StartedAt is the time when the job started.
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// FinishedAt is the time when the job finished.
+	// +optional
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+
+	// JobInfo is the information of the job.
+	// +optional
+	JobInfo *RunnerJobInfo `json:"jobInfo,omitempty"`
+
+	// TTLSecondsAfterFinished is the lifetime of the RunnerJob after the job finished.
+	// The RunnerJob is deleted after this period.
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished int32 `json:"ttlSecondsAfterFinished"`
+}
+
+// RunnerJobInfo is the information of a job given by GitHub Actions.
+type RunnerJobInfo struct {
+	// Actor is the name of the user who triggered the workflow.
+	// +optional
+	Actor string `json:"actor,omitempty"`
+
+	// GitRef is the branch or tag ref that triggered the workflow.
+	// +optional
+	GitRef string `json:"gitRef,omitempty"`
+
+	// JobID is the ID of the job.
+	// +optional
+	JobID string `json:"jobID,omitempty"`
+
+	// PullRequestNumber is the number of the pull request that triggered the workflow.
+	// +optional
+	PullRequestNumber int `json:"pullRequestNumber,omitempty"`
+
+	// Repository is the owner and repository name.
+	// +optional
+	Repository string `json:"repository,omitempty"`
+
+	// RunID is the ID of the workflow run.
+	// +optional
+	RunID int `json:"runID,omitempty"`
+
+	// RunNumber is the number of the workflow run.
+	// +optional
+	RunNumber int `json:"runNumber,omitempty"`
+
+	// WorkflowName is the name of the workflow.
+	// +optional
+	WorkflowName string `json:"workflowName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="RunnerPool",type="string",JSONPath=".spec.runnerPool"
+//+kubebuilder:printcolumn:name="Repository",type="string",JSONPath=".spec.jobInfo.repository"
+//+kubebuilder:printcolumn:name="Workflow",type="string",JSONPath=".spec.jobInfo.workflowName"
+//+kubebuilder:printcolumn:name="Result",type="string",JSONPath=".spec.result"
+//+kubebuilder:printcolumn:name="Pod",type="string",JSONPath=".spec.podName",priority=1
+//+kubebuilder:printcolumn:name="Node",type="string",JSONPath=".spec.nodeName",priority=1
+//+kubebuilder:printcolumn:name="Finished",type="date",JSONPath=".spec.finishedAt"
+
+// RunnerJob is the Schema for the runnerjobs API
+type RunnerJob struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RunnerJobSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// RunnerJobList contains a list of RunnerJob
+type RunnerJobList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RunnerJob `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RunnerJob{}, &RunnerJobList{})
+}
+
+// ExpirationTime returns the time when the RunnerJob should be deleted.
+// If the finished time is not recorded, the creation time is used instead.
+func (j *RunnerJob) ExpirationTime() time.Time {
+	base := j.CreationTimestamp.Time
+	if j.Spec.FinishedAt != nil {
+		base = j.Spec.FinishedAt.Time
+	}
+	return base.Add(time.Duration(j.Spec.TTLSecondsAfterFinished) * time.Second)
+}
@@ -11,6 +11,8 @@ import (
 	constants "github.com/cybozu-go/meows"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
@@ -21,6 +23,7 @@ var reservedEnvNames = map[string]bool{
 	constants.RunnerRepoEnvName:     true,
 	constants.RunnerPoolNameEnvName: true,
 	constants.RunnerOptionEnvName:   true,
+	constants.CABundleEnvName:       true,
 }
 
 // RunnerPoolSpec defines the desired state of RunnerPool
@@ -58,11 +61,53 @@ type RunnerPoolSpec struct {
 	// +optional
 	SetupCommand []string `json:"setupCommand,omitempty"`
 
+	// SetupTimeout is the time limit for each attempt of the setup command.
+	// If this field is omitted, the setup command does not time out.
+	// +optional
+	SetupTimeout string `json:"setupTimeout,omitempty"`
+
+	// SetupRetries is the number of times the setup command is retried after it fails or times out.
+	// If all the attempts fail, the runner pod is recreated.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SetupRetries int32 `json:"setupRetries,omitempty"`
+
 	// Deadline for the Pod to be recreated.
 	// +kubebuilder:default="24h"
 	// +optional
 	RecreateDeadline string `json:"recreateDeadline,omitempty"`
 
+	// RegistrationTimeout is the time limit for a Running pod to start the runner.
+	// The pod is recreated if its runner is not in the running state or not registered in GitHub within this time.
+	// If this field is omitted, the pods are not recreated for this reason.
+	// +optional
+	RegistrationTimeout string `json:"registrationTimeout,omitempty"`
+
+	// MaxJobDuration is the time limit for a job running on a runner pod.
+	// The runner pod is deleted if its job does not finish within this time, and the job result is reported as "timeout".
+	// If this field is omitted, the jobs do not time out.
+	// +optional
+	MaxJobDuration string `json:"maxJobDuration,omitempty"`
+
+	// OfflineRunnerGracePeriod is the time to keep an offline runner that does not have a runner pod before removing it from GitHub.
+	// This prevents the runners of the pods being recreated or temporarily disconnected from being removed.
+	// If 0 is specified, such runners are removed immediately.
+	// +kubebuilder:default="5m"
+	// +optional
+	OfflineRunnerGracePeriod string `json:"offlineRunnerGracePeriod,omitempty"`
+
+	// DeletionGracePeriod is the maximum time to wait for the busy runners to finish their jobs when the RunnerPool is deleted.
+	// After this period, the runners are removed even if they are running jobs.
+	// +kubebuilder:default="1h"
+	// +optional
+	DeletionGracePeriod string `json:"deletionGracePeriod,omitempty"`
+
+	// JobHistoryTTL is the time to keep the RunnerJobs, the records of the jobs executed by the runner pods, after the jobs finished.
+	// If 0 is specified, the RunnerJobs are not created.
+	// +kubebuilder:default="24h"
+	// +optional
+	JobHistoryTTL string `json:"jobHistoryTTL,omitempty"`
+
 	// Configuration of the notification.
 	// +optional
 	Notification NotificationConfig `json:"notification,omitempty"`
@@ -74,8 +119,51 @@ type RunnerPoolSpec struct {
 	// DenyDisruption protects busy runner Pods by PDB.
 	// +optional
 	DenyDisruption bool `json:"denyDisruption,omitempty"`
+
+	// DisruptionProtection is how the busy runner pods are protected when DenyDisruption is true. Defaults to "PerPod".
+	// "PerPod" creates a PDB for each busy runner pod.
+	// "Pool" creates a single PDB for the RunnerPool, which selects the busy runner pods by a label.
+	// +kubebuilder:validation:Enum=PerPod;Pool
+	// +kubebuilder:default=PerPod
+	// +optional
+	DisruptionProtection DisruptionProtectionMode `json:"disruptionProtection,omitempty"`
+
+	// PodManagement is how the runner pods are managed. Defaults to "Deployment".
+	// "Deployment" creates a Deployment and unlinks the busy runner pods from it.
+	// "Direct" makes the controller create and delete the runner pods by itself.
+	// +kubebuilder:validation:Enum=Deployment;Direct
+	// +kubebuilder:default=Deployment
+	// +optional
+	PodManagement PodManagementPolicy `json:"podManagement,omitempty"`
+
+	// JITConfig makes the controller register each runner pod with a just-in-time configuration,
+	// instead of the registration token shared by all runner pods through a Secret.
+	// +optional
+	JITConfig bool `json:"jitConfig,omitempty"`
 }
 
+// PodManagementPolicy is the policy to manage the runner pods.
+type PodManagementPolicy string
+
+const (
+	// PodManagementDeployment manages the runner pods by a Deployment.
+	PodManagementDeployment PodManagementPolicy = "Deployment"
+
+	// PodManagementDirect manages the runner pods directly by the controller.
+	PodManagementDirect PodManagementPolicy = "Direct"
+)
+
+// DisruptionProtectionMode is the mode to protect the busy runner pods by PDBs.
+type DisruptionProtectionMode string
+
+const (
+	// DisruptionProtectionPerPod protects each busy runner pod by a dedicated PDB.
+	DisruptionProtectionPerPod DisruptionProtectionMode = "PerPod"
+
+	// DisruptionProtectionPool protects the busy runner pods by a PDB for the RunnerPool.
+	DisruptionProtectionPool DisruptionProtectionMode = "Pool"
+)
+
 type NotificationConfig struct {
 	// Configuration of the Slack notification.
 	// +optional
@@ -185,6 +273,32 @@ type RunnerPoolStatus struct {
 	// Bound is true when the child Deployment is created.
 	// +optional
 	Bound bool `json:"bound,omitempty"`
+
+	// UnlinkedPods is the number of the runner pods unlinked from the Deployment to keep busy runners.
+	// +optional
+	UnlinkedPods int32 `json:"unlinkedPods,omitempty"`
+
+	// Drain is the progress of draining the runners after the RunnerPool is deleted.
+	// +optional
+	Drain *DrainStatus `json:"drain,omitempty"`
+}
+
+// DrainStatus is the progress of draining the runners of the deleted RunnerPool.
+type DrainStatus struct {
+	// StartedAt is the time when the draining started.
+	StartedAt metav1.Time `json:"startedAt"`
+
+	// BusyRunners is the names of the runners that are running jobs.
+	// +optional
+	BusyRunners []string `json:"busyRunners,omitempty"`
+
+	// TimedOut is true if the deletion grace period passed before the busy runners finished their jobs.
+	// +optional
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	// RemovalError is the last error in removing the runners from GitHub.
+	// +optional
+	RemovalError string `json:"removalError,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -230,6 +344,11 @@ func (s *RunnerPoolSpec) validateUpdate(old RunnerPoolSpec) field.ErrorList {
 		allErrs = append(allErrs, field.Forbidden(pp, "the field is immutable"))
 	}
 
+	if s.PodManagement != old.PodManagement {
+		pp := p.Child("podManagement")
+		allErrs = append(allErrs, field.Forbidden(pp, "the field is immutable"))
+	}
+
 	return append(allErrs, s.validateCommon()...)
 }
 
@@ -251,11 +370,53 @@ func (s *RunnerPoolSpec) validateCommon() field.ErrorList {
 		allErrs = append(allErrs, field.Invalid(p.Child("maxRunnerPods"), s.MaxRunnerPods, "this value should be 0, or greater-than or equal-to replicas."))
 	}
 
+	if s.SetupTimeout != "" {
+		d, err := time.ParseDuration(s.SetupTimeout)
+		if err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("setupTimeout"), s.SetupTimeout, "this value should be a positive duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
 	_, err := time.ParseDuration(s.RecreateDeadline)
 	if err != nil {
 		allErrs = append(allErrs, field.Invalid(p.Child("recreateDeadline"), s.RecreateDeadline, "this value should be able to parse using time.ParseDuration"))
 	}
 
+	if s.RegistrationTimeout != "" {
+		d, err := time.ParseDuration(s.RegistrationTimeout)
+		if err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("registrationTimeout"), s.RegistrationTimeout, "this value should be a positive duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
+	if s.MaxJobDuration != "" {
+		d, err := time.ParseDuration(s.MaxJobDuration)
+		if err != nil || d <= 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("maxJobDuration"), s.MaxJobDuration, "this value should be a positive duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
+	if s.OfflineRunnerGracePeriod != "" {
+		d, err := time.ParseDuration(s.OfflineRunnerGracePeriod)
+		if err != nil || d < 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("offlineRunnerGracePeriod"), s.OfflineRunnerGracePeriod, "this value should be a non-negative duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
+	if s.DeletionGracePeriod != "" {
+		d, err := time.ParseDuration(s.DeletionGracePeriod)
+		if err != nil || d < 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("deletionGracePeriod"), s.DeletionGracePeriod, "this value should be a non-negative duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
+	if s.JobHistoryTTL != "" {
+		d, err := time.ParseDuration(s.JobHistoryTTL)
+		if err != nil || d < 0 {
+			allErrs = append(allErrs, field.Invalid(p.Child("jobHistoryTTL"), s.JobHistoryTTL, "this value should be a non-negative duration that can be parsed using time.ParseDuration"))
+		}
+	}
+
 	if s.Notification.ExtendDuration != "" {
 		_, err := time.ParseDuration(s.Notification.ExtendDuration)
 		if err != nil {
@@ -278,6 +439,34 @@ func (r *RunnerPool) GetRunnerDeploymentName() string {
 	return r.Name
 }
 
+// GetRunnerPodTemplateName returns the PodTemplate name for runners in the direct pod management.
+func (r *RunnerPool) GetRunnerPodTemplateName() string {
+	return r.Name
+}
+
+// IsDirectPodManagement returns true if the controller manages the runner pods directly.
+func (r *RunnerPool) IsDirectPodManagement() bool {
+	return r.Spec.PodManagement == PodManagementDirect
+}
+
+// GetBusyRunnerPDBName returns the name of the PDB protecting the busy runner pods in the pool-level protection.
+func (r *RunnerPool) GetBusyRunnerPDBName() string {
+	return r.Name + "-busy"
+}
+
+// IsPoolDisruptionProtection returns true if the busy runner pods are protected by the PDB for the RunnerPool.
+func (r *RunnerPool) IsPoolDisruptionProtection() bool {
+	return r.Spec.DenyDisruption && r.Spec.DisruptionProtection == DisruptionProtectionPool
+}
+
+// GetCredentialSecretName returns the name of the Secret that contains the GitHub credential.
+func (r *RunnerPool) GetCredentialSecretName() string {
+	if r.Spec.CredentialSecretName != "" {
+		return r.Spec.CredentialSecretName
+	}
+	return constants.DefaultCredentialSecretName
+}
+
 func (r *RunnerPool) GetRunnerSecretName() string {
 	return "runner-token-" + r.Name
 }
@@ -301,3 +490,18 @@ func (r *RunnerPool) GetRepository() string {
 	split := strings.Split(r.Spec.Repository, "/")
 	return split[1]
 }
+
+// GetRunnerLabel returns the label given to the runners of the RunnerPool.
+func (r *RunnerPool) GetRunnerLabel() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// ParseRunnerLabel parses the label given to the runners of a RunnerPool.
+// It returns false if the label is not in the form of `NAMESPACE/NAME`.
+func ParseRunnerLabel(label string) (types.NamespacedName, bool) {
+	namespace, name, ok := strings.Cut(label, "/")
+	if !ok || len(validation.IsDNS1123Label(namespace)) != 0 || len(validation.IsDNS1123Subdomain(name)) != 0 {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, true
+}
@@ -67,6 +67,11 @@ var _ = Describe("validate RunnerPool webhook with ", func() {
 		Expect(rp.Spec.Replicas).To(BeNumerically("==", 1))
 		Expect(rp.Spec.MaxRunnerPods).To(BeNumerically("==", 0))
 		Expect(rp.Spec.RecreateDeadline).To(Equal("24h"))
+		Expect(rp.Spec.OfflineRunnerGracePeriod).To(Equal("5m"))
+		Expect(rp.Spec.DeletionGracePeriod).To(Equal("1h"))
+		Expect(rp.Spec.JobHistoryTTL).To(Equal("24h"))
+		Expect(rp.Spec.PodManagement).To(Equal(PodManagementDeployment))
+		Expect(rp.Spec.DisruptionProtection).To(Equal(DisruptionProtectionPerPod))
 		Expect(rp.Spec.Template.ServiceAccountName).To(Equal("default"))
 	})
 
@@ -114,6 +119,23 @@ var _ = Describe("validate RunnerPool webhook with ", func() {
 		Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed())
 	})
 
+	It("should deny updating RunnerPool if PodManagement is changed", func() {
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.PodManagement = PodManagementDirect
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		rp.Spec.PodManagement = PodManagementDeployment
+		Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed())
+	})
+
+	It("should deny creating RunnerPool with unknown PodManagement", func() {
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.PodManagement = "ReplicaSet"
+		Expect(k8sClient.Create(ctx, rp)).NotTo(Succeed())
+	})
+
 	It("should allow creating RunnerPool when Replicas == MaxRunnerPods", func() {
 		rp := makeRunnerPoolTemplate(name, namespace)
 		rp.Spec.Repository = "test-org/test-repo"
@@ -150,6 +172,96 @@ var _ = Describe("validate RunnerPool webhook with ", func() {
 		Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed())
 	})
 
+	It("should validate RegistrationTimeout", func() {
+		By("creating RunnerPool with valid RegistrationTimeout")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.RegistrationTimeout = "30m"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid RegistrationTimeout")
+		for _, timeout := range []string{"invalid", "0s", "-1m"} {
+			rp.Spec.RegistrationTimeout = timeout
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), timeout)
+		}
+	})
+
+	It("should validate SetupTimeout and SetupRetries", func() {
+		By("creating RunnerPool with valid SetupTimeout and SetupRetries")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.SetupTimeout = "10m"
+		rp.Spec.SetupRetries = 3
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid SetupTimeout")
+		for _, timeout := range []string{"invalid", "0s", "-1m"} {
+			rp.Spec.SetupTimeout = timeout
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), timeout)
+		}
+
+		By("updating RunnerPool with invalid SetupRetries")
+		rp.Spec.SetupTimeout = "10m"
+		rp.Spec.SetupRetries = -1
+		Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed())
+	})
+
+	It("should validate MaxJobDuration", func() {
+		By("creating RunnerPool with valid MaxJobDuration")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.MaxJobDuration = "6h"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid MaxJobDuration")
+		for _, d := range []string{"invalid", "0s", "-1m"} {
+			rp.Spec.MaxJobDuration = d
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), d)
+		}
+	})
+
+	It("should validate DeletionGracePeriod", func() {
+		By("creating RunnerPool with valid DeletionGracePeriod")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.DeletionGracePeriod = "0s"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid DeletionGracePeriod")
+		for _, period := range []string{"invalid", "-1m"} {
+			rp.Spec.DeletionGracePeriod = period
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), period)
+		}
+	})
+
+	It("should validate OfflineRunnerGracePeriod", func() {
+		By("creating RunnerPool with valid OfflineRunnerGracePeriod")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.OfflineRunnerGracePeriod = "0s"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid OfflineRunnerGracePeriod")
+		for _, period := range []string{"invalid", "-1m"} {
+			rp.Spec.OfflineRunnerGracePeriod = period
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), period)
+		}
+	})
+
+	It("should validate JobHistoryTTL", func() {
+		By("creating RunnerPool with valid JobHistoryTTL")
+		rp := makeRunnerPoolTemplate(name, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.JobHistoryTTL = "0s"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("updating RunnerPool with invalid JobHistoryTTL")
+		for _, ttl := range []string{"invalid", "-1m"} {
+			rp.Spec.JobHistoryTTL = ttl
+			Expect(k8sClient.Update(ctx, rp)).NotTo(Succeed(), ttl)
+		}
+	})
+
 	It("should deny creating or updating RunnerPool with reserved environment variables", func() {
 		testCases := []string{
 			constants.PodNameEnvName,
@@ -22,6 +22,14 @@ var config struct {
 	controllerNamespace   string
 	runnerImage           string
 	runnerManagerInterval time.Duration
+	runnerGCInterval      time.Duration
+	runnerGCGracePeriod   time.Duration
+	runnerGCIncludeLegacy bool
+	runnerRemovalTimeout  time.Duration
+	githubAPIURL          string
+	githubProxyURL        string
+	githubNoProxy         string
+	githubCABundlePath    string
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -53,6 +61,14 @@ func init() {
 	fs.StringVar(&config.webhookAddr, "webhook-addr", ":9443", "The address the webhook endpoint binds to")
 	fs.StringVar(&config.runnerImage, "runner-image", defaultRunnerImage, "The image of runner container")
 	fs.DurationVar(&config.runnerManagerInterval, "runner-manager-interval", time.Minute, "Interval to watch and delete Pods.")
+	fs.DurationVar(&config.runnerGCInterval, "runner-gc-interval", 0, "Interval to remove the offline runners whose RunnerPools do not exist. 0 disables it.")
+	fs.DurationVar(&config.runnerGCGracePeriod, "runner-gc-grace-period", time.Hour, "Period to keep the offline runners whose RunnerPools do not exist before removing them.")
+	fs.BoolVar(&config.runnerGCIncludeLegacy, "runner-gc-include-legacy", false, "Remove also the orphaned runners registered without the "+constants.ManagedRunnerLabel+" label by the older versions of meows.")
+	fs.DurationVar(&config.runnerRemovalTimeout, "runner-removal-timeout", 5*time.Second, "Time limit to remove the runners from GitHub when the controller stops. It should be shorter than the termination grace period of the pod.")
+	fs.StringVar(&config.githubAPIURL, "github-api-url", "", "The base URL of GitHub REST API. The default is https://api.github.com/.")
+	fs.StringVar(&config.githubProxyURL, "github-proxy-url", "", "The URL of the HTTP proxy to access GitHub. It can be overridden by the credential secret.")
+	fs.StringVar(&config.githubNoProxy, "github-no-proxy", "", "The comma-separated list of hosts accessed without the proxy. It can be overridden by the credential secret.")
+	fs.StringVar(&config.githubCABundlePath, "github-ca-bundle", "", "The path to the PEM-encoded CA certificates trusted to access GitHub in addition to the system roots.")
 
 	goflags := flag.NewFlagSet("klog", flag.ExitOnError)
 	klog.InitFlags(goflags)
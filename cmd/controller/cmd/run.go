@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"regexp"
 	"strconv"
 
@@ -73,17 +74,28 @@ func run() error {
 	metrics.InitControllerMetrics(k8sMetrics.Registry)
 
 	log := ctrl.Log.WithName("controllers")
-	factory := github.NewFactory()
+	baseFactory, err := github.NewFactory(config.githubAPIURL)
+	if err != nil {
+		setupLog.Error(err, "unable to create GitHub client factory")
+		return err
+	}
+	// The runner manager and the secret updater share the clients for the same credential.
+	factory := github.NewCachingFactory(baseFactory)
 
 	runnerManager := controllers.NewRunnerManager(
 		log,
 		mgr.GetClient(),
 		mgr.GetScheme(),
+		mgr.GetEventRecorderFor("runner-manager"),
 		factory,
 		runner.NewClient(),
 		config.runnerManagerInterval,
 	)
-	defer runnerManager.StopAll()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.runnerRemovalTimeout)
+		defer cancel()
+		runnerManager.StopAll(ctx)
+	}()
 
 	secretUpdater := controllers.NewSecretUpdater(
 		log,
@@ -98,6 +110,12 @@ func run() error {
 		return err
 	}
 
+	defaultNetwork, err := getDefaultNetwork()
+	if err != nil {
+		setupLog.Error(err, "unable to read network configuration")
+		return err
+	}
+
 	reconciler := controllers.NewRunnerPoolReconciler(
 		log,
 		mgr.GetClient(),
@@ -107,6 +125,7 @@ func run() error {
 		secretUpdater,
 		orgRegexp,
 		repoRegexp,
+		defaultNetwork,
 	)
 
 	if err = reconciler.SetupWithManager(mgr); err != nil {
@@ -114,6 +133,30 @@ func run() error {
 		return err
 	}
 
+	if err = controllers.NewRunnerJobReconciler(log, mgr.GetClient()).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "runner-job-reconciler")
+		return err
+	}
+
+	if config.runnerGCInterval > 0 {
+		gc := controllers.NewRunnerGarbageCollector(
+			log,
+			mgr.GetClient(),
+			mgr.GetAPIReader(),
+			runnerManager,
+			factory,
+			defaultNetwork,
+			config.controllerNamespace,
+			config.runnerGCInterval,
+			config.runnerGCGracePeriod,
+			config.runnerGCIncludeLegacy,
+		)
+		if err := mgr.Add(gc); err != nil {
+			setupLog.Error(err, "unable to add runner garbage collector")
+			return err
+		}
+	}
+
 	if err = (&meowsv1alpha1.RunnerPool{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "RunnerPool")
 		return err
@@ -169,3 +212,18 @@ func getValidationRule(ctx context.Context, reader client.Reader, namespace, nam
 
 	return orgRegexp, repoRegexp, nil
 }
+
+func getDefaultNetwork() (github.NetworkConfig, error) {
+	network := github.NetworkConfig{
+		ProxyURL: config.githubProxyURL,
+		NoProxy:  config.githubNoProxy,
+	}
+	if config.githubCABundlePath != "" {
+		caBundle, err := os.ReadFile(config.githubCABundlePath)
+		if err != nil {
+			return network, fmt.Errorf("failed to read CA bundle; %w", err)
+		}
+		network.CABundle = caBundle
+	}
+	return network, network.Validate()
+}
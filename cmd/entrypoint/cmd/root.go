@@ -23,7 +23,7 @@ var rootCmd = &cobra.Command{
 	Short: "GitHub Actions runner Entrypoint",
 	Long:  "GitHub Actions runner Entrypoint",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		listener := runner.NewListener(constants.RunnerRootDirPath)
+		listener := runner.NewListener(constants.RunnerRootDirPath, constants.RunnerVarDirPath)
 		r, err := runner.NewRunner(listener, config.listenAddr, constants.RunnerRootDirPath, constants.RunnerWorkDirPath, constants.RunnerVarDirPath)
 		if err != nil {
 			return err
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 
 	constants "github.com/cybozu-go/meows"
 	"github.com/cybozu-go/meows/runner"
@@ -12,28 +14,61 @@ import (
 var (
 	jobInfoFile      string
 	slackChannelFile string
+	jobStartedFile   string
+	hook             bool
+	completed        bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:  "job-started",
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		jobInfo, err := runner.GetJobInfo()
-		if err != nil {
-			return err
+		err := recordJob()
+		if err != nil && hook {
+			// A failing hook fails the job, so only report the error.
+			fmt.Fprintf(os.Stderr, "meows: failed to record the job: %v\n", err)
+			return nil
 		}
-		data, err := json.Marshal(jobInfo)
-		if err != nil {
-			return err
-		}
-		err = os.WriteFile(jobInfoFile, data, 0664)
-		if err != nil {
-			return err
+		return err
+	},
+}
+
+func recordJob() error {
+	// The job information may have been recorded at the beginning of the job.
+	if completed {
+		if _, err := os.Stat(jobInfoFile); err == nil {
+			return nil
 		}
+	}
 
-		slackChannel := os.Getenv(constants.SlackChannelEnvName)
-		return os.WriteFile(slackChannelFile, []byte(slackChannel), 0664)
-	},
+	jobInfo, err := runner.GetJobInfo()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(jobInfo)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(jobInfoFile, data, 0664)
+	if err != nil {
+		return err
+	}
+
+	slackChannel := os.Getenv(constants.SlackChannelEnvName)
+	err = os.WriteFile(slackChannelFile, []byte(slackChannel), 0664)
+	if err != nil {
+		return err
+	}
+
+	// The time is unknown at the end of the job.
+	if completed {
+		return nil
+	}
+	// Do not overwrite the time if this command is called more than once in a job.
+	if _, err := os.Stat(jobStartedFile); err == nil {
+		return nil
+	}
+	return os.WriteFile(jobStartedFile, []byte(time.Now().UTC().Format(time.RFC3339)), 0664)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -48,4 +83,7 @@ func init() {
 	fs := rootCmd.Flags()
 	fs.StringVarP(&jobInfoFile, "jobinfo-file", "f", constants.RunnerVarDirPath+"/github.env", "Job info file.")
 	fs.StringVarP(&slackChannelFile, "slackchannel-file", "s", constants.SlackChannelFilePath, "A file that describes the Slack channel to be notified.")
+	fs.StringVar(&jobStartedFile, "job-started-file", constants.JobStartedFilePath, "A file that describes the time when the job started.")
+	fs.BoolVar(&hook, "hook", false, "Run as a job hook of the runner. Errors are reported but do not fail the job.")
+	fs.BoolVar(&completed, "completed", false, "Record the job information only if it is not recorded yet, without the time when the job started.")
 }
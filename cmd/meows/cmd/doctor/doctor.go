@@ -0,0 +1,428 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	constants "github.com/cybozu-go/meows"
+	"github.com/cybozu-go/meows/agent"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/kube"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/output"
+	"github.com/cybozu-go/meows/github"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrChecksFailed is returned when some checks failed.
+// The command exits with 2 for it, while the errors that prevent the diagnosis, such as a wrong kubeconfig, exit with 1 as the other commands.
+var ErrChecksFailed = errors.New("some checks failed")
+
+// The results of the checks.
+const (
+	resultOK      = "ok"
+	resultWarning = "warning"
+	resultFailed  = "failed"
+	resultSkipped = "skipped"
+)
+
+const defaultControllerNamespace = "meows"
+
+var config struct {
+	kube                kube.Config
+	output              output.Format
+	controllerNamespace string
+	githubAPIURL        string
+}
+
+var clients *kube.Clients
+
+// check is the result of a check.
+type check struct {
+	Name    string `json:"name"`
+	Result  string `json:"result"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor POOL",
+		Short: "diagnose the setup of a RunnerPool",
+		Long: `This command diagnoses the setup of a RunnerPool and reports actionable failures.
+
+It checks the following:
+  - the RunnerPool exists.
+  - the credential Secret has the keys of a personal access token or a GitHub App.
+  - the credential is accepted by GitHub. A registration token is created but it is not used.
+  - the organization or the repository is allowed by the rules in the meows-cm ConfigMap.
+  - the slack-agent Service is reachable, if the Slack notification is enabled.
+
+The exit code is 0 if no check failed, 2 if some checks failed,
+and 1 if the diagnosis could not be run.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			var err error
+			clients, err = config.kube.NewClients()
+			if err != nil {
+				return err
+			}
+
+			var checks []*check
+			well.Go(func(ctx context.Context) error {
+				var err error
+				checks, err = diagnose(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, checks)
+				}
+				return printChecks(os.Stdout, checks)
+			})
+
+			well.Stop()
+			if err := well.Wait(); err != nil {
+				return err
+			}
+			for _, c := range checks {
+				if c.Result == resultFailed {
+					return ErrChecksFailed
+				}
+			}
+			return nil
+		},
+	}
+
+	fs := cmd.Flags()
+	config.kube.AddFlags(fs)
+	config.output.AddFlag(fs)
+	fs.StringVar(&config.controllerNamespace, "controller-namespace", defaultControllerNamespace, "The namespace of the controller where the meows-cm ConfigMap is created.")
+	fs.StringVar(&config.githubAPIURL, "github-api-url", "", "The base URL of GitHub REST API. The default is https://api.github.com/.")
+	return cmd
+}
+
+// diagnose runs the checks in order.
+// The checks depending on a failed check are skipped.
+func diagnose(ctx context.Context, name string) ([]*check, error) {
+	rp := &meowsv1alpha1.RunnerPool{}
+	err := clients.Client.Get(ctx, types.NamespacedName{Namespace: clients.Namespace, Name: name}, rp)
+	if apierrors.IsNotFound(err) {
+		return []*check{{
+			Name:    "runnerpool",
+			Result:  resultFailed,
+			Message: fmt.Sprintf("RunnerPool %s/%s is not found", clients.Namespace, name),
+			Hint:    "Check the name and the namespace of the RunnerPool.",
+		}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RunnerPool %s/%s; %w", clients.Namespace, name, err)
+	}
+	checks := []*check{{
+		Name:    "runnerpool",
+		Result:  resultOK,
+		Message: fmt.Sprintf("RunnerPool %s/%s exists", rp.Namespace, rp.Name),
+	}}
+
+	credCheck, cred, err := checkCredentialSecret(ctx, rp)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, credCheck)
+	if cred != nil {
+		checks = append(checks, checkGitHubAccess(ctx, rp, cred))
+	} else {
+		checks = append(checks, &check{
+			Name:    "github",
+			Result:  resultSkipped,
+			Message: "the credential is not available",
+		})
+	}
+
+	ruleCheck, err := checkControllerRules(ctx, rp)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, ruleCheck)
+
+	agentCheck, err := checkSlackAgent(ctx, rp)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, agentCheck)
+	return checks, nil
+}
+
+func checkCredentialSecret(ctx context.Context, rp *meowsv1alpha1.RunnerPool) (*check, *github.ClientCredential, error) {
+	name := rp.GetCredentialSecretName()
+	c := &check{Name: "credential"}
+
+	s := &corev1.Secret{}
+	err := clients.Client.Get(ctx, types.NamespacedName{Namespace: rp.Namespace, Name: name}, s)
+	switch {
+	case apierrors.IsNotFound(err):
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("credential Secret %s is not found", name)
+		c.Hint = fmt.Sprintf("Create Secret %s in namespace %s with the %q key, or the %q, %q and %q keys.", name, rp.Namespace,
+			constants.CredentialSecretDataPATToken, constants.CredentialSecretDataAppID,
+			constants.CredentialSecretDataAppInstallationID, constants.CredentialSecretDataAppPrivateKey)
+		return c, nil, nil
+	case apierrors.IsForbidden(err):
+		c.Result = resultSkipped
+		c.Message = fmt.Sprintf("not permitted to get credential Secret %s", name)
+		return c, nil, nil
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to get credential Secret %s; %w", name, err)
+	}
+
+	cred, err := github.NewCredentialFromSecretData(s.Data)
+	if err != nil {
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("credential Secret %s is invalid: %v", name, err)
+		c.Hint = fmt.Sprintf("Set the %q key for a personal access token, or the %q, %q and %q keys for a GitHub App.",
+			constants.CredentialSecretDataPATToken, constants.CredentialSecretDataAppID,
+			constants.CredentialSecretDataAppInstallationID, constants.CredentialSecretDataAppPrivateKey)
+		return c, nil, nil
+	}
+	if err := cred.Network.Validate(); err != nil {
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("credential Secret %s has an invalid network configuration: %v", name, err)
+		c.Hint = fmt.Sprintf("Fix the %q, %q or %q key.", constants.CredentialSecretDataProxyURL,
+			constants.CredentialSecretDataNoProxy, constants.CredentialSecretDataCABundle)
+		return c, nil, nil
+	}
+
+	c.Result = resultOK
+	if cred.PersonalAccessToken != "" {
+		c.Message = fmt.Sprintf("credential Secret %s has a personal access token", name)
+		if _, ok := s.Data[constants.CredentialSecretDataAppID]; ok {
+			c.Result = resultWarning
+			c.Message += "; the keys of the GitHub App are ignored"
+			c.Hint = fmt.Sprintf("Remove the %q key to use the GitHub App.", constants.CredentialSecretDataPATToken)
+		}
+	} else {
+		c.Message = fmt.Sprintf("credential Secret %s has the keys of GitHub App %d", name, cred.AppID)
+	}
+	return c, cred, nil
+}
+
+func checkGitHubAccess(ctx context.Context, rp *meowsv1alpha1.RunnerPool, cred *github.ClientCredential) *check {
+	c := &check{Name: "github"}
+	target := rp.Spec.Repository
+	if rp.IsOrgLevel() {
+		target = rp.Spec.Organization
+	}
+
+	factory, err := github.NewFactory(config.githubAPIURL)
+	if err != nil {
+		c.Result = resultFailed
+		c.Message = err.Error()
+		c.Hint = "Fix the --github-api-url flag."
+		return c
+	}
+	githubClient, err := factory.New(cred)
+	if err != nil {
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("failed to create github client: %v", err)
+		c.Hint = fmt.Sprintf("Check the private key of the GitHub App in the %q key.", constants.CredentialSecretDataAppPrivateKey)
+		return c
+	}
+
+	// The registration token expires in an hour without being used.
+	_, err = githubClient.CreateRegistrationToken(ctx, rp.GetOwner(), rp.GetRepository())
+	switch {
+	case err == nil:
+		c.Result = resultOK
+		c.Message = fmt.Sprintf("the credential can register runners to %s", target)
+		return c
+	case errors.Is(err, github.ErrUnauthorized):
+		c.Hint = "Check that the token or the GitHub App is valid and has the permission for self-hosted runners."
+	case errors.Is(err, github.ErrNotFound):
+		c.Hint = fmt.Sprintf("Check that %s exists and the GitHub App is installed to it.", target)
+	case errors.Is(err, github.ErrRateLimited):
+		c.Hint = "Wait until the rate limit of GitHub API is reset."
+	default:
+		c.Hint = "Check the network configuration to access GitHub."
+	}
+	c.Result = resultFailed
+	c.Message = fmt.Sprintf("failed to create a registration token for %s: %v", target, err)
+	return c
+}
+
+func checkControllerRules(ctx context.Context, rp *meowsv1alpha1.RunnerPool) (*check, error) {
+	c := &check{Name: "rules"}
+	cm := &corev1.ConfigMap{}
+	err := clients.Client.Get(ctx, types.NamespacedName{Namespace: config.controllerNamespace, Name: constants.OptionConfigMapName}, cm)
+	switch {
+	case apierrors.IsNotFound(err):
+		c.Result = resultOK
+		c.Message = fmt.Sprintf("ConfigMap %s/%s is not found; any organization and repository are allowed", config.controllerNamespace, constants.OptionConfigMapName)
+		return c, nil
+	case apierrors.IsForbidden(err):
+		c.Result = resultSkipped
+		c.Message = fmt.Sprintf("not permitted to get ConfigMap %s/%s", config.controllerNamespace, constants.OptionConfigMapName)
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get ConfigMap %s/%s; %w", config.controllerNamespace, constants.OptionConfigMapName, err)
+	}
+
+	key, target := constants.OptionConfigMapDataRepositoryRule, rp.Spec.Repository
+	if rp.IsOrgLevel() {
+		key, target = constants.OptionConfigMapDataOrganizationRule, rp.Spec.Organization
+	}
+	rule := cm.Data[key]
+	if rule == "" {
+		c.Result = resultOK
+		c.Message = fmt.Sprintf("%s is not set; %s is allowed", key, target)
+		return c, nil
+	}
+	re, err := regexp.Compile(rule)
+	if err != nil {
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("%s is invalid: %v", key, err)
+		c.Hint = fmt.Sprintf("Fix the %q key of ConfigMap %s/%s and restart the controller.", key, config.controllerNamespace, constants.OptionConfigMapName)
+		return c, nil
+	}
+	if !re.MatchString(target) {
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("%s does not match %s %q", target, key, rule)
+		c.Hint = fmt.Sprintf("Ask the administrator to allow %s in ConfigMap %s/%s.", target, config.controllerNamespace, constants.OptionConfigMapName)
+		return c, nil
+	}
+	c.Result = resultOK
+	c.Message = fmt.Sprintf("%s matches %s %q", target, key, rule)
+	return c, nil
+}
+
+func checkSlackAgent(ctx context.Context, rp *meowsv1alpha1.RunnerPool) (*check, error) {
+	c := &check{Name: "slack-agent"}
+	if !rp.Spec.Notification.Slack.Enable {
+		c.Result = resultSkipped
+		c.Message = "the Slack notification is disabled"
+		return c, nil
+	}
+
+	serviceName := constants.DefaultSlackAgentServiceName
+	if rp.Spec.Notification.Slack.AgentServiceName != "" {
+		serviceName = rp.Spec.Notification.Slack.AgentServiceName
+	}
+	// The controller sends requests to slack-agent, so a name without the namespace is resolved in the namespace of the controller.
+	namespace, name, port, err := parseServiceName(serviceName, config.controllerNamespace)
+	if err != nil {
+		c.Result = resultFailed
+		c.Message = err.Error()
+		c.Hint = "Fix spec.notification.slack.agentServiceName of the RunnerPool."
+		return c, nil
+	}
+
+	svc := &corev1.Service{}
+	err = clients.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, svc)
+	switch {
+	case apierrors.IsNotFound(err):
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("Service %s/%s is not found", namespace, name)
+		c.Hint = "Deploy slack-agent, or fix spec.notification.slack.agentServiceName of the RunnerPool."
+		return c, nil
+	case apierrors.IsForbidden(err):
+		c.Result = resultSkipped
+		c.Message = fmt.Sprintf("not permitted to get Service %s/%s", namespace, name)
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get Service %s/%s; %w", namespace, name, err)
+	}
+
+	// slack-agent responds to GET requests for the result API with 405 Method Not Allowed.
+	var statusCode int
+	err = clients.Clientset.CoreV1().RESTClient().Get().
+		Namespace(namespace).
+		Resource("services").
+		Name("http:" + name + ":" + port).
+		SubResource("proxy").
+		Suffix(agent.ResultAPIPath).
+		Do(ctx).
+		StatusCode(&statusCode).
+		Error()
+	switch {
+	case statusCode == http.StatusMethodNotAllowed:
+		c.Result = resultOK
+		c.Message = fmt.Sprintf("slack-agent is reachable at %s", serviceName)
+	case apierrors.IsForbidden(err):
+		c.Result = resultWarning
+		c.Message = fmt.Sprintf("Service %s/%s exists, but not permitted to access it through the proxy", namespace, name)
+	default:
+		c.Result = resultFailed
+		c.Message = fmt.Sprintf("slack-agent is not reachable at %s: %v", serviceName, err)
+		c.Hint = "Check that the slack-agent pods are running and ready."
+	}
+	return c, nil
+}
+
+// parseServiceName parses the service name in the same way as the controller does,
+// e.g. `slack-agent.meows.svc` or `http://slack-agent.meows.svc:80`.
+func parseServiceName(serviceName, defaultNamespace string) (namespace, name, port string, err error) {
+	u, err := url.Parse(serviceName)
+	if err != nil || u.Scheme == "" {
+		u, err = url.Parse("http://" + serviceName)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid slack-agent service name %q: %w", serviceName, err)
+		}
+	}
+
+	labels := strings.Split(u.Hostname(), ".")
+	name = labels[0]
+	namespace = defaultNamespace
+	if len(labels) > 1 {
+		namespace = labels[1]
+	}
+	port = u.Port()
+	if port == "" {
+		port = "80"
+	}
+	if name == "" {
+		return "", "", "", fmt.Errorf("invalid slack-agent service name %q", serviceName)
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", "", "", fmt.Errorf("invalid port of slack-agent service name %q", serviceName)
+	}
+	return namespace, name, port, nil
+}
+
+func printChecks(w io.Writer, checks []*check) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tRESULT\tMESSAGE")
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Name, strings.ToUpper(c.Result), c.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var hints []*check
+	for _, c := range checks {
+		if c.Hint != "" {
+			hints = append(hints, c)
+		}
+	}
+	if len(hints) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Hints:")
+	for _, c := range hints {
+		fmt.Fprintf(w, "  %s: %s\n", c.Name, c.Hint)
+	}
+	return nil
+}
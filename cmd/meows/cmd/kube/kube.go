@@ -0,0 +1,208 @@
+// Package kube provides the helpers for the meows subcommands to access Kubernetes.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	constants "github.com/cybozu-go/meows"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/runner"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(meowsv1alpha1.AddToScheme(scheme))
+}
+
+// Config is the configuration to access Kubernetes.
+type Config struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+// AddFlags adds the flags for the configuration.
+func (c *Config) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.kubeconfig, "kubeconfig", "", "The path to the kubeconfig file. The default is the same as kubectl.")
+	fs.StringVar(&c.context, "context", "", "The name of the kubeconfig context to use.")
+	fs.StringVarP(&c.namespace, "namespace", "n", "", "The namespace. The default is the namespace of the kubeconfig context.")
+}
+
+// Clients are the clients to access Kubernetes.
+type Clients struct {
+	// Client is the client to access the resources including the meows custom resources.
+	Client client.Client
+	// Clientset is used to access the runner pods through the proxy of the API server.
+	Clientset kubernetes.Interface
+	// Namespace is the namespace given by the flag or the kubeconfig context.
+	Namespace string
+}
+
+// NewClients creates the clients from the configuration.
+func (c *Config) NewClients() (*Clients, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = c.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: c.context}
+	if c.namespace != "" {
+		overrides.Context.Namespace = c.namespace
+	}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace; %w", err)
+	}
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig; %w", err)
+	}
+	cl, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client; %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset; %w", err)
+	}
+	return &Clients{
+		Client:    cl,
+		Clientset: cs,
+		Namespace: namespace,
+	}, nil
+}
+
+// RunnerPodLabels returns the labels to select the runner pods.
+// If rpName is empty, the labels select the runner pods of all RunnerPools.
+func RunnerPodLabels(rpName string) client.MatchingLabels {
+	labels := client.MatchingLabels{
+		constants.AppNameLabelKey:      constants.AppName,
+		constants.AppComponentLabelKey: constants.AppComponentRunner,
+	}
+	if rpName != "" {
+		labels[constants.AppInstanceLabelKey] = rpName
+	}
+	return labels
+}
+
+// RunnerPod is a runner pod and the status of its runner.
+type RunnerPod struct {
+	Namespace  string          `json:"namespace"`
+	Name       string          `json:"name"`
+	RunnerPool string          `json:"runnerPool"`
+	Node       string          `json:"node,omitempty"`
+	Phase      corev1.PodPhase `json:"phase"`
+	Status     *runner.Status  `json:"status,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// NewRunnerPod gets the status of the runner in the pod.
+// The error in getting the status is recorded in the Error field.
+func (c *Clients) NewRunnerPod(ctx context.Context, po *corev1.Pod) *RunnerPod {
+	rpo := &RunnerPod{
+		Namespace:  po.Namespace,
+		Name:       po.Name,
+		RunnerPool: po.Labels[constants.AppInstanceLabelKey],
+		Node:       po.Spec.NodeName,
+		Phase:      po.Status.Phase,
+	}
+	// The runner does not serve the status until the pod is running.
+	if po.Status.Phase != corev1.PodRunning || po.Status.PodIP == "" {
+		return rpo
+	}
+	st, err := c.GetRunnerStatus(ctx, po)
+	if err != nil {
+		rpo.Error = err.Error()
+		return rpo
+	}
+	rpo.Status = st
+	return rpo
+}
+
+// State returns the state of the runner, or the phase of the pod if the runner is not started.
+func (p *RunnerPod) State() string {
+	switch {
+	case p.Status != nil:
+		return p.Status.State
+	case p.Error != "":
+		return "unknown"
+	default:
+		return string(p.Phase)
+	}
+}
+
+// ListRunnerPods lists the runner pods and gets the status of their runners.
+func (c *Clients) ListRunnerPods(ctx context.Context, opts ...client.ListOption) ([]*RunnerPod, error) {
+	podList := &corev1.PodList{}
+	if err := c.Client.List(ctx, podList, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list runner pods; %w", err)
+	}
+
+	rpos := make([]*RunnerPod, 0, len(podList.Items))
+	for i := range podList.Items {
+		rpos = append(rpos, c.NewRunnerPod(ctx, &podList.Items[i]))
+	}
+	return rpos, nil
+}
+
+// GetRunnerStatus gets the status of the runner pod through the proxy of the API server.
+func (c *Clients) GetRunnerStatus(ctx context.Context, po *corev1.Pod) (*runner.Status, error) {
+	data, err := c.runnerRequest(http.MethodGet, po).
+		Suffix(constants.StatusEndPoint).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the status of %s/%s; %w", po.Namespace, po.Name, err)
+	}
+
+	st := &runner.Status{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the status of %s/%s; %w", po.Namespace, po.Name, err)
+	}
+	return st, nil
+}
+
+// PutDeletionTime updates the deletion time of the runner pod through the proxy of the API server.
+func (c *Clients) PutDeletionTime(ctx context.Context, po *corev1.Pod, tm time.Time) error {
+	body, err := json.Marshal(runner.DeletionTimePayload{
+		DeletionTime: tm,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.runnerRequest(http.MethodPut, po).
+		Suffix(constants.DeletionTimeEndpoint).
+		SetHeader("Content-Type", "application/json").
+		Body(bytes.NewReader(body)).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return fmt.Errorf("failed to update the deletion time of %s/%s; %w", po.Namespace, po.Name, err)
+	}
+	return nil
+}
+
+// runnerRequest returns a request to the runner pod through the proxy of the API server.
+func (c *Clients) runnerRequest(verb string, po *corev1.Pod) *rest.Request {
+	return c.Clientset.CoreV1().RESTClient().Verb(verb).
+		Namespace(po.Namespace).
+		Resource("pods").
+		Name(po.Name + ":" + strconv.Itoa(constants.RunnerListenPort)).
+		SubResource("proxy")
+}
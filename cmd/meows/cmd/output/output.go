@@ -0,0 +1,93 @@
+// Package output provides the helpers for the meows subcommands to print the results.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// The output formats.
+const (
+	Table = "table"
+	JSON  = "json"
+	YAML  = "yaml"
+)
+
+// Format is the output format given by the flag.
+type Format string
+
+// AddFlag adds the flag for the output format.
+func (f *Format) AddFlag(fs *pflag.FlagSet) {
+	*f = Table
+	fs.VarP(f, "output", "o", "Output format. One of: table, json, yaml.")
+}
+
+func (f *Format) String() string {
+	return string(*f)
+}
+
+func (f *Format) Set(v string) error {
+	if v != Table && v != JSON && v != YAML {
+		return fmt.Errorf("unsupported output format: %s", v)
+	}
+	*f = Format(v)
+	return nil
+}
+
+func (f *Format) Type() string {
+	return "string"
+}
+
+// IsTable returns true if the output format is table.
+func (f Format) IsTable() bool {
+	return f == Table
+}
+
+// Print prints v in JSON or YAML format.
+func (f Format) Print(w io.Writer, v interface{}) error {
+	if f == YAML {
+		return PrintYAML(w, v)
+	}
+	return PrintJSON(w, v)
+}
+
+// PrintJSON prints v as indented JSON.
+func PrintJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal; %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// FormatTime formats the time in RFC 3339 format, or returns "-" if it is nil.
+func FormatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// OrDash returns "-" if s is empty.
+func OrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// PrintYAML prints v as YAML.
+func PrintYAML(w io.Writer, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal; %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
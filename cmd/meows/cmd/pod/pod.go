@@ -0,0 +1,292 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	constants "github.com/cybozu-go/meows"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/kube"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/output"
+	"github.com/cybozu-go/meows/runner"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultExtendDuration is the same as the extension from Slack.
+const defaultExtendDuration = "2h"
+
+var config struct {
+	kube          kube.Config
+	output        output.Format
+	runnerPool    string
+	allNamespaces bool
+}
+
+var clients *kube.Clients
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pod",
+		Short: "inspect, extend and delete runner pods",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			var err error
+			clients, err = config.kube.NewClients()
+			return err
+		},
+	}
+	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newStatusCmd())
+	cmd.AddCommand(newExtendCmd())
+	cmd.AddCommand(newDeleteCmd())
+
+	fs := cmd.PersistentFlags()
+	config.kube.AddFlags(fs)
+	config.output.AddFlag(fs)
+	return cmd
+}
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list runner pods",
+		Long:  "This command lists runner pods with the status of their runners.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			well.Go(func(ctx context.Context) error {
+				opts := []client.ListOption{kube.RunnerPodLabels(config.runnerPool)}
+				if !config.allNamespaces {
+					opts = append(opts, client.InNamespace(clients.Namespace))
+				}
+				rpos, err := clients.ListRunnerPods(ctx, opts...)
+				if err != nil {
+					return err
+				}
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, rpos)
+				}
+				return printPodTable(os.Stdout, rpos)
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVarP(&config.runnerPool, "runnerpool", "p", "", "List only the runner pods of the RunnerPool.")
+	fs.BoolVarP(&config.allNamespaces, "all-namespaces", "A", false, "List the runner pods in all namespaces.")
+	return cmd
+}
+
+func newStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status POD",
+		Short: "show the status of a runner pod",
+		Long:  "This command shows the state, the job result, the job information and the deletion time of a runner pod.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			well.Go(func(ctx context.Context) error {
+				po, err := getRunnerPod(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				rpo := clients.NewRunnerPod(ctx, po)
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, rpo)
+				}
+				return printPodDetail(os.Stdout, rpo)
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+	return cmd
+}
+
+func newExtendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extend POD [DURATION | TIME]",
+		Short: "extend a debugging runner pod",
+		Long: `This command extends a debugging runner pod by updating its deletion time.
+
+Specify a duration from now (e.g. 3h) or a time in RFC 3339 format.
+If it is omitted, the pod is extended for ` + defaultExtendDuration + ` from now.
+The extension is limited to .spec.notification.extendDuration of the RunnerPool from now.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			until := defaultExtendDuration
+			if len(args) > 1 {
+				until = args[1]
+			}
+
+			well.Go(func(ctx context.Context) error {
+				po, err := getDebuggingRunnerPod(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				extendDuration, err := getExtendDuration(ctx, po)
+				if err != nil {
+					return err
+				}
+				tm, err := runner.ParseExtendUntil(until, extendDuration, time.Now().UTC())
+				if err != nil {
+					return err
+				}
+				if err := clients.PutDeletionTime(ctx, po, tm); err != nil {
+					return err
+				}
+				fmt.Printf("extended %s/%s until %s\n", po.Namespace, po.Name, tm.Format(time.RFC3339))
+				return nil
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+	return cmd
+}
+
+func newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete POD",
+		Short: "delete a debugging runner pod",
+		Long: `This command makes the controller delete a debugging runner pod immediately.
+This is the same as the "Delete immediately" button of the Slack notification.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			well.Go(func(ctx context.Context) error {
+				po, err := getDebuggingRunnerPod(ctx, args[0])
+				if err != nil {
+					return err
+				}
+				// The zero time makes the controller delete the pod in the next check.
+				if err := clients.PutDeletionTime(ctx, po, time.Time{}); err != nil {
+					return err
+				}
+				fmt.Printf("%s/%s will be deleted\n", po.Namespace, po.Name)
+				return nil
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+	return cmd
+}
+
+func getRunnerPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	po := &corev1.Pod{}
+	if err := clients.Client.Get(ctx, types.NamespacedName{Namespace: clients.Namespace, Name: name}, po); err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s; %w", clients.Namespace, name, err)
+	}
+	if po.Labels[constants.AppComponentLabelKey] != constants.AppComponentRunner {
+		return nil, fmt.Errorf("%s/%s is not a runner pod", po.Namespace, po.Name)
+	}
+	return po, nil
+}
+
+// getDebuggingRunnerPod gets the runner pod, and returns an error if its runner is not in the debugging state.
+// The deletion time is used by the controller only in the debugging state.
+func getDebuggingRunnerPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	po, err := getRunnerPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("%s/%s is not running", po.Namespace, po.Name)
+	}
+	st, err := clients.GetRunnerStatus(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	if st.State != constants.RunnerPodStateDebugging {
+		return nil, fmt.Errorf("%s/%s is not debugging; state: %s", po.Namespace, po.Name, st.State)
+	}
+	return po, nil
+}
+
+// getExtendDuration returns the maximum duration to extend the runner pod, which is given by its RunnerPool.
+func getExtendDuration(ctx context.Context, po *corev1.Pod) (time.Duration, error) {
+	rp := &meowsv1alpha1.RunnerPool{}
+	rpName := po.Labels[constants.AppInstanceLabelKey]
+	if err := clients.Client.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: rpName}, rp); err != nil {
+		return 0, fmt.Errorf("failed to get RunnerPool %s/%s; %w", po.Namespace, rpName, err)
+	}
+	extendDuration, _ := time.ParseDuration(rp.Spec.Notification.ExtendDuration)
+	return extendDuration, nil
+}
+
+func printPodTable(w io.Writer, rpos []*kube.RunnerPod) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tRUNNERPOOL\tNODE\tSTATE\tRESULT\tDELETION TIME\tREPOSITORY\tWORKFLOW")
+	for _, i := range rpos {
+		result, deletionTime, repository, workflow := "-", "-", "-", "-"
+		if st := i.Status; st != nil {
+			if st.Result != "" {
+				result = st.Result
+			}
+			if st.DeletionTime != nil {
+				deletionTime = output.FormatTime(st.DeletionTime)
+			}
+			if st.JobInfo != nil {
+				repository = st.JobInfo.Repository
+				workflow = st.JobInfo.WorkflowName
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			i.Namespace, i.Name, i.RunnerPool, output.OrDash(i.Node), i.State(), result, deletionTime, repository, workflow)
+	}
+	return tw.Flush()
+}
+
+func printPodDetail(w io.Writer, rpo *kube.RunnerPod) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "Pod:\t%s/%s\n", rpo.Namespace, rpo.Name)
+	fmt.Fprintf(tw, "RunnerPool:\t%s\n", rpo.RunnerPool)
+	fmt.Fprintf(tw, "Node:\t%s\n", output.OrDash(rpo.Node))
+	fmt.Fprintf(tw, "Phase:\t%s\n", rpo.Phase)
+	fmt.Fprintf(tw, "State:\t%s\n", rpo.State())
+	if rpo.Error != "" {
+		fmt.Fprintf(tw, "Error:\t%s\n", rpo.Error)
+	}
+	if st := rpo.Status; st != nil {
+		fmt.Fprintf(tw, "Result:\t%s\n", output.OrDash(st.Result))
+		fmt.Fprintf(tw, "Job Started At:\t%s\n", output.FormatTime(st.JobStartedAt))
+		fmt.Fprintf(tw, "Finished At:\t%s\n", output.FormatTime(st.FinishedAt))
+		fmt.Fprintf(tw, "Deletion Time:\t%s\n", output.FormatTime(st.DeletionTime))
+		if st.Extend != nil {
+			fmt.Fprintf(tw, "Extend:\t%t\n", *st.Extend)
+		}
+		if st.SlackChannel != "" {
+			fmt.Fprintf(tw, "Slack Channel:\t%s\n", st.SlackChannel)
+		}
+		if info := st.JobInfo; info != nil {
+			fmt.Fprintf(tw, "Repository:\t%s\n", info.Repository)
+			fmt.Fprintf(tw, "Workflow:\t%s #%d [%s]\n", info.WorkflowName, info.RunNumber, info.JobID)
+			fmt.Fprintf(tw, "Workflow URL:\t%s\n", info.WorkflowURL())
+			fmt.Fprintf(tw, "Actor:\t%s\n", info.Actor)
+			fmt.Fprintf(tw, "Git Ref:\t%s\n", info.GitRef)
+			if info.PullRequestNum != 0 {
+				fmt.Fprintf(tw, "Pull Request:\t#%d\n", info.PullRequestNum)
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,398 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	constants "github.com/cybozu-go/meows"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/kube"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/output"
+	"github.com/cybozu-go/meows/github"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tokenExpiryMargin is the same as the margin for the controller to update the runner token.
+const tokenExpiryMargin = 5 * time.Minute
+
+var config struct {
+	kube          kube.Config
+	output        output.Format
+	allNamespaces bool
+	githubAPIURL  string
+}
+
+var clients *kube.Clients
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pool",
+		Short: "inspect RunnerPools",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			var err error
+			clients, err = config.kube.NewClients()
+			return err
+		},
+	}
+	cmd.AddCommand(newListCmd())
+	cmd.AddCommand(newDescribeCmd())
+
+	fs := cmd.PersistentFlags()
+	config.kube.AddFlags(fs)
+	config.output.AddFlag(fs)
+	return cmd
+}
+
+// poolSummary is a row of `meows pool list`.
+type poolSummary struct {
+	Namespace     string    `json:"namespace"`
+	Name          string    `json:"name"`
+	Target        string    `json:"target"`
+	Replicas      int32     `json:"replicas"`
+	MaxRunnerPods int32     `json:"maxRunnerPods,omitempty"`
+	Pods          int       `json:"pods"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list RunnerPools",
+		Long:  "This command lists RunnerPools with the number of their runner pods.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			well.Go(func(ctx context.Context) error {
+				var opts []client.ListOption
+				if !config.allNamespaces {
+					opts = append(opts, client.InNamespace(clients.Namespace))
+				}
+				rpList := &meowsv1alpha1.RunnerPoolList{}
+				if err := clients.Client.List(ctx, rpList, opts...); err != nil {
+					return fmt.Errorf("failed to list RunnerPools; %w", err)
+				}
+				podList := &corev1.PodList{}
+				if err := clients.Client.List(ctx, podList, append(opts, kube.RunnerPodLabels(""))...); err != nil {
+					return fmt.Errorf("failed to list runner pods; %w", err)
+				}
+				pods := map[types.NamespacedName]int{}
+				for i := range podList.Items {
+					po := &podList.Items[i]
+					pods[types.NamespacedName{Namespace: po.Namespace, Name: po.Labels[constants.AppInstanceLabelKey]}]++
+				}
+
+				summaries := make([]*poolSummary, 0, len(rpList.Items))
+				for i := range rpList.Items {
+					rp := &rpList.Items[i]
+					summaries = append(summaries, &poolSummary{
+						Namespace:     rp.Namespace,
+						Name:          rp.Name,
+						Target:        target(rp),
+						Replicas:      rp.Spec.Replicas,
+						MaxRunnerPods: rp.Spec.MaxRunnerPods,
+						Pods:          pods[types.NamespacedName{Namespace: rp.Namespace, Name: rp.Name}],
+						CreatedAt:     rp.CreationTimestamp.Time,
+					})
+				}
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, summaries)
+				}
+				return printPoolTable(os.Stdout, summaries, time.Now())
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.BoolVarP(&config.allNamespaces, "all-namespaces", "A", false, "List the RunnerPools in all namespaces.")
+	return cmd
+}
+
+// poolDetail is the consolidated view of `meows pool describe`.
+type poolDetail struct {
+	RunnerPool     *meowsv1alpha1.RunnerPool `json:"runnerPool"`
+	Deployment     *deploymentSummary        `json:"deployment,omitempty"`
+	TokenExpiresAt *time.Time                `json:"tokenExpiresAt,omitempty"`
+	Pods           []*kube.RunnerPod         `json:"pods"`
+	Runners        []*github.Runner          `json:"runners"`
+	Problems       []string                  `json:"problems"`
+}
+
+type deploymentSummary struct {
+	Name              string `json:"name"`
+	Replicas          int32  `json:"replicas"`
+	ReadyReplicas     int32  `json:"readyReplicas"`
+	UpdatedReplicas   int32  `json:"updatedReplicas"`
+	AvailableReplicas int32  `json:"availableReplicas"`
+}
+
+func newDescribeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "describe NAME",
+		Short: "show the details of a RunnerPool",
+		Long: `This command shows a RunnerPool together with its Deployment, its runner token,
+its runner pods and the runners registered in GitHub.
+
+The runners are listed with the credential Secret of the RunnerPool.
+Problems such as offline runners without pods, runner pods without runners
+and the runner token near expiry are reported at the end.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			well.Go(func(ctx context.Context) error {
+				detail, err := describePool(ctx, args[0], time.Now())
+				if err != nil {
+					return err
+				}
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, detail)
+				}
+				return printPoolDetail(os.Stdout, detail)
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+
+	fs := cmd.Flags()
+	fs.StringVar(&config.githubAPIURL, "github-api-url", "", "The base URL of GitHub REST API. The default is https://api.github.com/.")
+	return cmd
+}
+
+func describePool(ctx context.Context, name string, now time.Time) (*poolDetail, error) {
+	rp := &meowsv1alpha1.RunnerPool{}
+	if err := clients.Client.Get(ctx, types.NamespacedName{Namespace: clients.Namespace, Name: name}, rp); err != nil {
+		return nil, fmt.Errorf("failed to get RunnerPool %s/%s; %w", clients.Namespace, name, err)
+	}
+	rp.ManagedFields = nil
+	detail := &poolDetail{
+		RunnerPool: rp,
+		Runners:    []*github.Runner{},
+		Problems:   []string{},
+	}
+
+	if !rp.IsDirectPodManagement() {
+		d := &appsv1.Deployment{}
+		err := clients.Client.Get(ctx, types.NamespacedName{Namespace: rp.Namespace, Name: rp.GetRunnerDeploymentName()}, d)
+		switch {
+		case apierrors.IsNotFound(err):
+			detail.addProblem("Deployment %s is not found", rp.GetRunnerDeploymentName())
+		case err != nil:
+			return nil, fmt.Errorf("failed to get Deployment; %w", err)
+		default:
+			detail.Deployment = &deploymentSummary{
+				Name:              d.Name,
+				Replicas:          d.Status.Replicas,
+				ReadyReplicas:     d.Status.ReadyReplicas,
+				UpdatedReplicas:   d.Status.UpdatedReplicas,
+				AvailableReplicas: d.Status.AvailableReplicas,
+			}
+		}
+	}
+
+	// The runner token Secret is not used with the just-in-time configuration.
+	if !rp.Spec.JITConfig {
+		if err := detail.checkRunnerToken(ctx, rp, now); err != nil {
+			return nil, err
+		}
+	}
+
+	pods, err := clients.ListRunnerPods(ctx, client.InNamespace(rp.Namespace), kube.RunnerPodLabels(rp.Name))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	detail.Pods = pods
+	for _, p := range pods {
+		if p.Error != "" {
+			detail.addProblem("failed to get the status of pod %s: %s", p.Name, p.Error)
+		}
+	}
+
+	runners, err := listRunners(ctx, rp)
+	if err != nil {
+		detail.addProblem("failed to list the runners in GitHub: %v", err)
+		return detail, nil
+	}
+	sort.Slice(runners, func(i, j int) bool { return runners[i].Name < runners[j].Name })
+	detail.Runners = append(detail.Runners, runners...)
+	detail.checkRunners()
+	return detail, nil
+}
+
+func (d *poolDetail) addProblem(format string, args ...interface{}) {
+	d.Problems = append(d.Problems, fmt.Sprintf(format, args...))
+}
+
+func (d *poolDetail) checkRunnerToken(ctx context.Context, rp *meowsv1alpha1.RunnerPool, now time.Time) error {
+	s := &corev1.Secret{}
+	err := clients.Client.Get(ctx, types.NamespacedName{Namespace: rp.Namespace, Name: rp.GetRunnerSecretName()}, s)
+	if apierrors.IsNotFound(err) {
+		d.addProblem("runner token Secret %s is not found", rp.GetRunnerSecretName())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get runner token Secret; %w", err)
+	}
+
+	expiresAtStr, ok := s.Annotations[constants.RunnerSecretExpiresAtAnnotationKey]
+	if !ok {
+		d.addProblem("runner token Secret %s is not annotated with %s", s.Name, constants.RunnerSecretExpiresAtAnnotationKey)
+		return nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, expiresAtStr)
+	if err != nil {
+		d.addProblem("runner token Secret %s has an invalid %s: %s", s.Name, constants.RunnerSecretExpiresAtAnnotationKey, expiresAtStr)
+		return nil
+	}
+	d.TokenExpiresAt = &expiresAt
+	switch {
+	case !now.Before(expiresAt):
+		d.addProblem("runner token expired at %s", expiresAt.UTC().Format(time.RFC3339))
+	case now.Add(tokenExpiryMargin).After(expiresAt):
+		d.addProblem("runner token expires soon at %s", expiresAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// checkRunners reports the runners and the runner pods that do not match each other.
+func (d *poolDetail) checkRunners() {
+	pods := map[string]*kube.RunnerPod{}
+	for _, p := range d.Pods {
+		pods[p.Name] = p
+	}
+	runners := map[string]*github.Runner{}
+	for _, r := range d.Runners {
+		runners[r.Name] = r
+		if !r.Online && pods[r.Name] == nil {
+			d.addProblem("offline runner %s (id: %d) has no pod", r.Name, r.ID)
+		}
+	}
+	for _, p := range d.Pods {
+		if p.Status == nil || p.Status.State != constants.RunnerPodStateRunning {
+			continue
+		}
+		if runners[p.Name] == nil {
+			d.addProblem("running pod %s has no runner", p.Name)
+		}
+	}
+}
+
+func listRunners(ctx context.Context, rp *meowsv1alpha1.RunnerPool) ([]*github.Runner, error) {
+	s := &corev1.Secret{}
+	err := clients.Client.Get(ctx, types.NamespacedName{Namespace: rp.Namespace, Name: rp.GetCredentialSecretName()}, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential Secret %s; %w", rp.GetCredentialSecretName(), err)
+	}
+	cred, err := github.NewCredentialFromSecretData(s.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential Secret %s; %w", s.Name, err)
+	}
+	factory, err := github.NewFactory(config.githubAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	githubClient, err := factory.New(cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create github client; %w", err)
+	}
+	return githubClient.ListRunners(ctx, rp.GetOwner(), rp.GetRepository(), []string{rp.GetRunnerLabel()})
+}
+
+func target(rp *meowsv1alpha1.RunnerPool) string {
+	if rp.IsOrgLevel() {
+		return rp.Spec.Organization
+	}
+	return rp.Spec.Repository
+}
+
+func printPoolTable(w io.Writer, summaries []*poolSummary, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tTARGET\tREPLICAS\tMAXRUNNERPODS\tPODS\tAGE")
+	for _, s := range summaries {
+		maxRunnerPods := "-"
+		if s.MaxRunnerPods != 0 {
+			maxRunnerPods = fmt.Sprint(s.MaxRunnerPods)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			s.Namespace, s.Name, s.Target, s.Replicas, maxRunnerPods, s.Pods, duration.HumanDuration(now.Sub(s.CreatedAt)))
+	}
+	return tw.Flush()
+}
+
+func printPoolDetail(w io.Writer, d *poolDetail) error {
+	rp := d.RunnerPool
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "RunnerPool:\t%s/%s\n", rp.Namespace, rp.Name)
+	fmt.Fprintf(tw, "Target:\t%s\n", target(rp))
+	fmt.Fprintf(tw, "Replicas:\t%d\n", rp.Spec.Replicas)
+	if rp.Spec.MaxRunnerPods != 0 {
+		fmt.Fprintf(tw, "Max Runner Pods:\t%d\n", rp.Spec.MaxRunnerPods)
+	}
+	fmt.Fprintf(tw, "Pod Management:\t%s\n", output.OrDash(string(rp.Spec.PodManagement)))
+	fmt.Fprintf(tw, "Credential Secret:\t%s\n", rp.GetCredentialSecretName())
+	if dep := d.Deployment; dep != nil {
+		fmt.Fprintf(tw, "Deployment:\t%s (ready: %d/%d, updated: %d, available: %d)\n",
+			dep.Name, dep.ReadyReplicas, dep.Replicas, dep.UpdatedReplicas, dep.AvailableReplicas)
+	}
+	fmt.Fprintf(tw, "Token Expires At:\t%s\n", output.FormatTime(d.TokenExpiresAt))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Pods:")
+	tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tNODE\tSTATE\tRESULT\tDELETION TIME")
+	for _, p := range d.Pods {
+		result, deletionTime := "-", "-"
+		if st := p.Status; st != nil {
+			result = output.OrDash(st.Result)
+			deletionTime = output.FormatTime(st.DeletionTime)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", p.Name, output.OrDash(p.Node), p.State(), result, deletionTime)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Runners:")
+	tw = tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tID\tONLINE\tBUSY")
+	for _, r := range d.Runners {
+		fmt.Fprintf(tw, "  %s\t%d\t%t\t%t\n", r.Name, r.ID, r.Online, r.Busy)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	if len(d.Problems) == 0 {
+		fmt.Fprintln(w, "Problems: none")
+		return nil
+	}
+	fmt.Fprintln(w, "Problems:")
+	for _, p := range d.Problems {
+		fmt.Fprintf(w, "  ! %s\n", p)
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
+	"github.com/cybozu-go/meows/cmd/meows/cmd/doctor"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/pod"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/pool"
 	"github.com/cybozu-go/meows/cmd/meows/cmd/runner"
 	"github.com/cybozu-go/meows/cmd/meows/cmd/slackagent"
 	"github.com/spf13/cobra"
@@ -12,10 +16,16 @@ var rootCmd = &cobra.Command{
 	Use: "meows",
 }
 
+// exitCodeChecksFailed is the exit code of `meows doctor` when some checks failed.
+const exitCodeChecksFailed = 2
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, doctor.ErrChecksFailed) {
+			os.Exit(exitCodeChecksFailed)
+		}
 		os.Exit(1)
 	}
 }
@@ -23,4 +33,7 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(slackagent.NewCommand())
 	rootCmd.AddCommand(runner.NewCommand())
+	rootCmd.AddCommand(pod.NewCommand())
+	rootCmd.AddCommand(pool.NewCommand())
+	rootCmd.AddCommand(doctor.NewCommand())
 }
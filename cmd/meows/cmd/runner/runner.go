@@ -1,14 +1,33 @@
 package runner
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	constants "github.com/cybozu-go/meows"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/kube"
+	"github.com/cybozu-go/meows/cmd/meows/cmd/output"
+	"github.com/cybozu-go/meows/controllers"
 	"github.com/cybozu-go/meows/github"
 	"github.com/cybozu-go/well"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The values of the --status flag.
+const (
+	statusOnline  = "online"
+	statusOffline = "offline"
 )
 
 var config struct {
@@ -16,10 +35,71 @@ var config struct {
 	appInstallationID   int64
 	appPrivateKeyPath   string
 	personalAccessToken string
+	credentialSecret    string
+	kube                kube.Config
+	githubAPIURL        string
+	githubProxyURL      string
+	githubNoProxy       string
+	githubCABundlePath  string
+
+	output        output.Format
+	dryRun        bool
+	yes           bool
+	gracePeriod   time.Duration
+	includeLegacy bool
 }
 
 var githubClient github.Client
 
+// runnerFilter selects the runners by the flags.
+type runnerFilter struct {
+	labels    []string
+	status    string
+	busy      bool
+	busySet   bool
+	nameRegex string
+
+	re *regexp.Regexp
+}
+
+func (f *runnerFilter) addFlags(cmd *cobra.Command, defaultStatus string) {
+	fs := cmd.Flags()
+	fs.StringSliceVar(&f.labels, "label", nil, "Select the runners that have all the labels. Can be specified multiple times.")
+	fs.StringVar(&f.status, "status", defaultStatus, "Select the runners by the status. One of: online, offline.")
+	fs.BoolVar(&f.busy, "busy", false, "Select the busy runners. --busy=false selects the idle runners.")
+	fs.StringVar(&f.nameRegex, "name-regex", "", "Select the runners whose names match the regular expression.")
+}
+
+// complete validates the flags and prepares the filter.
+func (f *runnerFilter) complete(cmd *cobra.Command) error {
+	if f.status != "" && f.status != statusOnline && f.status != statusOffline {
+		return fmt.Errorf("unsupported status: %s", f.status)
+	}
+	f.busySet = cmd.Flags().Changed("busy")
+	if f.nameRegex != "" {
+		re, err := regexp.Compile(f.nameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid --name-regex; %w", err)
+		}
+		f.re = re
+	}
+	return nil
+}
+
+func (f *runnerFilter) match(r *github.Runner) bool {
+	switch {
+	case f.status == statusOnline && !r.Online:
+		return false
+	case f.status == statusOffline && r.Online:
+		return false
+	case f.busySet && r.Busy != f.busy:
+		return false
+	case f.re != nil && !f.re.MatchString(r.Name):
+		return false
+	}
+	return true
+}
+
 func splitOwnerRepo(str string) (string, string, error) {
 	split := strings.Split(str, "/")
 	switch len(split) {
@@ -36,21 +116,51 @@ func NewCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "runner",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			network := github.NetworkConfig{
+				ProxyURL: config.githubProxyURL,
+				NoProxy:  config.githubNoProxy,
+			}
+			if config.githubCABundlePath != "" {
+				caBundle, err := os.ReadFile(config.githubCABundlePath)
+				if err != nil {
+					return fmt.Errorf("failed to read CA bundle; %w", err)
+				}
+				network.CABundle = caBundle
+			}
+
 			var cred *github.ClientCredential
-			if config.personalAccessToken != "" {
+			switch {
+			case config.credentialSecret != "":
+				if config.personalAccessToken != "" || config.appID != 0 {
+					return errors.New("--credential-secret cannot be used with --token or --app-id")
+				}
+				var err error
+				cred, err = readCredentialSecret(cmd.Context(), config.credentialSecret)
+				if err != nil {
+					return err
+				}
+				// The flags take precedence over the Secret because the command may run outside the cluster.
+				cred.Network = cred.Network.Merge(network)
+			case config.personalAccessToken != "":
 				cred = &github.ClientCredential{
 					PersonalAccessToken: config.personalAccessToken,
+					Network:             network,
 				}
-			} else {
+			default:
 				cred = &github.ClientCredential{
 					AppID:             config.appID,
 					AppInstallationID: config.appInstallationID,
 					PrivateKeyPath:    config.appPrivateKeyPath,
+					Network:           network,
 				}
 			}
 
-			var err error
-			githubClient, err = github.NewFactory().New(cred)
+			factory, err := github.NewFactory(config.githubAPIURL)
+			if err != nil {
+				return err
+			}
+			githubClient, err = factory.New(cred)
 			if err != nil {
 				return fmt.Errorf("failed to create github client; %w", err)
 			}
@@ -59,20 +169,59 @@ func NewCommand() *cobra.Command {
 	}
 	cmd.AddCommand(newListCmd())
 	cmd.AddCommand(newRemoveCmd())
+	cmd.AddCommand(newGCCmd())
 
 	fs := cmd.PersistentFlags()
 	fs.Int64Var(&config.appID, "app-id", 0, "The ID for GitHub App.")
 	fs.Int64Var(&config.appInstallationID, "app-installation-id", 0, "The installation ID for GitHub App.")
 	fs.StringVar(&config.appPrivateKeyPath, "app-private-key-path", "", "The path for GitHub App private key.")
 	fs.StringVar(&config.personalAccessToken, "token", "", "The personal access token (PAT) of GitHub.")
+	fs.StringVar(&config.credentialSecret, "credential-secret", "", "The name of the Secret that has the credential in the same format as the RunnerPool's one.")
+	config.kube.AddFlags(fs)
+	fs.StringVar(&config.githubAPIURL, "github-api-url", "", "The base URL of GitHub REST API. The default is https://api.github.com/.")
+	fs.StringVar(&config.githubProxyURL, "github-proxy-url", "", "The URL of the HTTP proxy to access GitHub.")
+	fs.StringVar(&config.githubNoProxy, "github-no-proxy", "", "The comma-separated list of hosts accessed without the proxy.")
+	fs.StringVar(&config.githubCABundlePath, "github-ca-bundle", "", "The path to the PEM-encoded CA certificates trusted in addition to the system roots.")
+	config.output.AddFlag(fs)
 	return cmd
 }
 
+func readCredentialSecret(ctx context.Context, name string) (*github.ClientCredential, error) {
+	clients, err := config.kube.NewClients()
+	if err != nil {
+		return nil, err
+	}
+	s := &corev1.Secret{}
+	if err := clients.Client.Get(ctx, types.NamespacedName{Namespace: clients.Namespace, Name: name}, s); err != nil {
+		return nil, fmt.Errorf("failed to get Secret %s/%s; %w", clients.Namespace, name, err)
+	}
+	cred, err := github.NewCredentialFromSecretData(s.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Secret %s/%s; %w", clients.Namespace, name, err)
+	}
+	return cred, nil
+}
+
+func listRunners(ctx context.Context, owner, repo string, filter *runnerFilter) ([]*github.Runner, error) {
+	runners, err := githubClient.ListRunners(ctx, owner, repo, filter.labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runners; %w", err)
+	}
+	selected := make([]*github.Runner, 0, len(runners))
+	for _, r := range runners {
+		if filter.match(r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
+
 func newListCmd() *cobra.Command {
+	filter := &runnerFilter{}
 	cmd := &cobra.Command{
 		Use:   "list [ORGANIZATION | REPOSITORY]",
 		Short: "list runners",
-		Long:  "This command lists all runners on the specified organization or repository.",
+		Long:  "This command lists the runners on the specified organization or repository that match the filters.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
@@ -80,62 +229,221 @@ func newListCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if err := filter.complete(cmd); err != nil {
+				return err
+			}
 
 			well.Go(func(ctx context.Context) error {
-				runners, err := githubClient.ListRunners(ctx, owner, repo, nil)
+				runners, err := listRunners(ctx, owner, repo, filter)
 				if err != nil {
-					return fmt.Errorf("failed to create github client; %w", err)
+					return err
 				}
-				str, err := json.MarshalIndent(runners, "", "    ")
-				if err != nil {
-					return fmt.Errorf("failed to marshal runners; %w", err)
+				if !config.output.IsTable() {
+					return config.output.Print(os.Stdout, runners)
 				}
-
-				fmt.Println(string(str))
-				return nil
+				return printRunnerTable(os.Stdout, runners)
 			})
 
 			well.Stop()
 			return well.Wait()
 		},
 	}
+	filter.addFlags(cmd, "")
 	return cmd
 }
 
 func newRemoveCmd() *cobra.Command {
+	filter := &runnerFilter{}
 	cmd := &cobra.Command{
-		Use:   "remove  [ORGANIZATION | REPOSITORY]",
-		Short: "remove offline runners",
-		Long:  "This command removes offline runners on the specified organization or repository.",
-		Args:  cobra.ExactArgs(1),
+		Use:   "remove [ORGANIZATION | REPOSITORY]",
+		Short: "remove runners",
+		Long: `This command removes the runners on the specified organization or repository that match the filters.
+
+Only the offline runners are removed unless --status is specified.
+The runners to be removed are shown and confirmed before the removal.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 			owner, repo, err := splitOwnerRepo(args[0])
 			if err != nil {
 				return err
 			}
+			if err := filter.complete(cmd); err != nil {
+				return err
+			}
 
 			well.Go(func(ctx context.Context) error {
-				runners, err := githubClient.ListRunners(ctx, owner, repo, nil)
+				runners, err := listRunners(ctx, owner, repo, filter)
 				if err != nil {
-					return fmt.Errorf("failed to create github client; %w", err)
+					return err
 				}
-				for _, r := range runners {
-					if r.Online {
-						continue
+				return removeRunners(ctx, owner, repo, runners)
+			})
+
+			well.Stop()
+			return well.Wait()
+		},
+	}
+	filter.addFlags(cmd, statusOffline)
+	addRemoveFlags(cmd)
+	return cmd
+}
+
+func newGCCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [ORGANIZATION | REPOSITORY]",
+		Short: "remove orphaned runners",
+		Long: `This command removes the offline runners on the specified organization or repository
+whose RunnerPools do not exist in the Kubernetes cluster.
+
+The runners of meows have the label of their RunnerPools in the form of NAMESPACE/NAME,
+and the ` + constants.ManagedRunnerLabel + ` label. The runners without such labels are not removed.
+The runners registered without the ` + constants.ManagedRunnerLabel + ` label by the older versions of meows
+are removed only if --include-legacy is specified.
+The runners are removed only if they are still orphaned after the grace period.
+The runners to be removed are shown and confirmed before the removal.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			owner, repo, err := splitOwnerRepo(args[0])
+			if err != nil {
+				return err
+			}
+			clients, err := config.kube.NewClients()
+			if err != nil {
+				return err
+			}
+
+			well.Go(func(ctx context.Context) error {
+				orphaned, err := findOrphanedRunners(ctx, clients, owner, repo)
+				if err != nil {
+					return err
+				}
+				if len(orphaned) != 0 && config.gracePeriod != 0 {
+					fmt.Printf("found %d orphaned runners; checking them again after %s\n", len(orphaned), config.gracePeriod)
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(config.gracePeriod):
+					}
+
+					// Remove only the runners that are orphaned throughout the grace period.
+					found := map[int64]bool{}
+					for _, r := range orphaned {
+						found[r.ID] = true
 					}
-					err := githubClient.RemoveRunner(ctx, owner, repo, r.ID)
+					again, err := findOrphanedRunners(ctx, clients, owner, repo)
 					if err != nil {
-						return fmt.Errorf("failed to remove runner %s (id: %d); %w", r.Name, r.ID, err)
+						return err
+					}
+					orphaned = nil
+					for _, r := range again {
+						if found[r.ID] {
+							orphaned = append(orphaned, r)
+						}
 					}
-					fmt.Printf("remove runner %s (id: %d)\n", r.Name, r.ID)
 				}
-				return nil
+				return removeRunners(ctx, owner, repo, orphaned)
 			})
 
 			well.Stop()
 			return well.Wait()
 		},
 	}
+	addRemoveFlags(cmd)
+	cmd.Flags().DurationVar(&config.gracePeriod, "grace-period", time.Minute, "Period for which the runners should be orphaned before they are removed.")
+	cmd.Flags().BoolVar(&config.includeLegacy, "include-legacy", false, "Remove also the runners registered without the "+constants.ManagedRunnerLabel+" label by the older versions of meows.")
 	return cmd
 }
+
+// findOrphanedRunners lists the runners on the organization or the repository whose RunnerPools do not exist.
+func findOrphanedRunners(ctx context.Context, clients *kube.Clients, owner, repo string) ([]*github.Runner, error) {
+	rpList := &meowsv1alpha1.RunnerPoolList{}
+	if err := clients.Client.List(ctx, rpList); err != nil {
+		return nil, fmt.Errorf("failed to list RunnerPools; %w", err)
+	}
+	existing := map[types.NamespacedName]bool{}
+	for i := range rpList.Items {
+		rp := &rpList.Items[i]
+		existing[types.NamespacedName{Namespace: rp.Namespace, Name: rp.Name}] = true
+	}
+
+	runners, err := githubClient.ListRunners(ctx, owner, repo, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runners; %w", err)
+	}
+	return controllers.FindOrphanedRunners(runners, existing, config.includeLegacy), nil
+}
+
+func addRemoveFlags(cmd *cobra.Command) {
+	fs := cmd.Flags()
+	fs.BoolVar(&config.dryRun, "dry-run", false, "Show the runners to be removed without removing them.")
+	fs.BoolVarP(&config.yes, "yes", "y", false, "Remove the runners without confirmation.")
+}
+
+// removeRunners shows the runners and removes them after the confirmation.
+func removeRunners(ctx context.Context, owner, repo string, runners []*github.Runner) error {
+	if len(runners) == 0 {
+		fmt.Println("no runners to remove")
+		return nil
+	}
+	if err := printRunnerTable(os.Stdout, runners); err != nil {
+		return err
+	}
+	if config.dryRun {
+		fmt.Printf("%d runners would be removed (dry run)\n", len(runners))
+		return nil
+	}
+	if !config.yes {
+		ok, err := confirm(os.Stdin, os.Stdout, fmt.Sprintf("Remove %d runners?", len(runners)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("aborted")
+			return nil
+		}
+	}
+
+	// Remove all the confirmed runners even if some of them fail.
+	var errs []error
+	for _, r := range runners {
+		err := githubClient.RemoveRunner(ctx, owner, repo, r.ID)
+		if err != nil {
+			err = fmt.Errorf("failed to remove runner %s (id: %d); %w", r.Name, r.ID, err)
+			fmt.Println(err)
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("remove runner %s (id: %d)\n", r.Name, r.ID)
+	}
+	fmt.Printf("%d runners removed, %d failed\n", len(runners)-len(errs), len(errs))
+	return errors.Join(errs...)
+}
+
+// confirm asks the user to answer yes or no, and returns true only for yes.
+func confirm(r io.Reader, w io.Writer, question string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read the answer; %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func printRunnerTable(w io.Writer, runners []*github.Runner) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tBUSY\tLABELS")
+	for _, r := range runners {
+		status := statusOffline
+		if r.Online {
+			status = statusOnline
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\n", r.ID, r.Name, status, r.Busy, strings.Join(r.Labels, ","))
+	}
+	return tw.Flush()
+}
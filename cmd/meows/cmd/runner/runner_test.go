@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cybozu-go/meows/github"
+)
+
+func TestRunnerFilterMatch(t *testing.T) {
+	runner := &github.Runner{Name: "rp1-abcde", Online: true, Busy: false}
+
+	testCases := []struct {
+		title    string
+		filter   runnerFilter
+		expected bool
+	}{
+		{
+			title:    "no filter",
+			filter:   runnerFilter{},
+			expected: true,
+		},
+		{
+			title:    "online",
+			filter:   runnerFilter{status: statusOnline},
+			expected: true,
+		},
+		{
+			title:    "offline",
+			filter:   runnerFilter{status: statusOffline},
+			expected: false,
+		},
+		{
+			title:    "idle",
+			filter:   runnerFilter{busy: false, busySet: true},
+			expected: true,
+		},
+		{
+			title:    "busy",
+			filter:   runnerFilter{busy: true, busySet: true},
+			expected: false,
+		},
+		{
+			title:    "busy is not specified",
+			filter:   runnerFilter{busy: true},
+			expected: true,
+		},
+		{
+			title:    "name matches",
+			filter:   runnerFilter{re: regexp.MustCompile("^rp1-")},
+			expected: true,
+		},
+		{
+			title:    "name does not match",
+			filter:   runnerFilter{re: regexp.MustCompile("^rp2-")},
+			expected: false,
+		},
+		{
+			title:    "all conditions",
+			filter:   runnerFilter{status: statusOnline, busy: false, busySet: true, re: regexp.MustCompile("abc")},
+			expected: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			if actual := tt.filter.match(runner); actual != tt.expected {
+				t.Errorf("expected %v, actual %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{input: "y\n", expected: true},
+		{input: "yes\n", expected: true},
+		{input: " YES \n", expected: true},
+		{input: "y", expected: true},
+		{input: "n\n", expected: false},
+		{input: "no\n", expected: false},
+		{input: "\n", expected: false},
+		{input: "", expected: false},
+		{input: "yess\n", expected: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.input, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			actual, err := confirm(strings.NewReader(tt.input), out, "Remove?")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %v, actual %v", tt.expected, actual)
+			}
+			if out.String() != "Remove? [y/N]: " {
+				t.Errorf("unexpected prompt: %q", out.String())
+			}
+		})
+	}
+}
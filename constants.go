@@ -15,6 +15,16 @@ const (
 const (
 	RunnerSecretExpiresAtAnnotationKey = "meows.cybozu.com/expires-at"
 
+	// NotifiedAnnotationKey is an annotation key for the time when the job result of a runner pod is notified.
+	NotifiedAnnotationKey = "meows.cybozu.com/notified"
+
+	// ExtendUntilAnnotationKey is an annotation key to extend a debugging runner pod.
+	// The value is a time in RFC 3339 format or a duration from now.
+	ExtendUntilAnnotationKey = "meows.cybozu.com/extend-until"
+
+	// DeleteNowAnnotationKey is an annotation key to delete a debugging runner pod immediately.
+	DeleteNowAnnotationKey = "meows.cybozu.com/delete-now"
+
 	// RunnerPoolFinalizer is a finalizer for runnerpool resource.
 	RunnerPoolFinalizer = "meows.cybozu.com/runnerpool"
 
@@ -29,6 +39,12 @@ const (
 
 	// RunnerPodName is the label key to select individual pod.
 	RunnerPodName = "meows.cybozu.com/runner-pod-name"
+
+	// BusyLabelKey is a label key given to the busy runner pods in the pool-level disruption protection.
+	BusyLabelKey = "meows.cybozu.com/busy"
+
+	// PodTemplateHashLabelKey is a label key for the hash of the template of a runner pod created directly by the controller.
+	PodTemplateHashLabelKey = "meows.cybozu.com/pod-template-hash"
 )
 
 const (
@@ -55,6 +71,9 @@ const (
 
 	// StatusEndPoint is the endpoint to get status of a runner pod.
 	StatusEndPoint = "status"
+
+	// JITConfigEndpoint is the endpoint to give a just-in-time runner configuration to a runner pod.
+	JITConfigEndpoint = "jitconfig"
 )
 
 // Runner pods state.
@@ -63,6 +82,20 @@ const (
 	RunnerPodStateRunning      = "running"
 	RunnerPodStateDebugging    = "debugging"
 	RunnerPodStateStale        = "stale"
+	RunnerPodStateSetupFailed  = "setup_failed"
+)
+
+// Reasons why runner pods are recreated by the registration timeout.
+const (
+	RegistrationTimeoutReasonNotRunning    = "not_running"
+	RegistrationTimeoutReasonNotRegistered = "not_registered"
+)
+
+// Runner labels
+const (
+	// ManagedRunnerLabel is a label given to the runners registered by meows in addition to the label of their RunnerPool.
+	// Only the runners with this label are removed by the runner garbage collector.
+	ManagedRunnerLabel = "meows.cybozu.com/managed"
 )
 
 // Exit state of Actions Listener.
@@ -82,6 +115,16 @@ const (
 	OptionConfigMapDataRepositoryRule   = "repository-rule"
 )
 
+// Constants for the runner garbage collector.
+const (
+	// RunnerGCTargetsConfigMapName is a configmap name to record the targets of the runner garbage collector.
+	// It is in the namespace of the controller.
+	RunnerGCTargetsConfigMapName = "meows-runner-gc-targets"
+
+	// RunnerGCTargetsConfigMapDataTargets is a data key for the JSON list of the targets.
+	RunnerGCTargetsConfigMapDataTargets = "targets"
+)
+
 // Constants for GitHub credential secret.
 const (
 	// DefaultCredentialSecretName is the default secret name for GitHub credential secret.
@@ -94,6 +137,11 @@ const (
 
 	// Data keys for GitHub personal access token (PAT).
 	CredentialSecretDataPATToken = "token"
+
+	// Optional data keys for the network to access GitHub.
+	CredentialSecretDataProxyURL = "proxy-url"
+	CredentialSecretDataNoProxy  = "no-proxy"
+	CredentialSecretDataCABundle = "ca-bundle"
 )
 
 const (
@@ -114,6 +162,15 @@ const (
 	// SlackChannelFilePath is a file path for the Slack channel to be notified.
 	SlackChannelFilePath = RunnerVarDirPath + "/slack_channel"
 
+	// JobStartedFilePath is a file path for the time when the job started.
+	JobStartedFilePath = RunnerVarDirPath + "/job_started"
+
+	// JobStartedHookPath is a file path for the script run by the runner at the beginning of a job.
+	JobStartedHookPath = "/usr/local/bin/job-started-hook.sh"
+
+	// JobCompletedHookPath is a file path for the script run by the runner at the end of a job.
+	JobCompletedHookPath = "/usr/local/bin/job-completed-hook.sh"
+
 	// SecretsDirName is a directory name for storing secret files.
 	SecretsDirName = "secrets"
 
@@ -143,4 +200,13 @@ const (
 
 	// SlackChannelEnvName is a env field key for MEOWS_SLACK_CHANNEL
 	SlackChannelEnvName = "MEOWS_SLACK_CHANNEL"
+
+	// CABundleEnvName is a env field key for MEOWS_CA_BUNDLE
+	CABundleEnvName = "MEOWS_CA_BUNDLE"
+
+	// JobStartedHookEnvName is a env field key for ACTIONS_RUNNER_HOOK_JOB_STARTED
+	JobStartedHookEnvName = "ACTIONS_RUNNER_HOOK_JOB_STARTED"
+
+	// JobCompletedHookEnvName is a env field key for ACTIONS_RUNNER_HOOK_JOB_COMPLETED
+	JobCompletedHookEnvName = "ACTIONS_RUNNER_HOOK_JOB_COMPLETED"
 )
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podExpectationsTimeout is the time after which the expectations are forgotten even if they are not observed.
+// This is the same as the ReplicaSet controller.
+const podExpectationsTimeout = 5 * time.Minute
+
+// podExpectations tracks the runner pods that the runner manager has created or deleted,
+// but that are not reflected in the cache yet.
+// Without this, the runner manager would create or delete extra pods based on the stale cache.
+// This is not goroutine-safe. It is used only by the goroutine of a RunnerPool.
+type podExpectations struct {
+	creations map[string]time.Time
+	deletions map[string]time.Time
+}
+
+func newPodExpectations() *podExpectations {
+	return &podExpectations{
+		creations: map[string]time.Time{},
+		deletions: map[string]time.Time{},
+	}
+}
+
+func (e *podExpectations) expectCreation(name string, now time.Time) {
+	e.creations[name] = now
+}
+
+func (e *podExpectations) expectDeletion(name string, now time.Time) {
+	e.deletions[name] = now
+}
+
+// observe forgets the expectations that are satisfied by the pod list or have timed out.
+func (e *podExpectations) observe(podList *corev1.PodList, now time.Time) {
+	exists := map[string]bool{}
+	for i := range podList.Items {
+		po := &podList.Items[i]
+		if po.DeletionTimestamp == nil {
+			exists[po.Name] = true
+		}
+	}
+
+	for name, t := range e.creations {
+		if exists[name] || now.After(t.Add(podExpectationsTimeout)) {
+			delete(e.creations, name)
+		}
+	}
+	for name, t := range e.deletions {
+		if !exists[name] || now.After(t.Add(podExpectationsTimeout)) {
+			delete(e.deletions, name)
+		}
+	}
+}
+
+// pendingCreations returns the number of the created pods that are not observed yet.
+func (e *podExpectations) pendingCreations() int {
+	return len(e.creations)
+}
+
+// deleting returns true if the pod has been deleted but the deletion is not observed yet.
+func (e *podExpectations) deleting(name string) bool {
+	_, ok := e.deletions[name]
+	return ok
+}
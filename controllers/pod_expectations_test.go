@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("PodExpectations", func() {
+	It("should forget the expectations when they are observed or timed out", func() {
+		now := time.Now()
+		e := newPodExpectations()
+		e.expectCreation("pod1", now)
+		e.expectCreation("pod2", now)
+		e.expectDeletion("pod3", now)
+		e.expectDeletion("pod4", now)
+
+		By("observing the creation of pod1 and the deletion of pod3")
+		podList := &corev1.PodList{Items: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod3", DeletionTimestamp: &metav1.Time{Time: now}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod4"}},
+		}}
+		e.observe(podList, now)
+		Expect(e.pendingCreations()).To(Equal(1))
+		Expect(e.deleting("pod3")).To(BeFalse())
+		Expect(e.deleting("pod4")).To(BeTrue())
+
+		By("timing out the rest")
+		e.observe(podList, now.Add(podExpectationsTimeout+time.Second))
+		Expect(e.pendingCreations()).To(Equal(0))
+		Expect(e.deleting("pod4")).To(BeFalse())
+	})
+})
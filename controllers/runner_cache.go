@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/meows/github"
+	"github.com/cybozu-go/meows/metrics"
+)
+
+// runnerCache caches the runners registered in GitHub for each organization or repository.
+// RunnerPools that share the same owner/repo and credential are served from one entry,
+// so the runner list is fetched only once per ttl however many RunnerPools there are.
+//
+// Each RunnerPool should acquire its entry when it starts and release it when it stops.
+// An entry is discarded when all of its RunnerPools release it.
+type runnerCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*runnerCacheEntry
+}
+
+type runnerCacheEntry struct {
+	// mu serializes fetches, so that concurrent callers wait for one request instead of sending their own.
+	mu        sync.Mutex
+	runners   []*github.Runner
+	fetchedAt time.Time
+	// notBefore is the time until which the API should not be called due to the rate limit.
+	notBefore time.Time
+	// refs is the number of the RunnerPools using this entry. It is protected by runnerCache.mu.
+	refs int
+}
+
+func newRunnerCache(ttl time.Duration) *runnerCache {
+	return &runnerCache{
+		ttl:     ttl,
+		entries: map[string]*runnerCacheEntry{},
+	}
+}
+
+func runnerCacheKey(credentialID, owner, repo string) string {
+	return credentialID + ":" + owner + "/" + repo
+}
+
+func (c *runnerCache) getEntry(key string) *runnerCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &runnerCacheEntry{}
+		c.entries[key] = e
+	}
+	return e
+}
+
+// acquire marks the entry as used by a RunnerPool.
+func (c *runnerCache) acquire(credentialID, owner, repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := runnerCacheKey(credentialID, owner, repo)
+	e, ok := c.entries[key]
+	if !ok {
+		e = &runnerCacheEntry{}
+		c.entries[key] = e
+	}
+	e.refs++
+}
+
+// release discards the entry if no RunnerPool uses it anymore.
+func (c *runnerCache) release(credentialID, owner, repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := runnerCacheKey(credentialID, owner, repo)
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	e.refs--
+	if e.refs <= 0 {
+		delete(c.entries, key)
+	}
+}
+
+// len returns the number of the cached entries.
+func (c *runnerCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// listRunners returns the runners that have all of the labels.
+// The runner list is fetched from GitHub when the cached one is older than ttl.
+func (c *runnerCache) listRunners(ctx context.Context, githubClient github.Client, credentialID, owner, repo string, labels []string) ([]*github.Runner, error) {
+	e := c.getEntry(runnerCacheKey(credentialID, owner, repo))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	if e.fetchedAt.IsZero() || now.Sub(e.fetchedAt) >= c.ttl {
+		if now.Before(e.notBefore) {
+			return nil, fmt.Errorf("%w; retry after %s", github.ErrRateLimited, e.notBefore.Format(time.RFC3339))
+		}
+
+		metrics.IncrementRunnerCache(false)
+		runners, err := githubClient.ListRunners(ctx, owner, repo, nil)
+		rate := githubClient.RateLimit()
+		if rate.Limit != 0 && rate.Remaining == 0 {
+			e.notBefore = rate.Reset
+		}
+		if err != nil {
+			if retryAfter, ok := github.RetryAfter(err); ok {
+				e.notBefore = retryAfter
+			}
+			return nil, err
+		}
+		e.runners = runners
+		e.fetchedAt = now
+	} else {
+		metrics.IncrementRunnerCache(true)
+	}
+
+	var ret []*github.Runner
+	for _, r := range e.runners {
+		if r.HasLabels(labels) {
+			ret = append(ret, r)
+		}
+	}
+	return ret, nil
+}
+
+// removeRunner drops the runner from the cached list after it is removed from GitHub.
+func (c *runnerCache) removeRunner(credentialID, owner, repo string, runnerID int64) {
+	c.mu.Lock()
+	e, ok := c.entries[runnerCacheKey(credentialID, owner, repo)]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	runners := make([]*github.Runner, 0, len(e.runners))
+	for _, r := range e.runners {
+		if r.ID != runnerID {
+			runners = append(runners, r)
+		}
+	}
+	e.runners = runners
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybozu-go/meows/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunnerCache", func() {
+	ctx := context.Background()
+
+	It("should share the runner list between callers", func() {
+		githubClientFactory := github.NewFakeClientFactory()
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: true, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod2", ID: 2, Online: true, Labels: []string{"test-ns1/rp2"}},
+			},
+		})
+		githubClient, err := githubClientFactory.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		cache := newRunnerCache(time.Second)
+
+		By("listing runners for two runnerpools")
+		runners, err := cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", []string{"test-ns1/rp1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runners).To(HaveLen(1))
+		Expect(runners[0].Name).To(Equal("pod1"))
+		runners, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", []string{"test-ns1/rp2"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runners).To(HaveLen(1))
+		Expect(runners[0].Name).To(Equal("pod2"))
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(1))
+
+		By("listing runners with another credential")
+		_, err = cache.listRunners(ctx, githubClient, "cred2", "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(2))
+
+		By("removing a runner")
+		cache.removeRunner("cred", "owner", "repo1", 1)
+		runners, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", []string{"test-ns1/rp1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runners).To(BeEmpty())
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(2))
+
+		By("listing runners after the cache is expired")
+		time.Sleep(time.Second)
+		runners, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", []string{"test-ns1/rp1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runners).To(HaveLen(1))
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(3))
+	})
+
+	It("should not call the API until the rate limit is reset", func() {
+		githubClientFactory := github.NewFakeClientFactory()
+		githubClientFactory.SetRateLimit(github.RateLimit{
+			Limit:     5000,
+			Remaining: 0,
+			Reset:     time.Now().Add(time.Hour),
+		})
+		githubClient, err := githubClientFactory.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		cache := newRunnerCache(0)
+
+		_, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", nil)
+		Expect(err).To(HaveOccurred())
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(1))
+	})
+
+	It("should discard the entry when all runnerpools release it", func() {
+		githubClientFactory := github.NewFakeClientFactory()
+		githubClient, err := githubClientFactory.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		cache := newRunnerCache(time.Hour)
+
+		By("acquiring the entry by two runnerpools")
+		cache.acquire("cred", "owner", "repo1")
+		cache.acquire("cred", "owner", "repo1")
+		_, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.len()).To(Equal(1))
+
+		By("releasing the entry by one of them")
+		cache.release("cred", "owner", "repo1")
+		Expect(cache.len()).To(Equal(1))
+		_, err = cache.listRunners(ctx, githubClient, "cred", "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(githubClientFactory.ListRunnersCount()).To(Equal(1))
+
+		By("releasing the entry by the other")
+		cache.release("cred", "owner", "repo1")
+		Expect(cache.len()).To(Equal(0))
+
+		By("removing a runner after the release does not recreate the entry")
+		cache.removeRunner("cred", "owner", "repo1", 1)
+		Expect(cache.len()).To(Equal(0))
+	})
+})
@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	constants "github.com/cybozu-go/meows"
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/cybozu-go/meows/github"
+	"github.com/cybozu-go/meows/metrics"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RunnerGarbageCollector removes the offline runners whose RunnerPools do not exist.
+// Such runners are left when a RunnerPool is renamed or the controller crashes while deleting it,
+// and they are not removed by the RunnerManager because they do not have the labels of the running RunnerPools.
+// Only the runners with constants.ManagedRunnerLabel are removed unless includeLegacy is true.
+//
+// The runners are looked for in the organizations and the repositories of the running RunnerPools,
+// and removed after they are found orphaned for the grace period.
+// The targets are recorded in a configmap in the controller namespace with their credential secrets,
+// so that they are still looked for after their last RunnerPools are deleted or the controller restarts.
+// A target is forgotten when none of the runners of the deleted RunnerPools is left.
+type RunnerGarbageCollector struct {
+	k8sClient client.Client
+	// apiReader reads the configmap without the cache, because the controller is not allowed to watch configmaps in all namespaces.
+	apiReader      client.Reader
+	log            logr.Logger
+	runnerManager  RunnerManager
+	githubFactory  github.ClientFactory
+	defaultNetwork github.NetworkConfig
+	namespace      string
+	interval       time.Duration
+	gracePeriod    time.Duration
+	// includeLegacy makes the runners without constants.ManagedRunnerLabel removed as well.
+	// They are registered by the older versions of meows.
+	includeLegacy bool
+
+	// orphanedSince is the time when each runner was found orphaned first.
+	// The key is the target and the ID of the runner.
+	orphanedSince map[string]time.Time
+}
+
+// NewRunnerGarbageCollector creates RunnerGarbageCollector
+func NewRunnerGarbageCollector(
+	log logr.Logger,
+	k8sClient client.Client,
+	apiReader client.Reader,
+	runnerManager RunnerManager,
+	githubFactory github.ClientFactory,
+	defaultNetwork github.NetworkConfig,
+	namespace string,
+	interval, gracePeriod time.Duration,
+	includeLegacy bool,
+) *RunnerGarbageCollector {
+	return &RunnerGarbageCollector{
+		k8sClient:      k8sClient,
+		apiReader:      apiReader,
+		log:            log.WithName("RunnerGarbageCollector"),
+		runnerManager:  runnerManager,
+		githubFactory:  githubFactory,
+		defaultNetwork: defaultNetwork,
+		namespace:      namespace,
+		interval:       interval,
+		gracePeriod:    gracePeriod,
+		includeLegacy:  includeLegacy,
+		orphanedSince:  map[string]time.Time{},
+	}
+}
+
+// gcTarget is a record of the target of the runner garbage collector.
+type gcTarget struct {
+	Owner           string `json:"owner"`
+	Repo            string `json:"repo,omitempty"`
+	SecretNamespace string `json:"secretNamespace"`
+	SecretName      string `json:"secretName"`
+}
+
+func (t gcTarget) String() string {
+	return RunnerTarget{Owner: t.Owner, Repo: t.Repo}.String()
+}
+
+// Start runs the garbage collection periodically until the context is canceled.
+// It implements manager.Runnable, so it runs only in the leader.
+func (gc *RunnerGarbageCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(gc.interval)
+	defer ticker.Stop()
+
+	gc.log.Info("start the runner garbage collector", "interval", gc.interval, "grace_period", gc.gracePeriod, "include_legacy", gc.includeLegacy)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := gc.collect(ctx, time.Now()); err != nil {
+				gc.log.Error(err, "failed to collect orphaned runners")
+			}
+		}
+	}
+}
+
+func (gc *RunnerGarbageCollector) collect(ctx context.Context, now time.Time) error {
+	rpList := &meowsv1alpha1.RunnerPoolList{}
+	if err := gc.k8sClient.List(ctx, rpList); err != nil {
+		return fmt.Errorf("failed to list RunnerPools; %w", err)
+	}
+	existing := map[types.NamespacedName]bool{}
+	for i := range rpList.Items {
+		rp := &rpList.Items[i]
+		existing[types.NamespacedName{Namespace: rp.Namespace, Name: rp.Name}] = true
+	}
+
+	cm, records, err := gc.loadTargets(ctx)
+	if err != nil {
+		return err
+	}
+	changed := false
+
+	var errs []error
+	found := map[string]bool{}
+	running := map[string]bool{}
+	for _, t := range gc.runnerManager.Targets() {
+		running[t.String()] = true
+		record := gcTarget{
+			Owner:           t.Owner,
+			Repo:            t.Repo,
+			SecretNamespace: t.CredentialSecret.Namespace,
+			SecretName:      t.CredentialSecret.Name,
+		}
+		if records[t.String()] != record {
+			records[t.String()] = record
+			changed = true
+		}
+
+		runners, err := gc.runnerManager.ListRunners(ctx, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list runners in %s; %w", t, err))
+			continue
+		}
+		_, err = gc.removeOrphanedRunners(t.String(), runners, existing, now, found, func(runnerID int64) error {
+			return gc.runnerManager.RemoveRunner(ctx, t, runnerID)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if running[key] {
+			continue
+		}
+		left, err := gc.collectStoppedTarget(ctx, records[key], existing, now, found)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if !left {
+			gc.log.Info("forget the target", "target", key)
+			delete(records, key)
+			changed = true
+		}
+	}
+
+	// Forget the runners that are removed by others or come back online.
+	for key := range gc.orphanedSince {
+		if !found[key] {
+			delete(gc.orphanedSince, key)
+		}
+	}
+
+	if changed {
+		if err := gc.saveTargets(ctx, cm, records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectStoppedTarget removes the orphaned runners in the target which has no running RunnerPool.
+// It returns false when none of the runners of the deleted RunnerPools is left in the target.
+func (gc *RunnerGarbageCollector) collectStoppedTarget(ctx context.Context, record gcTarget, existing map[types.NamespacedName]bool, now time.Time, found map[string]bool) (bool, error) {
+	secretName := types.NamespacedName{Namespace: record.SecretNamespace, Name: record.SecretName}
+	s := &corev1.Secret{}
+	err := gc.k8sClient.Get(ctx, secretName, s)
+	if apierrors.IsNotFound(err) {
+		gc.log.Info("credential secret is not found; remove the orphaned runners by `meows runner gc`", "target", record.String(), "secret", secretName.String())
+		return false, nil
+	} else if err != nil {
+		return true, fmt.Errorf("failed to get credential secret of %s; %w", record, err)
+	}
+
+	cred, err := github.NewCredentialFromSecretData(s.Data)
+	if err != nil {
+		return true, fmt.Errorf("invalid credential secret of %s; %w", record, err)
+	}
+	cred.Network = gc.defaultNetwork.Merge(cred.Network)
+	if err := cred.Network.Validate(); err != nil {
+		return true, fmt.Errorf("invalid network configuration in credential secret of %s; %w", record, err)
+	}
+	githubClient, err := gc.githubFactory.New(cred)
+	if err != nil {
+		return true, fmt.Errorf("failed to create GitHub client for %s; %w", record, err)
+	}
+	defer gc.githubFactory.Release(cred)
+
+	runners, err := githubClient.ListRunners(ctx, record.Owner, record.Repo, nil)
+	if err != nil {
+		return true, fmt.Errorf("failed to list runners in %s; %w", record, err)
+	}
+	removed, err := gc.removeOrphanedRunners(record.String(), runners, existing, now, found, func(runnerID int64) error {
+		return githubClient.RemoveRunner(ctx, record.Owner, record.Repo, runnerID)
+	})
+
+	// The online runners are counted as well, because they will be orphaned when they go offline.
+	for _, r := range runners {
+		if removed[r.ID] {
+			continue
+		}
+		if owned, alive := isRunnerOfRunnerPool(r, existing, gc.includeLegacy); owned && !alive {
+			return true, err
+		}
+	}
+	return false, err
+}
+
+// removeOrphanedRunners removes the runners orphaned for the grace period by remove, and returns the IDs of the removed runners.
+func (gc *RunnerGarbageCollector) removeOrphanedRunners(target string, runners []*github.Runner, existing map[types.NamespacedName]bool, now time.Time, found map[string]bool, remove func(runnerID int64) error) (map[int64]bool, error) {
+	var errs []error
+	removed := map[int64]bool{}
+	for _, r := range FindOrphanedRunners(runners, existing, gc.includeLegacy) {
+		key := target + ":" + strconv.FormatInt(r.ID, 10)
+		found[key] = true
+		since, ok := gc.orphanedSince[key]
+		if !ok {
+			gc.orphanedSince[key] = now
+			gc.log.Info("found an orphaned runner", "target", target, "runner", r.Name, "runner_id", r.ID, "labels", r.Labels)
+			since = now
+		}
+		if now.Sub(since) < gc.gracePeriod {
+			continue
+		}
+
+		err := remove(r.ID)
+		if err != nil && !errors.Is(err, github.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("failed to remove runner %s (id: %d) in %s; %w", r.Name, r.ID, target, err))
+			continue
+		}
+		removed[r.ID] = true
+		delete(gc.orphanedSince, key)
+		metrics.IncrementOrphanedRunnerRemoved()
+		gc.log.Info("removed an orphaned runner", "target", target, "runner", r.Name, "runner_id", r.ID, "labels", r.Labels)
+	}
+	return removed, errors.Join(errs...)
+}
+
+func (gc *RunnerGarbageCollector) loadTargets(ctx context.Context) (*corev1.ConfigMap, map[string]gcTarget, error) {
+	records := map[string]gcTarget{}
+	cm := &corev1.ConfigMap{}
+	err := gc.apiReader.Get(ctx, types.NamespacedName{Namespace: gc.namespace, Name: constants.RunnerGCTargetsConfigMapName}, cm)
+	if apierrors.IsNotFound(err) {
+		return nil, records, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to get configmap; %w", err)
+	}
+
+	data := cm.Data[constants.RunnerGCTargetsConfigMapDataTargets]
+	if data == "" {
+		return cm, records, nil
+	}
+	var targets []gcTarget
+	if err := json.Unmarshal([]byte(data), &targets); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s key; %w", constants.RunnerGCTargetsConfigMapDataTargets, err)
+	}
+	for _, t := range targets {
+		records[t.String()] = t
+	}
+	return cm, records, nil
+}
+
+func (gc *RunnerGarbageCollector) saveTargets(ctx context.Context, cm *corev1.ConfigMap, records map[string]gcTarget) error {
+	targets := make([]gcTarget, 0, len(records))
+	for _, t := range records {
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].String() < targets[j].String()
+	})
+	data, err := json.Marshal(targets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal targets; %w", err)
+	}
+
+	if cm == nil {
+		cm = &corev1.ConfigMap{}
+		cm.Namespace = gc.namespace
+		cm.Name = constants.RunnerGCTargetsConfigMapName
+		cm.Data = map[string]string{constants.RunnerGCTargetsConfigMapDataTargets: string(data)}
+		if err := gc.k8sClient.Create(ctx, cm); err != nil {
+			return fmt.Errorf("failed to create configmap; %w", err)
+		}
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[constants.RunnerGCTargetsConfigMapDataTargets] = string(data)
+	if err := gc.k8sClient.Update(ctx, cm); err != nil {
+		return fmt.Errorf("failed to update configmap; %w", err)
+	}
+	return nil
+}
+
+// FindOrphanedRunners returns the offline runners that have the labels of RunnerPools and none of those RunnerPools exist.
+// Only the runners with constants.ManagedRunnerLabel are returned, because other tools may give labels in the form of `NAMESPACE/NAME` as well.
+// If includeLegacy is true, the runners without constants.ManagedRunnerLabel, which are registered by the older versions of meows, are returned as well.
+func FindOrphanedRunners(runners []*github.Runner, existing map[types.NamespacedName]bool, includeLegacy bool) []*github.Runner {
+	var orphaned []*github.Runner
+	for _, r := range runners {
+		if r.Online {
+			continue
+		}
+		if owned, alive := isRunnerOfRunnerPool(r, existing, includeLegacy); owned && !alive {
+			orphaned = append(orphaned, r)
+		}
+	}
+	return orphaned
+}
+
+// isRunnerOfRunnerPool returns whether the runner is registered by meows for RunnerPools, and whether any of them exists.
+func isRunnerOfRunnerPool(r *github.Runner, existing map[types.NamespacedName]bool, includeLegacy bool) (owned, alive bool) {
+	managed := includeLegacy
+	for _, l := range r.Labels {
+		if l == constants.ManagedRunnerLabel {
+			managed = true
+			continue
+		}
+		rpName, ok := meowsv1alpha1.ParseRunnerLabel(l)
+		if !ok {
+			continue
+		}
+		owned = true
+		if existing[rpName] {
+			alive = true
+		}
+	}
+	return managed && owned, alive
+}
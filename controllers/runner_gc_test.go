@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	constants "github.com/cybozu-go/meows"
+	"github.com/cybozu-go/meows/github"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// runnerTargetsStub is a RunnerManager that only returns the targets.
+type runnerTargetsStub struct {
+	RunnerManager
+	targets []RunnerTarget
+}
+
+func (s *runnerTargetsStub) Targets() []RunnerTarget {
+	return s.targets
+}
+
+func (s *runnerTargetsStub) ListRunners(ctx context.Context, t RunnerTarget) ([]*github.Runner, error) {
+	return t.GitHubClient.ListRunners(ctx, t.Owner, t.Repo, nil)
+}
+
+func (s *runnerTargetsStub) RemoveRunner(ctx context.Context, t RunnerTarget, runnerID int64) error {
+	return t.GitHubClient.RemoveRunner(ctx, t.Owner, t.Repo, runnerID)
+}
+
+var _ = Describe("RunnerGarbageCollector", func() {
+	namespace := "runnergc-ns"
+	gcNamespace1 := "runnergc-system1"
+	gcNamespace2 := "runnergc-system2"
+	ctx := context.Background()
+
+	credentialSecret := types.NamespacedName{Namespace: namespace, Name: constants.DefaultCredentialSecretName}
+	getRecordedTargets := func(gcNamespace string) []string {
+		cm := &corev1.ConfigMap{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: gcNamespace, Name: constants.RunnerGCTargetsConfigMapName}, cm)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		Expect(err).NotTo(HaveOccurred())
+		var targets []gcTarget
+		Expect(json.Unmarshal([]byte(cm.Data[constants.RunnerGCTargetsConfigMapDataTargets]), &targets)).To(Succeed())
+		var ret []string
+		for _, t := range targets {
+			Expect(t.SecretNamespace).To(Equal(credentialSecret.Namespace))
+			Expect(t.SecretName).To(Equal(credentialSecret.Name))
+			ret = append(ret, t.String())
+		}
+		return ret
+	}
+
+	It("should create namespace", func() {
+		createNamespaces(ctx, namespace, gcNamespace1, gcNamespace2)
+	})
+
+	It("should remove orphaned runners after the grace period", func() {
+		By("creating a RunnerPool")
+		Expect(k8sClient.Create(ctx, makeRunnerPoolWithRepository("rp1", namespace, "owner/repo"))).To(Succeed())
+
+		githubClientFactory := github.NewFakeClientFactory()
+		githubClient, err := githubClientFactory.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		setRunners := func(orphan2Online bool) {
+			githubClientFactory.SetRunners(map[string][]*github.Runner{
+				"owner/repo": {
+					{ID: 1, Name: "alive", Labels: []string{"self-hosted", namespace + "/rp1", constants.ManagedRunnerLabel}},
+					{ID: 2, Name: "orphan1", Labels: []string{"self-hosted", namespace + "/deleted", constants.ManagedRunnerLabel}},
+					{ID: 3, Name: "orphan2", Labels: []string{"other-ns/rp1", constants.ManagedRunnerLabel}, Online: orphan2Online},
+					{ID: 4, Name: "online", Labels: []string{namespace + "/deleted", constants.ManagedRunnerLabel}, Online: true},
+					{ID: 5, Name: "unmanaged", Labels: []string{"self-hosted", "linux/amd64"}},
+				},
+			})
+		}
+		listRunnerNames := func() []string {
+			runners, err := githubClient.ListRunners(ctx, "owner", "repo", nil)
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, r := range runners {
+				names = append(names, r.Name)
+			}
+			return names
+		}
+
+		stub := &runnerTargetsStub{targets: []RunnerTarget{{Owner: "owner", Repo: "repo", GitHubClient: githubClient, CredentialSecret: credentialSecret}}}
+		gc := NewRunnerGarbageCollector(ctrl.Log, k8sClient, k8sClient, stub, githubClientFactory, github.NetworkConfig{}, gcNamespace1, time.Minute, time.Hour, false)
+		now := time.Now()
+
+		By("finding the orphaned runners")
+		setRunners(false)
+		Expect(gc.collect(ctx, now)).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("alive", "orphan1", "orphan2", "online", "unmanaged"))
+		Expect(gc.orphanedSince).To(HaveLen(2))
+		Expect(getRecordedTargets(gcNamespace1)).To(ConsistOf("owner/repo"))
+
+		By("forgetting the runner that comes back online")
+		setRunners(true)
+		Expect(gc.collect(ctx, now.Add(30*time.Minute))).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("alive", "orphan1", "orphan2", "online", "unmanaged"))
+		Expect(gc.orphanedSince).To(HaveLen(1))
+
+		By("removing the runner orphaned for the grace period")
+		setRunners(false)
+		Expect(gc.collect(ctx, now.Add(time.Hour))).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("alive", "orphan2", "online", "unmanaged"))
+		Expect(gc.orphanedSince).To(HaveLen(1))
+
+		By("removing the other runner after its own grace period")
+		Expect(gc.collect(ctx, now.Add(2*time.Hour))).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("alive", "online", "unmanaged"))
+		Expect(gc.orphanedSince).To(BeEmpty())
+	})
+
+	It("should remove orphaned runners after the last RunnerPool of the repository is deleted", func() {
+		By("creating a RunnerPool and its credential secret")
+		rp := makeRunnerPoolWithRepository("rp2", namespace, "owner/repo2")
+		rp.Finalizers = nil
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+		secret := &corev1.Secret{}
+		secret.Namespace = credentialSecret.Namespace
+		secret.Name = credentialSecret.Name
+		secret.Data = map[string][]byte{constants.CredentialSecretDataPATToken: []byte("dummy")}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+		githubClientFactory := github.NewFakeClientFactory()
+		githubClient, err := githubClientFactory.New(nil)
+		Expect(err).NotTo(HaveOccurred())
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo2": {
+				{ID: 1, Name: "runner", Labels: []string{"self-hosted", namespace + "/rp2", constants.ManagedRunnerLabel}},
+				{ID: 2, Name: "unmanaged", Labels: []string{"self-hosted", "linux/amd64"}},
+			},
+		})
+		listRunnerNames := func() []string {
+			runners, err := githubClient.ListRunners(ctx, "owner", "repo2", nil)
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, r := range runners {
+				names = append(names, r.Name)
+			}
+			return names
+		}
+
+		stub := &runnerTargetsStub{targets: []RunnerTarget{{Owner: "owner", Repo: "repo2", GitHubClient: githubClient, CredentialSecret: credentialSecret}}}
+		gc := NewRunnerGarbageCollector(ctrl.Log, k8sClient, k8sClient, stub, githubClientFactory, github.NetworkConfig{}, gcNamespace2, time.Minute, time.Hour, false)
+		now := time.Now()
+
+		By("recording the target of the running RunnerPool")
+		Expect(gc.collect(ctx, now)).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("runner", "unmanaged"))
+		Expect(gc.orphanedSince).To(BeEmpty())
+		Expect(getRecordedTargets(gcNamespace2)).To(ConsistOf("owner/repo2"))
+
+		By("deleting the RunnerPool and stopping its runner manager")
+		Expect(k8sClient.Delete(ctx, rp)).To(Succeed())
+		stub.targets = nil
+
+		By("finding the orphaned runner in the recorded target")
+		Expect(gc.collect(ctx, now.Add(time.Minute))).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("runner", "unmanaged"))
+		Expect(gc.orphanedSince).To(HaveLen(1))
+		Expect(getRecordedTargets(gcNamespace2)).To(ConsistOf("owner/repo2"))
+
+		By("removing the runner after the grace period and forgetting the target")
+		Expect(gc.collect(ctx, now.Add(time.Minute+time.Hour))).To(Succeed())
+		Expect(listRunnerNames()).To(ConsistOf("unmanaged"))
+		Expect(gc.orphanedSince).To(BeEmpty())
+		Expect(getRecordedTargets(gcNamespace2)).To(BeEmpty())
+	})
+
+	It("should find orphaned runners", func() {
+		existing := map[types.NamespacedName]bool{
+			{Namespace: "ns", Name: "rp1"}: true,
+		}
+		runners := []*github.Runner{
+			{ID: 1, Name: "alive", Labels: []string{"self-hosted", "ns/rp1", constants.ManagedRunnerLabel}},
+			{ID: 2, Name: "orphaned", Labels: []string{"self-hosted", "ns/deleted", constants.ManagedRunnerLabel}},
+			{ID: 3, Name: "online", Labels: []string{"ns/deleted", constants.ManagedRunnerLabel}, Online: true},
+			{ID: 4, Name: "unmanaged", Labels: []string{"self-hosted", "linux/amd64"}},
+			{ID: 5, Name: "no-runnerpool", Labels: []string{"self-hosted", constants.ManagedRunnerLabel}},
+			{ID: 6, Name: "invalid-label", Labels: []string{"Custom/Label", constants.ManagedRunnerLabel}},
+			{ID: 7, Name: "legacy", Labels: []string{"self-hosted", "ns/deleted"}},
+			{ID: 8, Name: "legacy-alive", Labels: []string{"self-hosted", "ns/rp1"}},
+		}
+		names := func(runners []*github.Runner) []string {
+			var ret []string
+			for _, r := range runners {
+				ret = append(ret, r.Name)
+			}
+			return ret
+		}
+
+		Expect(names(FindOrphanedRunners(runners, existing, false))).To(ConsistOf("orphaned"))
+		Expect(names(FindOrphanedRunners(runners, existing, true))).To(ConsistOf("orphaned", "unmanaged", "legacy"))
+	})
+})
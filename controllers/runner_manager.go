@@ -2,8 +2,13 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -23,41 +28,111 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete;update
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete;update;patch
+//+kubebuilder:rbac:groups="",resources=podtemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=meows.cybozu.com,resources=runnerjobs,verbs=get;list;watch;create
+
+// jitRunnerDefaultLabels are the labels that config.sh gives to runners by default.
+// Just-in-time runners are given them explicitly so that the same workflows can use both types of runners.
+var jitRunnerDefaultLabels = []string{"self-hosted", "Linux", "X64"}
+
+// The backoff to recreate runner pods whose setup command failed.
+const (
+	setupFailureBaseBackoff = 10 * time.Second
+	setupFailureMaxBackoff  = 5 * time.Minute
+)
+
+// notificationRetryPeriod is the period to keep a finished runner pod to retry the notification of the job result.
+const notificationRetryPeriod = 10 * time.Minute
+
+// drainTaintKeys are the keys of the taints which are added to the nodes to be drained.
+var drainTaintKeys = []string{
+	corev1.TaintNodeUnschedulable,
+	corev1.TaintNodeOutOfService,
+	"ToBeDeletedByClusterAutoscaler",
+}
+
+// removeRunnersTimeout is the time limit to remove the runners of a RunnerPool when the process stops.
+const removeRunnersTimeout = 30 * time.Second
 
 // RunnerManager manages runner pods and runners registered in GitHub.
 // It generates one goroutine for each RunnerPool CR to manage them.
 type RunnerManager interface {
 	StartOrUpdate(*meowsv1alpha1.RunnerPool, *github.ClientCredential) error
+	// Drain makes the goroutine for the deleted RunnerPool keep the busy runner pods until they finish their jobs.
+	// It returns the names of the busy runners observed after the draining started.
+	// observed is false until the goroutine checks the runners in the draining mode.
+	Drain(*meowsv1alpha1.RunnerPool, *github.ClientCredential) (busyRunners []string, observed bool, err error)
+	// Stop stops the goroutine and removes all runners of the RunnerPool from GitHub.
+	// If removing the runners fails, it returns an error and the removal is retried in the next call.
 	Stop(*meowsv1alpha1.RunnerPool) error
-	StopAll()
+	// StopAll stops all goroutines and removes the runners of all RunnerPools from GitHub until ctx is done.
+	StopAll(ctx context.Context)
+	// Targets returns the organizations and the repositories where the running RunnerPools register their runners.
+	Targets() []RunnerTarget
+	// ListRunners returns all runners in the target. The runner list is shared with the RunnerPools for the target.
+	ListRunners(ctx context.Context, t RunnerTarget) ([]*github.Runner, error)
+	// RemoveRunner removes the runner from the target and from the shared runner list.
+	RemoveRunner(ctx context.Context, t RunnerTarget, runnerID int64) error
+}
+
+// RunnerTarget is an organization or a repository where runners are registered, and the client to access it.
+type RunnerTarget struct {
+	Owner        string
+	Repo         string
+	GitHubClient github.Client
+	// CredentialID identifies the credential of GitHubClient.
+	CredentialID string
+	// CredentialSecret is the credential secret of the RunnerPool which GitHubClient is created from.
+	CredentialSecret types.NamespacedName
+}
+
+// String returns the organization name or the repository name in the form of `OWNER/REPO`.
+func (t RunnerTarget) String() string {
+	if t.Repo == "" {
+		return t.Owner
+	}
+	return t.Owner + "/" + t.Repo
 }
 
 type runnerManager struct {
 	log                 logr.Logger
 	k8sClient           client.Client
 	scheme              *runtime.Scheme
+	recorder            record.EventRecorder
 	githubClientFactory github.ClientFactory
 	runnerPodClient     runner.Client
 	interval            time.Duration
+	runnerCache         *runnerCache
+	startedAt           time.Time
 	mu                  sync.Mutex
 	stopped             bool
 	processes           map[string]*manageProcess
+	// githubCreds are the credentials used by the processes. They are released to the factory when the processes stop.
+	githubCreds map[string]*github.ClientCredential
 }
 
-func NewRunnerManager(log logr.Logger, k8sClient client.Client, scheme *runtime.Scheme, githubClientFactory github.ClientFactory, runnerPodClient runner.Client, interval time.Duration) RunnerManager {
+func NewRunnerManager(log logr.Logger, k8sClient client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, githubClientFactory github.ClientFactory, runnerPodClient runner.Client, interval time.Duration) RunnerManager {
 	return &runnerManager{
 		log:                 log.WithName("RunnerManager"),
 		k8sClient:           k8sClient,
 		scheme:              scheme,
+		recorder:            recorder,
 		githubClientFactory: githubClientFactory,
 		runnerPodClient:     runnerPodClient,
 		interval:            interval,
-		processes:           map[string]*manageProcess{},
+		// Processes tick at different times. Keep the cached runners fresh enough for the process that fetched them.
+		runnerCache: newRunnerCache(interval / 2),
+		startedAt:   time.Now().UTC(),
+		processes:   map[string]*manageProcess{},
+		githubCreds: map[string]*github.ClientCredential{},
 	}
 }
 
@@ -65,6 +140,10 @@ func (m *runnerManager) StartOrUpdate(rp *meowsv1alpha1.RunnerPool, cred *github
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	return m.startOrUpdate(rp, cred)
+}
+
+func (m *runnerManager) startOrUpdate(rp *meowsv1alpha1.RunnerPool, cred *github.ClientCredential) error {
 	if m.stopped {
 		return errors.New("RunnerManager is already stopped")
 	}
@@ -79,57 +158,157 @@ func (m *runnerManager) StartOrUpdate(rp *meowsv1alpha1.RunnerPool, cred *github
 			m.log.WithValues("runnerpool", rpNamespacedName),
 			m.k8sClient,
 			m.scheme,
+			m.recorder,
 			githubClient,
+			m.runnerCache,
+			cred.Identity(),
 			m.runnerPodClient,
 			m.interval,
+			m.startedAt,
 			rp,
 		)
 		if err != nil {
+			m.githubClientFactory.Release(cred)
 			return err
 		}
 		process.start()
+		m.runnerCache.acquire(process.credentialID, process.owner, process.repo)
 		m.processes[rpNamespacedName] = process
+		m.githubCreds[rpNamespacedName] = cred
 		return nil
 	}
 	return m.processes[rpNamespacedName].update(rp)
 }
 
-func (m *runnerManager) Stop(rp *meowsv1alpha1.RunnerPool) error {
+func (m *runnerManager) Drain(rp *meowsv1alpha1.RunnerPool, cred *github.ClientCredential) ([]string, bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// The process may not be running if the controller restarted while draining.
+	if err := m.startOrUpdate(rp, cred); err != nil {
+		return nil, false, err
+	}
 	rpNamespacedName := types.NamespacedName{Namespace: rp.Namespace, Name: rp.Name}.String()
-	if process, ok := m.processes[rpNamespacedName]; ok {
-		if err := process.stop(); err != nil {
-			return err
-		}
-		delete(m.processes, rpNamespacedName)
+	busyRunners, observed := m.processes[rpNamespacedName].drain()
+	return busyRunners, observed, nil
+}
+
+func (m *runnerManager) Stop(rp *meowsv1alpha1.RunnerPool) error {
+	rpNamespacedName := types.NamespacedName{Namespace: rp.Namespace, Name: rp.Name}.String()
+	m.mu.Lock()
+	process, ok := m.processes[rpNamespacedName]
+	if !ok {
+		m.mu.Unlock()
+		return nil
 	}
+	if err := process.stop(); err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	m.mu.Unlock()
+
+	// Remove the runners without the lock not to block the other RunnerPools.
+	ctx, cancel := context.WithTimeout(context.Background(), removeRunnersTimeout)
+	defer cancel()
+	if err := process.deleteAllRunners(ctx); err != nil {
+		// Keep the stopped process to retry removing the runners.
+		return fmt.Errorf("failed to remove runners; %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// The process may be released by StopAll while removing the runners.
+	if m.processes[rpNamespacedName] != process {
+		return nil
+	}
+	delete(m.processes, rpNamespacedName)
+	m.runnerCache.release(process.credentialID, process.owner, process.repo)
+	m.githubClientFactory.Release(m.githubCreds[rpNamespacedName])
+	delete(m.githubCreds, rpNamespacedName)
 	return nil
 }
 
-func (m *runnerManager) StopAll() {
+func (m *runnerManager) StopAll(ctx context.Context) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for _, process := range m.processes {
 		process.stop()
 	}
+	// Remove the runners of the RunnerPools in parallel so that all of them share the time limit.
+	var wg sync.WaitGroup
+	for _, process := range m.processes {
+		wg.Add(1)
+		go func(process *manageProcess) {
+			defer wg.Done()
+			process.deleteAllRunners(ctx)
+		}(process)
+	}
+	wg.Wait()
+	for rpNamespacedName, process := range m.processes {
+		m.runnerCache.release(process.credentialID, process.owner, process.repo)
+		m.githubClientFactory.Release(m.githubCreds[rpNamespacedName])
+	}
 	m.processes = nil
+	m.githubCreds = nil
 	m.stopped = true
 }
 
+func (m *runnerManager) Targets() []RunnerTarget {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The RunnerPools for the same target may use different credentials. Any of them can be used.
+	var targets []RunnerTarget
+	seen := map[string]bool{}
+	for _, process := range m.processes {
+		t := RunnerTarget{
+			Owner:            process.owner,
+			Repo:             process.repo,
+			GitHubClient:     process.githubClient,
+			CredentialID:     process.credentialID,
+			CredentialSecret: process.credentialSecret,
+		}
+		if seen[t.String()] {
+			continue
+		}
+		seen[t.String()] = true
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].String() < targets[j].String() })
+	return targets
+}
+
+func (m *runnerManager) ListRunners(ctx context.Context, t RunnerTarget) ([]*github.Runner, error) {
+	return m.runnerCache.listRunners(ctx, t.GitHubClient, t.CredentialID, t.Owner, t.Repo, nil)
+}
+
+func (m *runnerManager) RemoveRunner(ctx context.Context, t RunnerTarget, runnerID int64) error {
+	err := t.GitHubClient.RemoveRunner(ctx, t.Owner, t.Repo, runnerID)
+	if err != nil && !errors.Is(err, github.ErrNotFound) {
+		return err
+	}
+	m.runnerCache.removeRunner(t.CredentialID, t.Owner, t.Repo, runnerID)
+	return err
+}
+
 type manageProcess struct {
 	// Given from outside. Not update internally.
 	log                   logr.Logger
 	k8sClient             client.Client
 	scheme                *runtime.Scheme
+	recorder              record.EventRecorder
 	githubClient          github.Client
+	runnerCache           *runnerCache
+	credentialID          string
+	credentialSecret      types.NamespacedName
 	runnerPodClient       runner.Client
 	slackAgentClient      *agent.Client
 	interval              time.Duration
+	managerStartedAt      time.Time // The jobs finished before this time may have been notified by the previous controller.
 	rpNamespace           string
 	rpName                string
+	rpUID                 types.UID
 	owner                 string
 	repo                  string
 	replicas              int32 // This field will be accessed from multiple goroutines. So use mutex to access.
@@ -139,20 +318,39 @@ type manageProcess struct {
 	slackAgentServiceName string
 	extendDuration        time.Duration
 	recreateDeadline      time.Duration
+	registrationTimeout   time.Duration
+	maxJobDuration        time.Duration
+	offlineGracePeriod    time.Duration
+	jobHistoryTTL         time.Duration
 	denyDisruption        bool
+	disruptionProtection  meowsv1alpha1.DisruptionProtectionMode
+	podManagement         meowsv1alpha1.PodManagementPolicy
 
 	// Update internally.
 	lastCheckTime   time.Time
 	env             *well.Environment
 	cancel          context.CancelFunc
 	prevRunnerNames []string
-	mu              sync.Mutex
-	deleteMetrics   func()
+	// setupFailures is the number of the pods recreated because of the setup failures since a pod ran successfully.
+	setupFailures          int
+	lastSetupFailureDelete time.Time
+	offlineRunnersSince    map[int64]time.Time // The time when each runner was found offline without a runner pod first.
+	expectations           *podExpectations
+	blockingPods           map[string]bool // The names of the runner pods blocking the drain of nodes in the last check.
+	draining               bool            // This field will be accessed from multiple goroutines. So use mutex to access.
+	drainObserved          bool            // This field will be accessed from multiple goroutines. So use mutex to access.
+	busyRunnerNames        []string        // This field will be accessed from multiple goroutines. So use mutex to access.
+	mu                     sync.Mutex
+	deleteMetrics          func()
 }
 
-func newManageProcess(log logr.Logger, k8sClient client.Client, scheme *runtime.Scheme, githubClient github.Client, runnerPodClient runner.Client, interval time.Duration, rp *meowsv1alpha1.RunnerPool) (*manageProcess, error) {
+func newManageProcess(log logr.Logger, k8sClient client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, githubClient github.Client, runnerCache *runnerCache, credentialID string, runnerPodClient runner.Client, interval time.Duration, managerStartedAt time.Time, rp *meowsv1alpha1.RunnerPool) (*manageProcess, error) {
 	extendDuration, _ := time.ParseDuration(rp.Spec.Notification.ExtendDuration)
 	recreateDeadline, _ := time.ParseDuration(rp.Spec.RecreateDeadline)
+	registrationTimeout, _ := time.ParseDuration(rp.Spec.RegistrationTimeout)
+	maxJobDuration, _ := time.ParseDuration(rp.Spec.MaxJobDuration)
+	offlineGracePeriod, _ := time.ParseDuration(rp.Spec.OfflineRunnerGracePeriod)
+	jobHistoryTTL, _ := time.ParseDuration(rp.Spec.JobHistoryTTL)
 
 	agentName := constants.DefaultSlackAgentServiceName
 	if rp.Spec.Notification.Slack.AgentServiceName != "" {
@@ -168,11 +366,18 @@ func newManageProcess(log logr.Logger, k8sClient client.Client, scheme *runtime.
 		log:                   log,
 		k8sClient:             k8sClient,
 		scheme:                scheme,
+		recorder:              recorder,
 		githubClient:          githubClient,
+		runnerCache:           runnerCache,
+		credentialID:          credentialID,
+		credentialSecret:      types.NamespacedName{Namespace: rp.Namespace, Name: rp.GetCredentialSecretName()},
 		runnerPodClient:       runnerPodClient,
 		interval:              interval,
+		managerStartedAt:      managerStartedAt,
+		lastCheckTime:         time.Now().UTC(),
 		rpNamespace:           rp.Namespace,
 		rpName:                rp.Name,
+		rpUID:                 rp.UID,
 		owner:                 rp.GetOwner(),
 		repo:                  rp.GetRepository(),
 		replicas:              rp.Spec.Replicas,
@@ -183,8 +388,15 @@ func newManageProcess(log logr.Logger, k8sClient client.Client, scheme *runtime.
 		slackAgentServiceName: agentName,
 		extendDuration:        extendDuration,
 		recreateDeadline:      recreateDeadline,
+		registrationTimeout:   registrationTimeout,
+		maxJobDuration:        maxJobDuration,
+		offlineGracePeriod:    offlineGracePeriod,
+		jobHistoryTTL:         jobHistoryTTL,
 		denyDisruption:        rp.Spec.DenyDisruption,
-		lastCheckTime:         time.Now().UTC(),
+		disruptionProtection:  rp.Spec.DisruptionProtection,
+		podManagement:         rp.Spec.PodManagement,
+		expectations:          newPodExpectations(),
+		offlineRunnersSince:   map[int64]time.Time{},
 		deleteMetrics: func() {
 			metrics.DeleteAllRunnerMetrics(rpNamespacedName)
 			metrics.DeleteRunnerPoolMetrics(rpNamespacedName)
@@ -205,7 +417,16 @@ func (p *manageProcess) update(rp *meowsv1alpha1.RunnerPool) error {
 	p.extendDuration = extendDuration
 	recreateDeadline, _ := time.ParseDuration(rp.Spec.RecreateDeadline)
 	p.recreateDeadline = recreateDeadline
+	registrationTimeout, _ := time.ParseDuration(rp.Spec.RegistrationTimeout)
+	p.registrationTimeout = registrationTimeout
+	maxJobDuration, _ := time.ParseDuration(rp.Spec.MaxJobDuration)
+	p.maxJobDuration = maxJobDuration
+	offlineGracePeriod, _ := time.ParseDuration(rp.Spec.OfflineRunnerGracePeriod)
+	p.offlineGracePeriod = offlineGracePeriod
+	jobHistoryTTL, _ := time.ParseDuration(rp.Spec.JobHistoryTTL)
+	p.jobHistoryTTL = jobHistoryTTL
 	p.denyDisruption = rp.Spec.DenyDisruption
+	p.disruptionProtection = rp.Spec.DisruptionProtection
 
 	agentName := constants.DefaultSlackAgentServiceName
 	if rp.Spec.Notification.Slack.AgentServiceName != "" {
@@ -223,6 +444,14 @@ func (p *manageProcess) update(rp *meowsv1alpha1.RunnerPool) error {
 
 }
 
+// drain turns on the draining mode, and returns the busy runners observed in the draining mode.
+func (p *manageProcess) drain() ([]string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draining = true
+	return p.busyRunnerNames, p.drainObserved
+}
+
 func (p *manageProcess) rpNamespacedName() string {
 	return p.rpNamespace + "/" + p.rpName
 }
@@ -234,10 +463,6 @@ func (p *manageProcess) start() {
 	p.env.Go(func(ctx context.Context) error {
 		p.run(ctx)
 		p.deleteMetrics()
-
-		ctx2, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		p.deleteAllRunners(ctx2)
 		return nil
 	})
 	p.env.Stop()
@@ -274,6 +499,10 @@ func (p *manageProcess) run(ctx context.Context) {
 }
 
 func (p *manageProcess) runOnce(ctx context.Context) error {
+	p.mu.Lock()
+	draining := p.draining
+	p.mu.Unlock()
+
 	podList, err := p.fetchRunnerPods(ctx)
 	if err != nil {
 		return err
@@ -284,10 +513,30 @@ func (p *manageProcess) runOnce(ctx context.Context) error {
 	}
 	p.updateMetrics(podList, runnerList)
 
-	err = p.maintainRunnerPods(ctx, runnerList, podList)
+	heldPods, err := p.maintainRunnerPods(ctx, runnerList, podList, draining)
 	if err != nil {
 		return err
 	}
+	if p.podManagement == meowsv1alpha1.PodManagementDirect {
+		err = p.scaleRunnerPods(ctx, podList, heldPods, draining)
+		if err != nil {
+			return err
+		}
+	}
+	if draining {
+		// The busy runner pods have been unlinked from the Deployment, so it is safe to scale it to zero.
+		// In the direct pod management, the busy runner pods are just kept.
+		var busyRunnerNames []string
+		for _, runner := range runnerList {
+			if runner.Busy {
+				busyRunnerNames = append(busyRunnerNames, runner.Name)
+			}
+		}
+		p.mu.Lock()
+		p.busyRunnerNames = busyRunnerNames
+		p.drainObserved = true
+		p.mu.Unlock()
+	}
 	err = p.deleteOfflineRunners(ctx, runnerList, podList)
 	if err != nil {
 		return err
@@ -323,7 +572,7 @@ func (p *manageProcess) fetchRunnerPods(ctx context.Context) (*corev1.PodList, e
 }
 
 func (p *manageProcess) fetchRunners(ctx context.Context) ([]*github.Runner, error) {
-	runnerList, err := p.githubClient.ListRunners(ctx, p.owner, p.repo, []string{p.rpNamespacedName()})
+	runnerList, err := p.runnerCache.listRunners(ctx, p.githubClient, p.credentialID, p.owner, p.repo, []string{p.rpNamespacedName()})
 	if err != nil {
 		p.log.Error(err, "failed to list runners")
 		return nil, err
@@ -364,17 +613,18 @@ func difference(prev, current []string) []string {
 	return ret
 }
 
-func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*github.Runner, podList *corev1.PodList) error {
+// maintainRunnerPods maintains the runner pods, and returns the names of the pods that have busy or debugging runners.
+func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*github.Runner, podList *corev1.PodList, draining bool) (map[string]bool, error) {
 	now := time.Now().UTC()
 	lastCheckTime := p.lastCheckTime
 	p.lastCheckTime = now
+	// In the direct pod management, the runner pods are not unlinked from anything.
+	unlinking := p.podManagement != meowsv1alpha1.PodManagementDirect
+	heldPods := map[string]bool{}
 
 	var numUnlabeledPods int32
-	for i := range podList.Items {
-		po := &podList.Items[i]
-		if _, ok := po.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; !ok {
-			numUnlabeledPods++
-		}
+	if unlinking {
+		numUnlabeledPods = p.adoptUnlinkedPods(ctx, podList)
 	}
 
 	p.mu.Lock()
@@ -382,25 +632,63 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 	slackChannel := p.slackChannel
 	extendDuration := p.extendDuration
 	recreateDeadline := p.recreateDeadline
-	numRemovablePods := p.maxRunnerPods - p.replicas - numUnlabeledPods // numRemovablePods can be a negative number.
+	registrationTimeout := p.registrationTimeout
+	maxJobDuration := p.maxJobDuration
+	jobHistoryTTL := p.jobHistoryTTL
+	denyDisruption := p.denyDisruption
+	disruptionProtection := p.disruptionProtection
+	maxRunnerPods := p.maxRunnerPods
+	numRemovablePods := p.maxRunnerPods - p.replicas - numUnlabeledPods
 	p.mu.Unlock()
+	if numRemovablePods < 0 && unlinking {
+		// This happens when maxRunnerPods is decreased while busy pods are unlinked.
+		// Busy pods are kept in the Deployment until the unlinked pods are deleted.
+		if maxRunnerPods != 0 {
+			p.log.Info("the number of unlinked pods exceeds the limit", "unlinked", numUnlabeledPods, "maxRunnerPods", maxRunnerPods)
+		}
+		numRemovablePods = 0
+	}
+	if draining {
+		// The Deployment will be scaled to zero. Unlink all busy runner pods to keep them.
+		numRemovablePods = int32(len(podList.Items))
+	}
+
+	drainingNodes := p.findDrainingNodes(ctx, podList)
+	blockingPods := map[string]string{}
 
 	for i := range podList.Items {
 		po := &podList.Items[i]
 		log := p.log.WithValues("pod", types.NamespacedName{Namespace: po.Namespace, Name: po.Name}.String())
+		onDrainingNode := drainingNodes[po.Spec.NodeName]
 		if po.Status.Phase != corev1.PodRunning {
+			if onDrainingNode && po.DeletionTimestamp == nil {
+				// The runner has not started yet, so the pod can be rescheduled to another node.
+				err := p.deletePod(ctx, po)
+				if err != nil && !apierrors.IsNotFound(err) {
+					log.Error(err, "failed to delete runner pod on draining node")
+				} else {
+					log.Info("deleted runner pod on draining node", "node", po.Spec.NodeName, "phase", po.Status.Phase)
+				}
+				continue
+			}
 			log.Info("skip because the status of the pod is not Running", "phase", po.Status.Phase)
 			continue
 		}
 
+		if runnerBusy(runnerList, po.Name) {
+			// Keep the pod even if the maintenance below is skipped.
+			heldPods[po.Name] = true
+		}
 		status, err := p.runnerPodClient.GetStatus(ctx, po.Status.PodIP)
 		if err != nil {
 			log.Error(err, "failed to get status, skipped maintaining runner pod")
+			// The runner may be running a job, so the pod is not deleted as an idle one.
+			heldPods[po.Name] = true
 			continue
 		}
 
 		if status.State == constants.RunnerPodStateStale {
-			err = p.k8sClient.Delete(ctx, po)
+			err = p.deletePod(ctx, po)
 			if err != nil && !apierrors.IsNotFound(err) {
 				log.Error(err, "failed to delete stale runner pod")
 			} else {
@@ -409,18 +697,70 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 			continue
 		}
 
+		if status.State == constants.RunnerPodStateSetupFailed {
+			p.recreateSetupFailedPod(ctx, log, po, status, now)
+			continue
+		}
+		if status.State == constants.RunnerPodStateRunning && po.CreationTimestamp.After(p.lastSetupFailureDelete) {
+			p.setupFailures = 0
+		}
+
+		if onDrainingNode && !runnerBusy(runnerList, po.Name) && status.State != constants.RunnerPodStateDebugging {
+			// Deregister the runner first so that no job is assigned to it after the pod is deleted.
+			if err := p.removeRunner(ctx, runnerList, po.Name); err != nil {
+				log.Error(err, "failed to remove idle runner on draining node")
+				continue
+			}
+			err = p.deletePod(ctx, po)
+			if err != nil && !apierrors.IsNotFound(err) {
+				log.Error(err, "failed to delete idle runner pod on draining node")
+			} else {
+				log.Info("deleted idle runner pod on draining node", "node", po.Spec.NodeName)
+			}
+			continue
+		}
+
+		if status.WaitingJITConfig {
+			err := p.giveJITConfig(ctx, po, runnerList)
+			if err != nil {
+				log.Error(err, "failed to give just-in-time configuration")
+			} else {
+				log.Info("gave just-in-time configuration")
+			}
+		}
+
 		if status.State == constants.RunnerPodStateDebugging {
 			needExtend := status.Extend != nil && *status.Extend && extendDuration != 0
 
-			if needNotification && status.FinishedAt.After(lastCheckTime) {
-				ch := slackChannel
-				if status.SlackChannel != "" {
-					ch = status.SlackChannel
+			if jobHistoryTTL != 0 {
+				err := p.recordRunnerJob(ctx, po, status, jobHistoryTTL)
+				if err != nil {
+					log.Error(err, "failed to record the job")
+				}
+			}
+
+			err := p.applyDeletionTimeAnnotations(ctx, po, status, extendDuration, now)
+			if err != nil {
+				log.Error(err, "failed to apply the deletion time given by the annotations")
+			}
+
+			notified := po.Annotations[constants.NotifiedAnnotationKey] != ""
+			switch {
+			case !needNotification || notified:
+			case status.FinishedAt != nil && status.FinishedAt.Before(p.managerStartedAt):
+				// The pods created by the older controllers do not have the annotation even if they were notified.
+				// Only record the notification so as not to send it again on the upgrade.
+				if err := p.markNotified(ctx, po); err != nil {
+					log.Error(err, "failed to record the notification of the job finished before the controller started")
+				} else {
+					notified = true
 				}
-				err := p.slackAgentClient.PostResult(ctx, ch, status.Result, needExtend, po.Namespace, po.Name, status.JobInfo)
+			default:
+				err := p.notify(ctx, po, status, slackChannel, needExtend)
 				if err != nil {
-					log.Error(err, "failed to send a notification to slack-agent")
+					log.Error(err, "failed to notify the job result; will retry")
 				} else {
+					notified = true
 					log.Info("sent a notification to slack-agent")
 				}
 			}
@@ -434,8 +774,13 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 			default:
 				needDelete = true
 			}
+			if needDelete && needNotification && !notified && status.FinishedAt != nil && now.Before(status.FinishedAt.Add(notificationRetryPeriod)) {
+				// The job result cannot be notified after the pod is deleted.
+				log.Info("keep debugging runner pod to retry the notification")
+				needDelete = false
+			}
 			if needDelete {
-				err := p.k8sClient.Delete(ctx, po)
+				err := p.deletePod(ctx, po)
 				if err != nil && !apierrors.IsNotFound(err) {
 					log.Error(err, "failed to delete debugging runner pod")
 				} else {
@@ -444,6 +789,7 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 				continue
 			}
 
+			// The deletion time is put only once after the job finishes, not in every check.
 			if status.DeletionTime == nil && status.FinishedAt != nil && status.FinishedAt.After(lastCheckTime) {
 				err := p.runnerPodClient.PutDeletionTime(ctx, po.Status.PodIP, status.FinishedAt.Add(extendDuration))
 				if err != nil {
@@ -452,9 +798,57 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 			}
 		}
 
+		if jobTimedOut(status, maxJobDuration, now) {
+			status.Result = runner.JobResultTimeout
+			status.FinishedAt = &now
+			if jobHistoryTTL != 0 {
+				err := p.recordRunnerJob(ctx, po, status, jobHistoryTTL)
+				if err != nil {
+					log.Error(err, "failed to record the job")
+				}
+			}
+
+			notified := po.Annotations[constants.NotifiedAnnotationKey] != ""
+			if needNotification && !notified {
+				err := p.notify(ctx, po, status, slackChannel, false)
+				if err != nil && now.Before(status.JobStartedAt.Add(maxJobDuration+notificationRetryPeriod)) {
+					// The job result cannot be notified after the pod is deleted.
+					log.Error(err, "failed to notify the job timeout; will retry")
+					heldPods[po.Name] = true
+					continue
+				}
+				if err != nil {
+					log.Error(err, "failed to notify the job timeout")
+				}
+			}
+
+			err = p.deletePod(ctx, po)
+			if err != nil && !apierrors.IsNotFound(err) {
+				log.Error(err, "failed to delete runner pod whose job exceeded the maximum duration")
+			} else {
+				log.Info("deleted runner pod whose job exceeded the maximum duration", "jobStartedAt", status.JobStartedAt)
+				p.recordRunnerPoolEvent(corev1.EventTypeWarning, "JobTimeout",
+					"Deleted runner pod %s because the job did not finish within %s", po.Name, maxJobDuration)
+			}
+			continue
+		}
+
+		if reason := registrationTimeoutReason(po, status, runnerList, registrationTimeout, now); reason != "" {
+			err = p.deletePod(ctx, po)
+			if err != nil && !apierrors.IsNotFound(err) {
+				log.Error(err, "failed to delete runner pod that exceeded registration timeout")
+			} else {
+				log.Info("deleted runner pod that exceeded registration timeout", "reason", reason)
+				metrics.IncrementRegistrationTimeout(p.rpNamespacedName(), reason)
+				p.recordRunnerPoolEvent(corev1.EventTypeWarning, "RegistrationTimeout",
+					"Deleted runner pod %s because the runner was not registered within %s (%s)", po.Name, registrationTimeout, reason)
+			}
+			continue
+		}
+
 		podRecreateTime := po.CreationTimestamp.Add(recreateDeadline)
 		if podRecreateTime.Before(now) && !(runnerBusy(runnerList, po.Name) || status.State == constants.RunnerPodStateDebugging) {
-			err = p.k8sClient.Delete(ctx, po)
+			err = p.deletePod(ctx, po)
 			if err != nil && !apierrors.IsNotFound(err) {
 				log.Error(err, "failed to delete runner pod that exceeded recreate deadline")
 			} else {
@@ -463,48 +857,33 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 			continue
 		}
 
+		held := runnerBusy(runnerList, po.Name) || status.State == constants.RunnerPodStateDebugging
+		if held {
+			heldPods[po.Name] = true
+		}
+		if err := p.updateDisruptionProtection(ctx, log, po, held && denyDisruption, disruptionProtection); err != nil {
+			log.Error(err, "failed to update disruption protection")
+			continue
+		}
+
 		// When a job is assigned, the runner pod will be removed from replicaset control.
-		if runnerBusy(runnerList, po.Name) || status.State == constants.RunnerPodStateDebugging {
-			if p.denyDisruption {
-				// if RunnerPool.spec.denyDisruption == true, protect the busy runner by a dedicated PDB.
-				if po.Labels[constants.RunnerPodName] != po.Name {
-					pdb := &policyv1.PodDisruptionBudget{}
-					pdb.SetName(po.Name)
-					pdb.SetNamespace(po.Namespace)
-					_, err := ctrl.CreateOrUpdate(ctx, p.k8sClient, pdb, func() error {
-						pdb.Spec.Selector = &metav1.LabelSelector{
-							MatchLabels: map[string]string{
-								constants.RunnerPodName: po.Name,
-							},
-						}
-						pdb.Spec.MinAvailable = &intstr.IntOrString{
-							Type:   intstr.Int,
-							IntVal: 1,
-						}
-						return ctrl.SetControllerReference(po, pdb, p.scheme)
-					})
-					if err != nil {
-						log.Error(err, "failed to create or update protection pdb")
-						continue
-					}
-					log.Info("created or updated protection pdb")
-					po.Labels[constants.RunnerPodName] = po.Name
-					err = p.k8sClient.Update(ctx, po)
-					if err != nil {
-						log.Error(err, "failed to relabel runner pod")
-						continue
-					}
-					log.Info("relabeled runner pod")
-				}
+		if held {
+			if onDrainingNode {
+				blockingPods[po.Name] = po.Spec.NodeName
 			}
 
-			if numRemovablePods <= 0 {
+			if !unlinking || numRemovablePods <= 0 {
 				continue
 			}
 			if _, ok := po.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; !ok {
 				continue
 			}
 			delete(po.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+			err = p.adoptPod(po)
+			if err != nil {
+				log.Error(err, "failed to set owner reference")
+				continue
+			}
 			err = p.k8sClient.Update(ctx, po)
 			if err != nil {
 				log.Error(err, "failed to unlink (update) runner pod")
@@ -514,9 +893,582 @@ func (p *manageProcess) maintainRunnerPods(ctx context.Context, runnerList []*gi
 			log.Info("unlinked (updated) runner pod")
 		}
 	}
+	p.reportBlockingPods(podList, blockingPods)
+	return heldPods, nil
+}
+
+// findDrainingNodes returns the set of the names of the cordoned or draining nodes where the runner pods are scheduled.
+func (p *manageProcess) findDrainingNodes(ctx context.Context, podList *corev1.PodList) map[string]bool {
+	drainingNodes := map[string]bool{}
+	checked := map[string]bool{}
+	for i := range podList.Items {
+		nodeName := podList.Items[i].Spec.NodeName
+		if nodeName == "" || checked[nodeName] {
+			continue
+		}
+		checked[nodeName] = true
+
+		node := &corev1.Node{}
+		if err := p.k8sClient.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+			if !apierrors.IsNotFound(err) {
+				p.log.Error(err, "failed to get node", "node", nodeName)
+			}
+			continue
+		}
+		if isNodeDraining(node) {
+			drainingNodes[nodeName] = true
+		}
+	}
+	return drainingNodes
+}
+
+// isNodeDraining returns true if the node is cordoned or tainted to be drained.
+func isNodeDraining(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		if slices.Contains(drainTaintKeys, taint.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportBlockingPods reports the busy or debugging runner pods on the draining nodes.
+// An event is recorded when a pod starts blocking the drain.
+func (p *manageProcess) reportBlockingPods(podList *corev1.PodList, blockingPods map[string]string) {
+	metrics.UpdateNodeDrainBlockingPods(p.rpNamespacedName(), len(blockingPods))
+	for i := range podList.Items {
+		po := &podList.Items[i]
+		nodeName, ok := blockingPods[po.Name]
+		if !ok || p.blockingPods[po.Name] {
+			continue
+		}
+		p.log.Info("runner pod is blocking the drain of node", "pod", po.Name, "node", nodeName)
+		p.recorder.Eventf(po, corev1.EventTypeWarning, "BlockingNodeDrain",
+			"Runner pod is blocking the drain of node %s because the runner is busy or being debugged", nodeName)
+	}
+
+	p.blockingPods = map[string]bool{}
+	for name := range blockingPods {
+		p.blockingPods[name] = true
+	}
+}
+
+// removeRunner removes the runner of the pod from GitHub if it is registered.
+func (p *manageProcess) removeRunner(ctx context.Context, runnerList []*github.Runner, name string) error {
+	for _, runner := range runnerList {
+		if runner.Name != name {
+			continue
+		}
+		err := p.githubClient.RemoveRunner(ctx, p.owner, p.repo, runner.ID)
+		if err != nil && !errors.Is(err, github.ErrNotFound) {
+			return err
+		}
+		p.runnerCache.removeRunner(p.credentialID, p.owner, p.repo, runner.ID)
+		p.log.Info("removed runner", "runner", runner.Name, "runner_id", runner.ID)
+	}
 	return nil
 }
 
+// updateDisruptionProtection protects the runner pod from disruptions by PDBs if protect is true.
+// Otherwise, it removes the protection given before.
+func (p *manageProcess) updateDisruptionProtection(ctx context.Context, log logr.Logger, po *corev1.Pod, protect bool, mode meowsv1alpha1.DisruptionProtectionMode) error {
+	protectByPool := protect && mode == meowsv1alpha1.DisruptionProtectionPool
+	protectByPod := protect && !protectByPool
+
+	if protectByPod && po.Labels[constants.RunnerPodName] != po.Name {
+		// Protect the busy runner by a dedicated PDB.
+		pdb := &policyv1.PodDisruptionBudget{}
+		pdb.SetName(po.Name)
+		pdb.SetNamespace(po.Namespace)
+		_, err := ctrl.CreateOrUpdate(ctx, p.k8sClient, pdb, func() error {
+			pdb.Spec.Selector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					constants.RunnerPodName: po.Name,
+				},
+			}
+			pdb.Spec.MinAvailable = &intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: 1,
+			}
+			return ctrl.SetControllerReference(po, pdb, p.scheme)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create or update protection pdb; %w", err)
+		}
+		log.Info("created or updated protection pdb")
+		po.Labels[constants.RunnerPodName] = po.Name
+		err = p.k8sClient.Update(ctx, po)
+		if err != nil {
+			return fmt.Errorf("failed to relabel runner pod; %w", err)
+		}
+		log.Info("relabeled runner pod")
+	}
+	if !protectByPod && po.Labels[constants.RunnerPodName] != "" {
+		pdb := &policyv1.PodDisruptionBudget{}
+		pdb.SetName(po.Name)
+		pdb.SetNamespace(po.Namespace)
+		err := p.k8sClient.Delete(ctx, pdb)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete protection pdb; %w", err)
+		}
+		log.Info("deleted protection pdb")
+		delete(po.Labels, constants.RunnerPodName)
+		err = p.k8sClient.Update(ctx, po)
+		if err != nil {
+			return fmt.Errorf("failed to unlabel runner pod; %w", err)
+		}
+		log.Info("unlabeled runner pod")
+	}
+
+	// The PDB for the RunnerPool is managed by the RunnerPool reconciler. Only the label is given here.
+	if protectByPool != (po.Labels[constants.BusyLabelKey] == "true") {
+		if protectByPool {
+			po.Labels[constants.BusyLabelKey] = "true"
+		} else {
+			delete(po.Labels, constants.BusyLabelKey)
+		}
+		err := p.k8sClient.Update(ctx, po)
+		if err != nil {
+			return fmt.Errorf("failed to update busy label; %w", err)
+		}
+		log.Info("updated busy label", "busy", protectByPool)
+	}
+	return nil
+}
+
+// adoptUnlinkedPods makes the RunnerPool the controller of the pods unlinked from the Deployment,
+// and returns the number of the unlinked pods.
+func (p *manageProcess) adoptUnlinkedPods(ctx context.Context, podList *corev1.PodList) int32 {
+	var numUnlabeledPods int32
+	for i := range podList.Items {
+		po := &podList.Items[i]
+		if _, ok := po.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+			continue
+		}
+		numUnlabeledPods++
+		if metav1.IsControlledBy(po, p.runnerPool()) {
+			continue
+		}
+		// The pod was unlinked before, but it is not controlled by the RunnerPool yet.
+		adopted := po.DeepCopy()
+		if err := p.adoptPod(adopted); err != nil {
+			p.log.Error(err, "failed to set owner reference", "pod", po.Name)
+			continue
+		}
+		if err := p.k8sClient.Update(ctx, adopted); err != nil {
+			p.log.Error(err, "failed to adopt unlinked runner pod", "pod", po.Name)
+			continue
+		}
+		*po = *adopted
+		p.log.Info("adopted unlinked runner pod", "pod", po.Name)
+	}
+	return numUnlabeledPods
+}
+
+// scaleRunnerPods creates and deletes the runner pods in the direct pod management.
+// It keeps the idle runner pods as many as replicas, as long as the total does not exceed maxRunnerPods.
+// The pods that have busy or debugging runners, and the pods whose status is unknown, are never deleted here.
+func (p *manageProcess) scaleRunnerPods(ctx context.Context, podList *corev1.PodList, heldPods map[string]bool, draining bool) error {
+	now := time.Now().UTC()
+	p.expectations.observe(podList, now)
+
+	pt := &corev1.PodTemplate{}
+	err := p.k8sClient.Get(ctx, types.NamespacedName{Namespace: p.rpNamespace, Name: p.runnerPool().GetRunnerPodTemplateName()}, pt)
+	if apierrors.IsNotFound(err) {
+		p.log.Info("skip scaling runner pods because the pod template is not created yet")
+		return nil
+	}
+	if err != nil {
+		p.log.Error(err, "failed to get pod template")
+		return err
+	}
+	hash, err := podTemplateHash(&pt.Template)
+	if err != nil {
+		return err
+	}
+
+	var idlePods, outdatedPods []*corev1.Pod
+	var numHeld int32
+	for i := range podList.Items {
+		po := &podList.Items[i]
+		switch {
+		case po.DeletionTimestamp != nil || p.expectations.deleting(po.Name):
+		case po.Status.Phase == corev1.PodSucceeded || po.Status.Phase == corev1.PodFailed:
+			// Finished pods are replaced as well as the pods created from the older template.
+			outdatedPods = append(outdatedPods, po)
+		case heldPods[po.Name]:
+			numHeld++
+		case po.Labels[constants.PodTemplateHashLabelKey] != hash:
+			outdatedPods = append(outdatedPods, po)
+		default:
+			idlePods = append(idlePods, po)
+		}
+	}
+
+	for _, po := range outdatedPods {
+		if err := p.deletePod(ctx, po); err != nil && !apierrors.IsNotFound(err) {
+			p.log.Error(err, "failed to delete outdated runner pod", "pod", po.Name)
+			continue
+		}
+		p.log.Info("deleted outdated runner pod", "pod", po.Name, "phase", po.Status.Phase)
+	}
+
+	p.mu.Lock()
+	replicas := p.replicas
+	limit := p.maxRunnerPods
+	p.mu.Unlock()
+	if limit == 0 {
+		limit = replicas
+	}
+	desired := min(replicas, limit-numHeld)
+	if draining || desired < 0 {
+		desired = 0
+	}
+
+	current := int32(len(idlePods) + p.expectations.pendingCreations())
+	for ; current < desired; current++ {
+		po, err := p.newRunnerPod(pt, hash)
+		if err != nil {
+			return err
+		}
+		if err := p.k8sClient.Create(ctx, po); err != nil {
+			p.log.Error(err, "failed to create runner pod")
+			return err
+		}
+		p.expectations.expectCreation(po.Name, now)
+		p.log.Info("created runner pod", "pod", po.Name)
+	}
+
+	if current > desired {
+		// Delete the pods that are not running first, then the newer ones, as the ReplicaSet controller does.
+		sort.SliceStable(idlePods, func(i, j int) bool {
+			ri := idlePods[i].Status.Phase == corev1.PodRunning
+			rj := idlePods[j].Status.Phase == corev1.PodRunning
+			if ri != rj {
+				return rj
+			}
+			return idlePods[j].CreationTimestamp.Before(&idlePods[i].CreationTimestamp)
+		})
+		for _, po := range idlePods[:min(int(current-desired), len(idlePods))] {
+			if err := p.deletePod(ctx, po); err != nil && !apierrors.IsNotFound(err) {
+				p.log.Error(err, "failed to delete surplus runner pod", "pod", po.Name)
+				continue
+			}
+			p.log.Info("deleted surplus runner pod", "pod", po.Name)
+		}
+	}
+	return nil
+}
+
+func (p *manageProcess) newRunnerPod(pt *corev1.PodTemplate, hash string) (*corev1.Pod, error) {
+	po := &corev1.Pod{}
+	po.SetNamespace(p.rpNamespace)
+	po.SetGenerateName(p.rpName + "-")
+	po.SetLabels(mergeMap(pt.Template.Labels, map[string]string{constants.PodTemplateHashLabelKey: hash}))
+	po.SetAnnotations(pt.Template.Annotations)
+	po.Spec = *pt.Template.Spec.DeepCopy()
+	if err := ctrl.SetControllerReference(p.runnerPool(), po, p.scheme); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+// podTemplateHash returns the hash of the pod template to find the runner pods created from the older template.
+func podTemplateHash(tmpl *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(tmpl)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New32a()
+	h.Write(data)
+	return strconv.FormatUint(uint64(h.Sum32()), 16), nil
+}
+
+// deletePod deletes the runner pod, and records the deletion to the expectations.
+func (p *manageProcess) deletePod(ctx context.Context, po *corev1.Pod) error {
+	if err := p.k8sClient.Delete(ctx, po); err != nil {
+		return err
+	}
+	p.expectations.expectDeletion(po.Name, time.Now().UTC())
+	return nil
+}
+
+// notify sends the job result of the runner pod to slack-agent, and records the delivery in the annotation of the pod.
+// The annotation prevents the duplicated notifications even if the controller restarts or the leader changes.
+// If recording the delivery fails, the notification may be sent again.
+func (p *manageProcess) notify(ctx context.Context, po *corev1.Pod, status *runner.Status, slackChannel string, extend bool) error {
+	ch := slackChannel
+	if status.SlackChannel != "" {
+		ch = status.SlackChannel
+	}
+	err := p.slackAgentClient.PostResult(ctx, ch, status.Result, extend, po.Namespace, po.Name, status.JobInfo)
+	if err != nil {
+		return fmt.Errorf("failed to send a notification to slack-agent; %w", err)
+	}
+	return p.markNotified(ctx, po)
+}
+
+// markNotified records the delivery of the notification in the annotation of the pod.
+func (p *manageProcess) markNotified(ctx context.Context, po *corev1.Pod) error {
+	patch := client.MergeFrom(po.DeepCopy())
+	if po.Annotations == nil {
+		po.Annotations = map[string]string{}
+	}
+	po.Annotations[constants.NotifiedAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	if err := p.k8sClient.Patch(ctx, po, patch); err != nil {
+		return fmt.Errorf("failed to record the notification; %w", err)
+	}
+	return nil
+}
+
+// applyDeletionTimeAnnotations updates the deletion time of the debugging runner pod by the annotations given by users.
+// The extension is limited to extendDuration from now, the same as the extension from Slack.
+// The annotations are removed after they are applied or rejected.
+func (p *manageProcess) applyDeletionTimeAnnotations(ctx context.Context, po *corev1.Pod, status *runner.Status, extendDuration time.Duration, now time.Time) error {
+	_, deleteNow := po.Annotations[constants.DeleteNowAnnotationKey]
+	extendUntil, extend := po.Annotations[constants.ExtendUntilAnnotationKey]
+	if !deleteNow && !extend {
+		return nil
+	}
+
+	// The zero time makes the pod deleted immediately.
+	var tm time.Time
+	var rejected string
+	if !deleteNow {
+		t, err := runner.ParseExtendUntil(extendUntil, extendDuration, now)
+		switch {
+		case errors.Is(err, runner.ErrExtendNotAllowed):
+			rejected = err.Error()
+		case err != nil:
+			rejected = fmt.Sprintf("invalid %s annotation: %v", constants.ExtendUntilAnnotationKey, err)
+		default:
+			tm = t
+		}
+	}
+
+	if rejected == "" {
+		if err := p.runnerPodClient.PutDeletionTime(ctx, po.Status.PodIP, tm); err != nil {
+			return err
+		}
+		status.DeletionTime = &tm
+	}
+
+	patch := client.MergeFrom(po.DeepCopy())
+	delete(po.Annotations, constants.DeleteNowAnnotationKey)
+	delete(po.Annotations, constants.ExtendUntilAnnotationKey)
+	if err := p.k8sClient.Patch(ctx, po, patch); err != nil {
+		return fmt.Errorf("failed to remove the annotations; %w", err)
+	}
+
+	switch {
+	case rejected != "":
+		p.recorder.Event(po, corev1.EventTypeWarning, "ExtensionRejected", rejected)
+	case deleteNow:
+		p.recorder.Event(po, corev1.EventTypeNormal, "DeletionRequested", "the runner pod will be deleted immediately")
+	default:
+		p.recorder.Eventf(po, corev1.EventTypeNormal, "Extended", "the runner pod is extended until %s", tm.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// jobTimedOut returns true if the job running on the runner pod exceeds the maximum duration.
+func jobTimedOut(status *runner.Status, maxJobDuration time.Duration, now time.Time) bool {
+	if maxJobDuration == 0 || status.State != constants.RunnerPodStateRunning || status.JobStartedAt == nil {
+		return false
+	}
+	return now.After(status.JobStartedAt.Add(maxJobDuration))
+}
+
+// recordRunnerJob creates a RunnerJob for the finished job of the runner pod, if it does not exist yet.
+// The RunnerJob is named after the pod, so that it is created only once for each job.
+func (p *manageProcess) recordRunnerJob(ctx context.Context, po *corev1.Pod, status *runner.Status, ttl time.Duration) error {
+	err := p.k8sClient.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: po.Name}, &meowsv1alpha1.RunnerJob{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get RunnerJob; %w", err)
+	}
+
+	job := &meowsv1alpha1.RunnerJob{}
+	job.Namespace = po.Namespace
+	job.Name = po.Name
+	job.Labels = map[string]string{
+		constants.AppNameLabelKey:     constants.AppName,
+		constants.AppInstanceLabelKey: p.rpName,
+	}
+	job.Spec = meowsv1alpha1.RunnerJobSpec{
+		RunnerPool:              p.rpName,
+		PodName:                 po.Name,
+		NodeName:                po.Spec.NodeName,
+		Result:                  status.Result,
+		TTLSecondsAfterFinished: int32(ttl.Seconds()),
+	}
+	if status.JobStartedAt != nil {
+		job.Spec.StartedAt = &metav1.Time{Time: *status.JobStartedAt}
+	}
+	if status.FinishedAt != nil {
+		job.Spec.FinishedAt = &metav1.Time{Time: *status.FinishedAt}
+	}
+	if info := status.JobInfo; info != nil {
+		job.Spec.JobInfo = &meowsv1alpha1.RunnerJobInfo{
+			Actor:             info.Actor,
+			GitRef:            info.GitRef,
+			JobID:             info.JobID,
+			PullRequestNumber: info.PullRequestNum,
+			Repository:        info.Repository,
+			RunID:             info.RunID,
+			RunNumber:         info.RunNumber,
+			WorkflowName:      info.WorkflowName,
+		}
+	}
+	if err := ctrl.SetControllerReference(p.runnerPool(), job, p.scheme); err != nil {
+		return err
+	}
+
+	err = p.k8sClient.Create(ctx, job)
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create RunnerJob; %w", err)
+	}
+	p.recorder.Eventf(job, corev1.EventTypeNormal, "JobFinished", "the job finished with the result %q on pod %s", status.Result, po.Name)
+	return nil
+}
+
+// recreateSetupFailedPod deletes the runner pod whose setup command failed.
+// When setup commands fail repeatedly, the pods are deleted with an exponential backoff not to recreate them in a tight loop.
+func (p *manageProcess) recreateSetupFailedPod(ctx context.Context, log logr.Logger, po *corev1.Pod, status *runner.Status, now time.Time) {
+	failure := status.SetupFailure
+	if failure == nil {
+		failure = &runner.SetupFailure{FailedAt: now}
+	}
+	backoff := setupFailureBackoff(p.setupFailures)
+	if now.Before(failure.FailedAt.Add(backoff)) {
+		log.Info("waiting for the backoff to recreate runner pod whose setup command failed", "backoff", backoff)
+		return
+	}
+
+	err := p.deletePod(ctx, po)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "failed to delete runner pod whose setup command failed")
+		return
+	}
+	// The output is not logged because the setup commands may print secrets. It can be seen in the log of the pod.
+	log.Info("deleted runner pod whose setup command failed", "exit_code", failure.ExitCode, "attempts", failure.Attempts)
+	p.setupFailures++
+	p.lastSetupFailureDelete = now
+	p.recordRunnerPoolEvent(corev1.EventTypeWarning, "SetupFailed",
+		"Deleted runner pod %s because the setup command failed with exit code %d after %d attempts", po.Name, failure.ExitCode, failure.Attempts)
+}
+
+// setupFailureBackoff returns the time to wait before recreating a runner pod after the given number of consecutive setup failures.
+func setupFailureBackoff(failures int) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+	backoff := setupFailureBaseBackoff
+	for i := 1; i < failures && backoff < setupFailureMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > setupFailureMaxBackoff {
+		backoff = setupFailureMaxBackoff
+	}
+	return backoff
+}
+
+// giveJITConfig registers a just-in-time runner for the pod and gives the configuration to the pod.
+func (p *manageProcess) giveJITConfig(ctx context.Context, po *corev1.Pod, runnerList []*github.Runner) error {
+	// When the configuration could not be given in the previous run, the runner remains registered.
+	// A configuration cannot be generated again for the same name, so remove the runner first.
+	for _, runner := range runnerList {
+		if runner.Name != po.Name {
+			continue
+		}
+		err := p.githubClient.RemoveRunner(ctx, p.owner, p.repo, runner.ID)
+		if err != nil && !errors.Is(err, github.ErrNotFound) {
+			return err
+		}
+		p.runnerCache.removeRunner(p.credentialID, p.owner, p.repo, runner.ID)
+	}
+
+	labels := append([]string{}, jitRunnerDefaultLabels...)
+	labels = append(labels, p.rpNamespacedName(), constants.ManagedRunnerLabel)
+	config, err := p.githubClient.GenerateJITConfig(ctx, p.owner, p.repo, po.Name, labels)
+	if err != nil {
+		return err
+	}
+	return p.runnerPodClient.PutJITConfig(ctx, po.Status.PodIP, config)
+}
+
+// registrationTimeoutReason returns the reason why the runner pod should be recreated by the registration timeout.
+// It returns an empty string if the runner pod should be kept.
+func registrationTimeoutReason(po *corev1.Pod, status *runner.Status, runnerList []*github.Runner, timeout time.Duration, now time.Time) string {
+	if timeout == 0 || now.Before(runningSince(po).Add(timeout)) {
+		return ""
+	}
+	switch status.State {
+	case constants.RunnerPodStateInitializing:
+		return constants.RegistrationTimeoutReasonNotRunning
+	case constants.RunnerPodStateRunning:
+		for _, runner := range runnerList {
+			if runner.Name == po.Name {
+				return ""
+			}
+		}
+		return constants.RegistrationTimeoutReasonNotRegistered
+	}
+	return ""
+}
+
+// runningSince returns the time when the runner container started.
+// If it is not recorded, the start time or the creation time of the pod is returned.
+func runningSince(po *corev1.Pod) time.Time {
+	for _, cs := range po.Status.ContainerStatuses {
+		if cs.Name == constants.RunnerContainerName && cs.State.Running != nil {
+			return cs.State.Running.StartedAt.Time
+		}
+	}
+	if po.Status.StartTime != nil {
+		return po.Status.StartTime.Time
+	}
+	return po.CreationTimestamp.Time
+}
+
+// runnerPool returns a RunnerPool which has only the metadata to refer to the managed RunnerPool.
+func (p *manageProcess) runnerPool() *meowsv1alpha1.RunnerPool {
+	rp := &meowsv1alpha1.RunnerPool{}
+	rp.SetNamespace(p.rpNamespace)
+	rp.SetName(p.rpName)
+	rp.SetUID(p.rpUID)
+	return rp
+}
+
+func (p *manageProcess) recordRunnerPoolEvent(eventtype, reason, messageFmt string, args ...interface{}) {
+	p.recorder.Eventf(p.runnerPool(), eventtype, reason, messageFmt, args...)
+}
+
+// adoptPod replaces the controller of the unlinked pod (i.e. the ReplicaSet) with the RunnerPool,
+// so that the pod is garbage-collected with the RunnerPool.
+func (p *manageProcess) adoptPod(po *corev1.Pod) error {
+	var refs []metav1.OwnerReference
+	for _, ref := range po.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	po.OwnerReferences = refs
+	return ctrl.SetControllerReference(p.runnerPool(), po, p.scheme)
+}
+
 func runnerBusy(runnerList []*github.Runner, name string) bool {
 	for _, runner := range runnerList {
 		if runner.Name == name {
@@ -527,18 +1479,49 @@ func runnerBusy(runnerList []*github.Runner, name string) bool {
 }
 
 func (p *manageProcess) deleteOfflineRunners(ctx context.Context, runnerList []*github.Runner, podList *corev1.PodList) error {
+	p.mu.Lock()
+	gracePeriod := p.offlineGracePeriod
+	p.mu.Unlock()
+
+	now := time.Now()
+	offline := map[int64]bool{}
+	var pending int
+	var errs []error
 	for _, runner := range runnerList {
 		if runner.Online || podExists(runner.Name, podList) {
 			continue
 		}
+		offline[runner.ID] = true
+		since, ok := p.offlineRunnersSince[runner.ID]
+		if !ok {
+			p.offlineRunnersSince[runner.ID] = now
+			since = now
+		}
+		if now.Sub(since) < gracePeriod {
+			pending++
+			continue
+		}
+
 		err := p.githubClient.RemoveRunner(ctx, p.owner, p.repo, runner.ID)
-		if err != nil {
+		if err != nil && !errors.Is(err, github.ErrNotFound) {
+			// Keep the runner in offlineRunnersSince to retry the removal in the next check.
 			p.log.Error(err, "failed to remove runner", "runner", runner.Name, "runner_id", runner.ID)
-			return err
+			errs = append(errs, err)
+			continue
 		}
-		p.log.Info("removed runner", "runner", runner.Name, "runner_id", runner.ID)
+		delete(offline, runner.ID)
+		p.runnerCache.removeRunner(p.credentialID, p.owner, p.repo, runner.ID)
+		p.log.Info("removed runner", "runner", runner.Name, "runner_id", runner.ID, "offline_since", since)
 	}
-	return nil
+
+	// Forget the runners that come back online, get their pods, or are removed.
+	for id := range p.offlineRunnersSince {
+		if !offline[id] {
+			delete(p.offlineRunnersSince, id)
+		}
+	}
+	metrics.UpdateOfflineRunnersPendingRemoval(p.rpNamespacedName(), pending)
+	return errors.Join(errs...)
 }
 
 func (p *manageProcess) deleteAllRunners(ctx context.Context) error {
@@ -549,10 +1532,11 @@ func (p *manageProcess) deleteAllRunners(ctx context.Context) error {
 	}
 	for _, runner := range runnerList {
 		err := p.githubClient.RemoveRunner(ctx, p.owner, p.repo, runner.ID)
-		if err != nil {
+		if err != nil && !errors.Is(err, github.ErrNotFound) {
 			p.log.Error(err, "failed to remove runner", "runner", runner.Name, "runner_id", runner.ID)
 			return err
 		}
+		p.runnerCache.removeRunner(p.credentialID, p.owner, p.repo, runner.ID)
 		p.log.Info("removed runner", "runner", runner.Name, "runner_id", runner.ID)
 	}
 	return nil
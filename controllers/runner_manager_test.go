@@ -2,10 +2,14 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
@@ -19,8 +23,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	corev1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -192,7 +199,7 @@ var _ = Describe("RunnerManager", func() {
 			By("preparing fake clients")
 			runnerPodClient := runner.NewFakeClient()
 			githubClientFactory := github.NewFakeClientFactory()
-			runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+			runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 			By("preparing pods and runners")
 			for _, inputPod := range tt.inputPods {
@@ -271,10 +278,11 @@ var _ = Describe("RunnerManager", func() {
 		By("preparing fake clients")
 		runnerPodClient := runner.NewFakeClient()
 		githubClientFactory := github.NewFakeClientFactory()
-		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 		By("starting runnerpool manager")
 		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
 		rp.Spec.Replicas = 5
 		rp.Spec.MaxRunnerPods = 8
 		runnerManager.StartOrUpdate(rp, nil)
@@ -335,6 +343,10 @@ var _ = Describe("RunnerManager", func() {
 		// It is undetermined which Pod other than pod1 will remain. It depends on enumeration order.
 		Expect(labeledPodNames).To(HaveLen(len(inputPods) - int(rp.Spec.MaxRunnerPods-rp.Spec.Replicas)))
 		Expect(labeledPodNames).To(ContainElement("pod1"))
+		for i := range podList.Items {
+			po := &podList.Items[i]
+			Expect(metav1.IsControlledBy(po, rp)).To(Equal(!slices.Contains(labeledPodNames, po.Name)), po.Name)
+		}
 
 		By("deleting one of the unlabeled pods")
 		Expect(k8sClient.Delete(ctx, unlabeledPod)).To(Succeed())
@@ -379,15 +391,169 @@ var _ = Describe("RunnerManager", func() {
 			Expect(pdbNames).To(ContainElement(pod.Name))
 		}
 
+		By("changing configuration to allow disruption")
+		rp.Spec.DenyDisruption = false
+		runnerManager.StartOrUpdate(rp, nil)
+		Eventually(func(g Gomega) {
+			pdbList := new(policyv1.PodDisruptionBudgetList)
+			g.Expect(k8sClient.List(ctx, pdbList, client.InNamespace("test-ns1"))).To(Succeed())
+			g.Expect(pdbList.Items).To(BeEmpty())
+
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+			for _, pod := range podList.Items {
+				g.Expect(pod.Labels).NotTo(HaveKey("meows.cybozu.com/runner-pod-name"))
+			}
+		}).Should(Succeed())
+
 		By("tearing down")
 		Expect(runnerManager.Stop(rp)).To(Succeed())
 	})
 
+	It("should give the busy label to busy runner pods in the pool-level disruption protection", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec  *corev1.Pod
+			ip    string
+			state string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", state: "running"}, // runner is idle.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", state: "running"}, // runner is busy.
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3", state: "debugging"},
+		}
+		for _, inputPod := range inputPods {
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+
+			status := runner.Status{State: inputPod.state}
+			if inputPod.state == "debugging" {
+				t1 := time.Now()
+				t2 := time.Now().Add(24 * time.Hour)
+				status.FinishedAt = &t1
+				status.DeletionTime = &t2
+			}
+			runnerPodClient.SetStatus(created.Status.PodIP, &status)
+		}
+		runners := []*github.Runner{
+			{Name: "pod1", ID: 1, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			{Name: "pod2", ID: 2, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{"owner/repo1": runners})
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.DenyDisruption = true
+		rp.Spec.DisruptionProtection = meowsv1alpha1.DisruptionProtectionPool
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		busyPodNames := func(g Gomega) []string {
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"), client.MatchingLabels{"meows.cybozu.com/busy": "true"})).To(Succeed())
+			var names []string
+			for _, po := range podList.Items {
+				g.Expect(po.Labels).NotTo(HaveKey("meows.cybozu.com/runner-pod-name"))
+				names = append(names, po.Name)
+			}
+			return names
+		}
+
+		By("checking the busy and debugging pods are labeled")
+		Eventually(func(g Gomega) {
+			g.Expect(busyPodNames(g)).To(ConsistOf("pod2", "pod3"))
+		}).Should(Succeed())
+		for _, name := range []string{"pod1", "pod2", "pod3"} {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "test-ns1"}, new(policyv1.PodDisruptionBudget))
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+
+		By("making the busy runner idle")
+		githubClientFactory.SetRunners(map[string][]*github.Runner{"owner/repo1": {
+			{Name: "pod1", ID: 1, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			{Name: "pod2", ID: 2, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+		}})
+		Eventually(func(g Gomega) {
+			g.Expect(busyPodNames(g)).To(ConsistOf("pod3"))
+		}).Should(Succeed())
+
+		By("changing configuration to allow disruption")
+		rp.Spec.DenyDisruption = false
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(busyPodNames(g)).To(BeEmpty())
+		}).Should(Succeed())
+	})
+
+	It("should give just-in-time configurations to runner pods", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec    *corev1.Pod
+			ip      string
+			waiting bool
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", waiting: true},
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", waiting: true}, // the runner is left by the previous run.
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3", waiting: false},
+		}
+		for _, inputPod := range inputPods {
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{State: "initializing", WaitingJITConfig: inputPod.waiting})
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod2", ID: 2, Online: false, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.Spec.JITConfig = true
+		runnerManager.StartOrUpdate(rp, nil)
+		time.Sleep(2 * time.Second)
+
+		By("checking the configurations")
+		Expect(runnerPodClient.GetJITConfig("10.0.0.1")).To(Equal("fake-jitconfig-pod1"))
+		Expect(runnerPodClient.GetJITConfig("10.0.0.2")).To(Equal("fake-jitconfig-pod2"))
+		Expect(runnerPodClient.GetJITConfig("10.0.0.3")).To(BeEmpty())
+
+		By("checking runners")
+		runnerList, err := githubClientFactory.ListRunners(ctx, "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		var runnerNames []string
+		for _, r := range runnerList {
+			Expect(r.Labels).To(ConsistOf("self-hosted", "Linux", "X64", "test-ns1/rp1", "meows.cybozu.com/managed"))
+			runnerNames = append(runnerNames, r.Name)
+		}
+		Expect(runnerNames).To(ConsistOf("pod1", "pod2"))
+
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+	})
+
 	It("should expose metrics about runnerpools", func() {
 		By("preparing fake clients")
 		runnerPodClient := runner.NewFakeClient()
 		githubClientFactory := github.NewFakeClientFactory()
-		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 		By("starting metrics server")
 		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
@@ -469,7 +635,7 @@ var _ = Describe("RunnerManager", func() {
 		By("preparing fake clients")
 		runnerPodClient := runner.NewFakeClient()
 		githubClientFactory := github.NewFakeClientFactory()
-		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 		By("starting metrics server")
 		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
@@ -592,7 +758,7 @@ var _ = Describe("RunnerManager", func() {
 		By("preparing fake clients")
 		runnerPodClient := runner.NewFakeClient()
 		githubClientFactory := github.NewFakeClientFactory()
-		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 		By("starting metrics server")
 		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
@@ -760,11 +926,926 @@ var _ = Describe("RunnerManager", func() {
 		Expect(runnerManager.Stop(rp3)).To(Succeed())
 	})
 
+	It("should recreate pods whose runners are not registered within the registration timeout", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		recorder := record.NewFakeRecorder(100)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, recorder, githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec  *corev1.Pod
+			ip    string
+			state string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", state: "initializing"}, // the runner does not start.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", state: "running"},      // the runner is not registered.
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3", state: "running"},      // the runner is registered.
+		}
+		for _, inputPod := range inputPods {
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{State: inputPod.state})
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod3", ID: 3, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.Spec.RegistrationTimeout = "3s"
+		runnerManager.StartOrUpdate(rp, nil)
+		defer runnerManager.Stop(rp)
+
+		By("checking pods are kept within the timeout")
+		time.Sleep(1500 * time.Millisecond)
+		podList := new(corev1.PodList)
+		Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+		Expect(podList.Items).To(HaveLen(3))
+
+		By("checking pods are deleted after the timeout")
+		Eventually(func(g Gomega) {
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+			var names []string
+			for _, po := range podList.Items {
+				names = append(names, po.Name)
+			}
+			g.Expect(names).To(ConsistOf("pod3"))
+		}).Should(Succeed())
+
+		By("checking events")
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(ConsistOf(
+			And(HavePrefix("Warning RegistrationTimeout"), ContainSubstring("pod1"), ContainSubstring("not_running")),
+			And(HavePrefix("Warning RegistrationTimeout"), ContainSubstring("pod2"), ContainSubstring("not_registered")),
+		))
+	})
+
+	It("should remove offline runners without pods after the grace period", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: false, Busy: false, Labels: []string{"test-ns1/rp1"}}, // pod does not exist, offline
+				{Name: "pod2", ID: 2, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},  // pod does not exist, but online
+			},
+		})
+		listRunnerNames := func(g Gomega) []string {
+			runnerList, err := githubClientFactory.ListRunners(ctx, "owner", "repo1", nil)
+			g.Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, r := range runnerList {
+				names = append(names, r.Name)
+			}
+			return names
+		}
+
+		By("starting metrics server")
+		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
+		go func() {
+			server.ListenAndServe()
+		}()
+		defer server.Shutdown(context.Background())
+		time.Sleep(1 * time.Second)
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.Spec.OfflineRunnerGracePeriod = "4s"
+		runnerManager.StartOrUpdate(rp, nil)
+
+		By("checking the offline runner is kept within the grace period")
+		time.Sleep(2 * time.Second)
+		Expect(listRunnerNames(Default)).To(ConsistOf("pod1", "pod2"))
+		MetricsShouldHaveValue(metricsURL, "meows_runnerpool_offline_runners_pending_removal",
+			MatchAllElementsWithIndex(IndexIdentity, Elements{
+				"0": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("test-ns1/rp1")}),
+					"Value": BeNumerically("==", 1.0),
+				})),
+			}),
+		)
+
+		By("checking the offline runner is removed after the grace period")
+		Eventually(func(g Gomega) {
+			g.Expect(listRunnerNames(g)).To(ConsistOf("pod2"))
+		}).Should(Succeed())
+		MetricsShouldHaveValue(metricsURL, "meows_runnerpool_offline_runners_pending_removal",
+			MatchAllElementsWithIndex(IndexIdentity, Elements{
+				"0": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("test-ns1/rp1")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+			}),
+		)
+
+		By("stopping runnerpool manager")
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+		MetricsShouldNotExist(metricsURL, "meows_runnerpool_offline_runners_pending_removal")
+	})
+
+	It("should recreate pods whose setup command failed with backoff", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		recorder := record.NewFakeRecorder(100)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, recorder, githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods whose setup command failed")
+		inputPods := []struct {
+			spec *corev1.Pod
+			ip   string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1"},
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2"},
+		}
+		for _, inputPod := range inputPods {
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{
+				State: "setup_failed",
+				SetupFailure: &runner.SetupFailure{
+					ExitCode: 1,
+					Output:   "error",
+					Attempts: 1,
+					FailedAt: time.Now(),
+				},
+			})
+		}
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		runnerManager.StartOrUpdate(rp, nil)
+		defer runnerManager.Stop(rp)
+
+		By("checking only one pod is deleted within the backoff")
+		time.Sleep(3 * time.Second)
+		podList := new(corev1.PodList)
+		Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+		Expect(podList.Items).To(HaveLen(1))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(And(HavePrefix("Warning SetupFailed"), ContainSubstring("exit code 1")))
+	})
+
+	It("should unlink all busy pods and report busy runners in draining mode", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec *corev1.Pod
+			ip   string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1"}, // runner is idle.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2"}, // runner is busy.
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3"}, // runner is busy.
+		}
+		for _, inputPod := range inputPods {
+			inputPod.spec.Labels["pod-template-hash"] = "foo"
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{State: "running"})
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod2", ID: 2, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod3", ID: 3, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("starting runnerpool manager without removable pods")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.Replicas = 3
+		rp.Spec.MaxRunnerPods = 3
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		time.Sleep(2 * time.Second)
+		podList := new(corev1.PodList)
+		Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"), client.HasLabels{"pod-template-hash"})).To(Succeed())
+		Expect(podList.Items).To(HaveLen(3))
+
+		By("draining runners")
+		Eventually(func(g Gomega) {
+			busyRunners, observed, err := runnerManager.Drain(rp, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(observed).To(BeTrue())
+			g.Expect(busyRunners).To(ConsistOf("pod2", "pod3"))
+		}).Should(Succeed())
+		podList = new(corev1.PodList)
+		Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"), client.HasLabels{"pod-template-hash"})).To(Succeed())
+		Expect(podList.Items).To(HaveLen(1))
+		Expect(podList.Items[0].Name).To(Equal("pod1"))
+
+		By("stopping runnerpool manager")
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+		runnerList, _ := githubClientFactory.ListRunners(ctx, "owner", "repo1", nil)
+		Expect(runnerList).To(BeEmpty())
+	})
+
+	It("should adopt unlinked pods and stop unlinking pods when the unlinked pods exceed the limit", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec     *corev1.Pod
+			ip       string
+			unlinked bool
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", unlinked: true}, // unlinked before maxRunnerPods is decreased.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2"},                 // runner is busy.
+		}
+		for _, inputPod := range inputPods {
+			if !inputPod.unlinked {
+				inputPod.spec.Labels["pod-template-hash"] = "foo"
+			}
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{State: "running"})
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod2", ID: 2, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("starting runnerpool manager with the decreased maxRunnerPods")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.Replicas = 1
+		rp.Spec.MaxRunnerPods = 1
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+
+		By("checking the unlinked pod is adopted")
+		Eventually(func(g Gomega) {
+			po := &corev1.Pod{}
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pod1", Namespace: "test-ns1"}, po)).To(Succeed())
+			g.Expect(metav1.IsControlledBy(po, rp)).To(BeTrue())
+		}).Should(Succeed())
+
+		By("checking the busy pod is not unlinked")
+		Consistently(func(g Gomega) {
+			po := &corev1.Pod{}
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pod2", Namespace: "test-ns1"}, po)).To(Succeed())
+			g.Expect(po.Labels).To(HaveKey("pod-template-hash"))
+			g.Expect(po.OwnerReferences).To(BeEmpty())
+		}, 3*time.Second).Should(Succeed())
+
+		By("stopping runnerpool manager")
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+	})
+
+	It("should create and delete runner pods directly in the direct pod management", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating a pod template")
+		pt := &corev1.PodTemplate{}
+		pt.SetName("rp1")
+		pt.SetNamespace("test-ns1")
+		templatePod := makePod("", "test-ns1", "rp1")
+		pt.Template.Labels = templatePod.Labels
+		pt.Template.Spec = templatePod.Spec
+		Expect(k8sClient.Create(ctx, pt)).To(Succeed())
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.PodManagement = meowsv1alpha1.PodManagementDirect
+		rp.Spec.Replicas = 2
+		rp.Spec.MaxRunnerPods = 3
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+
+		listActivePods := func(g Gomega) []corev1.Pod {
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+			var pods []corev1.Pod
+			for _, po := range podList.Items {
+				if po.DeletionTimestamp == nil {
+					pods = append(pods, po)
+				}
+			}
+			return pods
+		}
+
+		By("checking the pods are created")
+		var pods []corev1.Pod
+		Eventually(func(g Gomega) {
+			pods = listActivePods(g)
+			g.Expect(pods).To(HaveLen(2))
+		}).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(listActivePods(g)).To(HaveLen(2))
+		}, 3*time.Second).Should(Succeed())
+		for i := range pods {
+			Expect(metav1.IsControlledBy(&pods[i], rp)).To(BeTrue())
+			Expect(pods[i].Labels).To(HaveKey("meows.cybozu.com/pod-template-hash"))
+		}
+		oldHash := pods[0].Labels["meows.cybozu.com/pod-template-hash"]
+
+		By("making a runner busy")
+		busyPod := pods[0].DeepCopy()
+		busyPod.Status.PodIP = "10.0.0.1"
+		busyPod.Status.Phase = corev1.PodRunning
+		Expect(k8sClient.Status().Update(ctx, busyPod)).To(Succeed())
+		runnerPodClient.SetStatus(busyPod.Status.PodIP, &runner.Status{State: "running"})
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: busyPod.Name, ID: 1, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("checking a pod is added for the busy runner")
+		Eventually(func(g Gomega) {
+			g.Expect(listActivePods(g)).To(HaveLen(3))
+		}).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(listActivePods(g)).To(HaveLen(3))
+		}, 3*time.Second).Should(Succeed())
+
+		By("updating the pod template")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(pt), pt)).To(Succeed())
+		pt.Template.Spec.Containers[0].Image = "sample:new"
+		Expect(k8sClient.Update(ctx, pt)).To(Succeed())
+
+		By("checking the idle pods are replaced, but the busy pod is kept")
+		Eventually(func(g Gomega) {
+			pods := listActivePods(g)
+			g.Expect(pods).To(HaveLen(3))
+			for _, po := range pods {
+				if po.Name == busyPod.Name {
+					g.Expect(po.Labels["meows.cybozu.com/pod-template-hash"]).To(Equal(oldHash))
+					continue
+				}
+				g.Expect(po.Labels["meows.cybozu.com/pod-template-hash"]).NotTo(Equal(oldHash))
+				g.Expect(po.Spec.Containers[0].Image).To(Equal("sample:new"))
+			}
+		}).Should(Succeed())
+
+		By("draining runners")
+		Eventually(func(g Gomega) {
+			_, observed, err := runnerManager.Drain(rp, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(observed).To(BeTrue())
+			pods := listActivePods(g)
+			g.Expect(pods).To(HaveLen(1))
+			g.Expect(pods[0].Name).To(Equal(busyPod.Name))
+		}).Should(Succeed())
+
+		By("stopping runnerpool manager")
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, pt)).To(Succeed())
+	})
+
+	It("should keep runner pods whose status cannot be fetched in the direct pod management", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating a pod template")
+		pt := &corev1.PodTemplate{}
+		pt.SetName("rp1")
+		pt.SetNamespace("test-ns1")
+		templatePod := makePod("", "test-ns1", "rp1")
+		pt.Template.Labels = templatePod.Labels
+		pt.Template.Spec = templatePod.Spec
+		Expect(k8sClient.Create(ctx, pt)).To(Succeed())
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.PodManagement = meowsv1alpha1.PodManagementDirect
+		rp.Spec.Replicas = 1
+		rp.Spec.MaxRunnerPods = 3
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+
+		listActivePods := func(g Gomega) []corev1.Pod {
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+			var pods []corev1.Pod
+			for _, po := range podList.Items {
+				if po.DeletionTimestamp == nil {
+					pods = append(pods, po)
+				}
+			}
+			return pods
+		}
+
+		By("checking the pod is created")
+		var pods []corev1.Pod
+		Eventually(func(g Gomega) {
+			pods = listActivePods(g)
+			g.Expect(pods).To(HaveLen(1))
+		}).Should(Succeed())
+
+		By("making the pod running without the status")
+		unknownPod := pods[0].DeepCopy()
+		unknownPod.Status.PodIP = "10.0.0.1"
+		unknownPod.Status.Phase = corev1.PodRunning
+		Expect(k8sClient.Status().Update(ctx, unknownPod)).To(Succeed())
+
+		By("updating the pod template")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(pt), pt)).To(Succeed())
+		pt.Template.Spec.Containers[0].Image = "sample:new"
+		Expect(k8sClient.Update(ctx, pt)).To(Succeed())
+
+		By("checking a new pod is added, but the pod whose status is unknown is kept")
+		Eventually(func(g Gomega) {
+			g.Expect(listActivePods(g)).To(HaveLen(2))
+		}).Should(Succeed())
+		Consistently(func(g Gomega) {
+			pods := listActivePods(g)
+			g.Expect(pods).To(HaveLen(2))
+			g.Expect(pods).To(ContainElement(HaveField("Name", unknownPod.Name)))
+		}, 3*time.Second).Should(Succeed())
+
+		By("stopping runnerpool manager")
+		Expect(runnerManager.Stop(rp)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, pt)).To(Succeed())
+	})
+
+	It("should delete idle runner pods on draining nodes and report the pods blocking the drain", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		recorder := record.NewFakeRecorder(100)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, recorder, githubClientFactory, runnerPodClient, time.Second)
+
+		By("starting metrics server")
+		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
+		go func() {
+			server.ListenAndServe()
+		}()
+		defer server.Shutdown(context.Background())
+
+		By("creating nodes")
+		cordoned := &corev1.Node{}
+		cordoned.SetName("node1")
+		cordoned.Spec.Unschedulable = true
+		tainted := &corev1.Node{}
+		tainted.SetName("node2")
+		tainted.Spec.Taints = []corev1.Taint{{Key: "ToBeDeletedByClusterAutoscaler", Effect: corev1.TaintEffectNoSchedule}}
+		healthy := &corev1.Node{}
+		healthy.SetName("node3")
+		for _, node := range []*corev1.Node{cordoned, tainted, healthy} {
+			Expect(k8sClient.Create(ctx, node)).To(Succeed())
+			defer k8sClient.Delete(ctx, node)
+		}
+
+		By("creating pods and runners")
+		inputPods := []struct {
+			spec  *corev1.Pod
+			node  string
+			ip    string
+			state string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), node: "node1", ip: "10.0.0.1", state: "running"},   // runner is idle.
+			{spec: makePod("pod2", "test-ns1", "rp1"), node: "node1", ip: "10.0.0.2", state: "running"},   // runner is busy.
+			{spec: makePod("pod3", "test-ns1", "rp1"), node: "node2", ip: "10.0.0.3", state: "debugging"}, // runner is being debugged.
+			{spec: makePod("pod4", "test-ns1", "rp1"), node: "node2"},                                     // pod is not running.
+			{spec: makePod("pod5", "test-ns1", "rp1"), node: "node3", ip: "10.0.0.5", state: "running"},   // runner is idle on a healthy node.
+		}
+		for _, inputPod := range inputPods {
+			inputPod.spec.Spec.NodeName = inputPod.node
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			defer k8sClient.Delete(ctx, inputPod.spec, client.GracePeriodSeconds(0))
+			if inputPod.ip == "" {
+				continue
+			}
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+
+			status := runner.Status{State: inputPod.state}
+			if inputPod.state == "debugging" {
+				t1 := time.Now()
+				t2 := time.Now().Add(24 * time.Hour)
+				status.FinishedAt = &t1
+				status.DeletionTime = &t2
+			}
+			runnerPodClient.SetStatus(created.Status.PodIP, &status)
+		}
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod2", ID: 2, Online: true, Busy: true, Labels: []string{"test-ns1/rp1"}},
+				{Name: "pod5", ID: 5, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			},
+		})
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		By("checking the idle pods on the draining nodes are deleted")
+		Eventually(func(g Gomega) {
+			podList := new(corev1.PodList)
+			g.Expect(k8sClient.List(ctx, podList, client.InNamespace("test-ns1"))).To(Succeed())
+			var names []string
+			for _, po := range podList.Items {
+				if po.DeletionTimestamp == nil {
+					names = append(names, po.Name)
+				}
+			}
+			g.Expect(names).To(ConsistOf("pod2", "pod3", "pod5"))
+		}).Should(Succeed())
+
+		By("checking the runner of the deleted pod is removed")
+		runnerList, err := githubClientFactory.ListRunners(ctx, "owner", "repo1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		var runnerNames []string
+		for _, r := range runnerList {
+			runnerNames = append(runnerNames, r.Name)
+		}
+		Expect(runnerNames).To(ConsistOf("pod2", "pod5"))
+
+		By("checking the blocking pods are reported")
+		MetricsShouldHaveValue(metricsURL, "meows_runnerpool_node_drain_blocking_pods",
+			MatchAllElementsWithIndex(IndexIdentity, Elements{
+				"0": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("test-ns1/rp1")}),
+					"Value": BeNumerically("==", 2.0),
+				})),
+			}),
+		)
+		time.Sleep(2 * time.Second)
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(ConsistOf(
+			And(HavePrefix("Warning BlockingNodeDrain"), ContainSubstring("node1")),
+			And(HavePrefix("Warning BlockingNodeDrain"), ContainSubstring("node2")),
+		))
+	})
+
+	It("should retry notifications until slack-agent acknowledges them", func() {
+		By("starting a fake slack-agent that fails at first")
+		var mu sync.Mutex
+		var requests, acknowledged int
+		agentServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests++
+			if requests <= 2 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			acknowledged++
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer agentServer.Close()
+
+		By("preparing fake clients")
+		// The job of pod3 finished before the runner manager starts, as if it was notified by the previous controller.
+		oldFinishedAt := time.Now().Add(-time.Minute)
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating finished pods")
+		finishedAt := time.Now()
+		deletionTime := time.Now().Add(time.Hour)
+		inputPods := []struct {
+			spec       *corev1.Pod
+			ip         string
+			finishedAt time.Time
+			notified   bool
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", finishedAt: finishedAt},
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", finishedAt: finishedAt, notified: true},
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3", finishedAt: oldFinishedAt},
+		}
+		for _, inputPod := range inputPods {
+			if inputPod.notified {
+				inputPod.spec.Annotations = map[string]string{"meows.cybozu.com/notified": finishedAt.Format(time.RFC3339)}
+			}
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{
+				State:        "debugging",
+				Result:       "failure",
+				FinishedAt:   &inputPod.finishedAt,
+				DeletionTime: &deletionTime,
+			})
+		}
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.Spec.Notification.Slack.Enable = true
+		rp.Spec.Notification.Slack.AgentServiceName = agentServer.URL
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		By("checking the notification is delivered only once")
+		Eventually(func(g Gomega) {
+			for _, name := range []string{"pod1", "pod3"} {
+				po := &corev1.Pod{}
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "test-ns1"}, po)).To(Succeed())
+				g.Expect(po.Annotations).To(HaveKey("meows.cybozu.com/notified"))
+			}
+		}).Should(Succeed())
+		Consistently(func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return acknowledged
+		}, 3*time.Second).Should(Equal(1))
+	})
+
+	It("should record the jobs of the debugging runner pods as RunnerJobs", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods")
+		finishedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+		deletionTime := time.Now().Add(time.Hour)
+		inputPods := []struct {
+			spec  *corev1.Pod
+			ip    string
+			state string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", state: "debugging"},
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", state: "running"},
+		}
+		for _, inputPod := range inputPods {
+			inputPod.spec.Spec.NodeName = "node1"
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			status := &runner.Status{State: inputPod.state}
+			if inputPod.state == "debugging" {
+				status.Result = "failure"
+				status.FinishedAt = &finishedAt
+				status.DeletionTime = &deletionTime
+				status.JobInfo = &runner.JobInfo{
+					Actor:        "user",
+					GitRef:       "main",
+					JobID:        "build",
+					Repository:   "owner/repo1",
+					RunID:        123,
+					RunNumber:    4,
+					WorkflowName: "CI",
+				}
+			}
+			runnerPodClient.SetStatus(created.Status.PodIP, status)
+		}
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.JobHistoryTTL = "1h"
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		By("checking the RunnerJob is created only for the debugging pod")
+		job := &meowsv1alpha1.RunnerJob{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Name: "pod1", Namespace: "test-ns1"}, job)
+		}).Should(Succeed())
+		Expect(job.Labels).To(HaveKeyWithValue("app.kubernetes.io/instance", "rp1"))
+		Expect(job.OwnerReferences).To(HaveLen(1))
+		Expect(job.OwnerReferences[0].Kind).To(Equal("RunnerPool"))
+		Expect(job.OwnerReferences[0].Name).To(Equal("rp1"))
+		Expect(job.Spec.RunnerPool).To(Equal("rp1"))
+		Expect(job.Spec.PodName).To(Equal("pod1"))
+		Expect(job.Spec.NodeName).To(Equal("node1"))
+		Expect(job.Spec.Result).To(Equal("failure"))
+		Expect(job.Spec.FinishedAt.Time).To(BeTemporally("==", finishedAt))
+		Expect(job.Spec.TTLSecondsAfterFinished).To(BeNumerically("==", 3600))
+		Expect(job.Spec.JobInfo).To(Equal(&meowsv1alpha1.RunnerJobInfo{
+			Actor:        "user",
+			GitRef:       "main",
+			JobID:        "build",
+			Repository:   "owner/repo1",
+			RunID:        123,
+			RunNumber:    4,
+			WorkflowName: "CI",
+		}))
+		Consistently(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "pod2", Namespace: "test-ns1"}, &meowsv1alpha1.RunnerJob{})
+			return apierrors.IsNotFound(err)
+		}, 3*time.Second).Should(BeTrue())
+
+		Expect(k8sClient.DeleteAllOf(ctx, &meowsv1alpha1.RunnerJob{}, client.InNamespace("test-ns1"))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("test-ns1"), client.GracePeriodSeconds(0))).To(Succeed())
+	})
+
+	It("should delete runner pods whose jobs exceed the maximum duration", func() {
+		By("starting a fake slack-agent")
+		var mu sync.Mutex
+		var texts []string
+		agentServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload := struct {
+				Text string `json:"text"`
+				Pod  string `json:"pod"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			texts = append(texts, payload.Pod+" "+payload.Text)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer agentServer.Close()
+
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating pods")
+		inputPods := []struct {
+			spec         *corev1.Pod
+			ip           string
+			jobStartedAt time.Time
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", jobStartedAt: time.Now().Add(-2 * time.Hour)}, // the job exceeds the limit.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", jobStartedAt: time.Now()},                     // the job is running within the limit.
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3"},                                               // no job is running.
+		}
+		for _, inputPod := range inputPods {
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			status := &runner.Status{State: "running"}
+			if !inputPod.jobStartedAt.IsZero() {
+				status.JobStartedAt = &inputPod.jobStartedAt
+			}
+			runnerPodClient.SetStatus(created.Status.PodIP, status)
+		}
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.UID = "rp1-uid"
+		rp.Spec.MaxJobDuration = "1h"
+		rp.Spec.JobHistoryTTL = "1h"
+		rp.Spec.Notification.Slack.Enable = true
+		rp.Spec.Notification.Slack.AgentServiceName = agentServer.URL
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		By("checking the pod whose job exceeds the limit is deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "pod1", Namespace: "test-ns1"}, &corev1.Pod{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pod2", Namespace: "test-ns1"}, &corev1.Pod{})).To(Succeed())
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pod3", Namespace: "test-ns1"}, &corev1.Pod{})).To(Succeed())
+		}, 3*time.Second).Should(Succeed())
+
+		By("checking the timeout is notified and recorded")
+		mu.Lock()
+		Expect(texts).To(ConsistOf("test-ns1/pod1 Timeout: (failed to get job status)"))
+		mu.Unlock()
+		job := &meowsv1alpha1.RunnerJob{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "pod1", Namespace: "test-ns1"}, job)).To(Succeed())
+		Expect(job.Spec.Result).To(Equal("timeout"))
+		Expect(job.Spec.StartedAt.Time).To(BeTemporally("~", inputPods[0].jobStartedAt, time.Second))
+		Expect(job.Spec.FinishedAt).NotTo(BeNil())
+
+		Expect(k8sClient.DeleteAllOf(ctx, &meowsv1alpha1.RunnerJob{}, client.InNamespace("test-ns1"))).To(Succeed())
+	})
+
+	It("should update the deletion time of debugging runner pods by annotations", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		recorder := record.NewFakeRecorder(100)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, recorder, githubClientFactory, runnerPodClient, time.Second)
+
+		By("creating debugging pods with annotations")
+		finishedAt := time.Now()
+		deletionTime := time.Now().Add(20 * time.Minute)
+		extendUntil := time.Now().Add(10 * time.Minute).UTC().Truncate(time.Second)
+		inputPods := []struct {
+			spec        *corev1.Pod
+			ip          string
+			annotations map[string]string
+		}{
+			{spec: makePod("pod1", "test-ns1", "rp1"), ip: "10.0.0.1", annotations: map[string]string{"meows.cybozu.com/extend-until": "3h"}}, // exceeds the limit.
+			{spec: makePod("pod2", "test-ns1", "rp1"), ip: "10.0.0.2", annotations: map[string]string{"meows.cybozu.com/extend-until": extendUntil.Format(time.RFC3339)}},
+			{spec: makePod("pod3", "test-ns1", "rp1"), ip: "10.0.0.3", annotations: map[string]string{"meows.cybozu.com/delete-now": ""}},
+			{spec: makePod("pod4", "test-ns1", "rp1"), ip: "10.0.0.4", annotations: map[string]string{"meows.cybozu.com/extend-until": "invalid"}},
+		}
+		for _, inputPod := range inputPods {
+			inputPod.spec.Annotations = inputPod.annotations
+			Expect(k8sClient.Create(ctx, inputPod.spec)).To(Succeed())
+			created := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: inputPod.spec.Name, Namespace: inputPod.spec.Namespace}, created)).To(Succeed())
+			created.Status.PodIP = inputPod.ip
+			created.Status.Phase = corev1.PodRunning
+			Expect(k8sClient.Status().Update(ctx, created)).To(Succeed())
+			dt := deletionTime
+			runnerPodClient.SetStatus(created.Status.PodIP, &runner.Status{
+				State:        "debugging",
+				Result:       "failure",
+				FinishedAt:   &finishedAt,
+				DeletionTime: &dt,
+			})
+		}
+
+		By("starting runnerpool manager")
+		rp := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp.Spec.Notification.ExtendDuration = "1h"
+		Expect(runnerManager.StartOrUpdate(rp, nil)).To(Succeed())
+		defer runnerManager.Stop(rp)
+
+		By("checking the pod with the delete-now annotation is deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "pod3", Namespace: "test-ns1"}, &corev1.Pod{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		By("checking the annotations are removed")
+		Eventually(func(g Gomega) {
+			for _, name := range []string{"pod1", "pod2", "pod4"} {
+				po := &corev1.Pod{}
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "test-ns1"}, po)).To(Succeed())
+				g.Expect(po.Annotations).NotTo(HaveKey("meows.cybozu.com/extend-until"), name)
+			}
+		}).Should(Succeed())
+
+		By("checking the deletion times")
+		st, err := runnerPodClient.GetStatus(ctx, "10.0.0.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*st.DeletionTime).To(BeTemporally("~", time.Now().Add(time.Hour), 10*time.Second))
+		st, err = runnerPodClient.GetStatus(ctx, "10.0.0.2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*st.DeletionTime).To(BeTemporally("==", extendUntil))
+		st, err = runnerPodClient.GetStatus(ctx, "10.0.0.4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*st.DeletionTime).To(BeTemporally("==", deletionTime))
+
+		By("checking the events")
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(ContainElements(
+			HavePrefix("Normal Extended"),
+			HavePrefix("Normal DeletionRequested"),
+			HavePrefix("Warning ExtensionRejected"),
+		))
+
+		Expect(k8sClient.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace("test-ns1"))).To(Succeed())
+	})
+
 	It("should delete all runners and metrics", func() {
 		By("preparing fake clients")
 		runnerPodClient := runner.NewFakeClient()
 		githubClientFactory := github.NewFakeClientFactory()
-		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, githubClientFactory, runnerPodClient, time.Second)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
 
 		By("starting metrics server")
 		server := &http.Server{Addr: metricsPort, Handler: promhttp.Handler()}
@@ -848,9 +1929,48 @@ var _ = Describe("RunnerManager", func() {
 		MetricsShouldNotExist(metricsURL, "meows_runner_busy")
 		runnerList, _ := githubClientFactory.ListRunners(ctx, rp1.GetOwner(), rp1.GetRepository(), nil)
 		Expect(runnerList).To(BeEmpty())
+		By("checking the cached runners are discarded")
+		Expect(runnerCacheLen(runnerManager)).To(Equal(0))
+	})
+
+	It("should delete the runners of all runnerpools when stopping all", func() {
+		By("preparing fake clients")
+		runnerPodClient := runner.NewFakeClient()
+		githubClientFactory := github.NewFakeClientFactory()
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), githubClientFactory, runnerPodClient, time.Second)
+
+		By("starting runnerpool managers")
+		rp1 := makeRunnerPoolWithRepository("rp1", "test-ns1", "owner/repo1")
+		rp2 := makeRunnerPoolWithRepository("rp2", "test-ns2", "owner/repo2")
+		Expect(runnerManager.StartOrUpdate(rp1, nil)).To(Succeed())
+		Expect(runnerManager.StartOrUpdate(rp2, nil)).To(Succeed())
+		githubClientFactory.SetRunners(map[string][]*github.Runner{
+			"owner/repo1": {
+				{Name: "pod1", ID: 1, Online: true, Busy: false, Labels: []string{"test-ns1/rp1"}},
+			},
+			"owner/repo2": {
+				{Name: "pod2", ID: 2, Online: true, Busy: false, Labels: []string{"test-ns2/rp2"}},
+			},
+		})
+
+		By("stopping all runnerpool managers")
+		stopCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		runnerManager.StopAll(stopCtx)
+		for _, rp := range []*meowsv1alpha1.RunnerPool{rp1, rp2} {
+			runnerList, err := githubClientFactory.ListRunners(ctx, rp.GetOwner(), rp.GetRepository(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runnerList).To(BeEmpty())
+		}
+		Expect(runnerCacheLen(runnerManager)).To(Equal(0))
+		Expect(runnerManager.StartOrUpdate(rp1, nil)).NotTo(Succeed())
 	})
 })
 
+func runnerCacheLen(m RunnerManager) int {
+	return m.(*runnerManager).runnerCache.len()
+}
+
 func MetricsShouldNotExist(url, name string) {
 	_, err := metrics.FetchGauge(context.Background(), url, name)
 	ExpectWithOffset(1, err).Should(MatchError(metrics.ErrNotExist))
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RunnerJobReconciler deletes the RunnerJobs whose TTL has passed.
+type RunnerJobReconciler struct {
+	client.Client
+	log logr.Logger
+}
+
+// NewRunnerJobReconciler creates RunnerJobReconciler
+func NewRunnerJobReconciler(log logr.Logger, client client.Client) *RunnerJobReconciler {
+	return &RunnerJobReconciler{
+		Client: client,
+		log:    log.WithName("RunnerJob"),
+	}
+}
+
+//+kubebuilder:rbac:groups=meows.cybozu.com,resources=runnerjobs,verbs=get;list;watch;delete
+
+// Reconcile deletes the RunnerJob if it is expired, or requeues it until it expires.
+func (r *RunnerJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("runnerjob", req.NamespacedName)
+
+	job := &meowsv1alpha1.RunnerJob{}
+	if err := r.Get(ctx, req.NamespacedName, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "failed to get RunnerJob")
+		return ctrl.Result{}, err
+	}
+	if job.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	remaining := time.Until(job.ExpirationTime())
+	if remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	err := r.Delete(ctx, job)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "failed to delete expired RunnerJob")
+		return ctrl.Result{}, err
+	}
+	log.Info("deleted expired RunnerJob")
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RunnerJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&meowsv1alpha1.RunnerJob{}).
+		Complete(r)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	meowsv1alpha1 "github.com/cybozu-go/meows/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+var _ = Describe("RunnerJob reconciler", func() {
+	namespace := "runnerjob-ns"
+
+	ctx := context.Background()
+	var mgrCancel context.CancelFunc
+
+	BeforeEach(func() {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:         scheme,
+			LeaderElection: false,
+			Metrics:        metricsserver.Options{BindAddress: "0"},
+			Controller: config.Controller{
+				SkipNameValidation: ptr.To(true),
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		r := NewRunnerJobReconciler(ctrl.Log, mgr.GetClient())
+		Expect(r.SetupWithManager(mgr)).To(Succeed())
+
+		var mgrCtx context.Context
+		mgrCtx, mgrCancel = context.WithCancel(context.Background())
+		go func() {
+			err := mgr.Start(mgrCtx)
+			if err != nil {
+				panic(err)
+			}
+		}()
+		time.Sleep(time.Second)
+	})
+
+	AfterEach(func() {
+		mgrCancel()
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	It("should create Namespace", func() {
+		createNamespaces(ctx, namespace)
+	})
+
+	It("should delete RunnerJobs after their TTL", func() {
+		makeRunnerJob := func(name string, finishedAt time.Time, ttl int32) *meowsv1alpha1.RunnerJob {
+			return &meowsv1alpha1.RunnerJob{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec: meowsv1alpha1.RunnerJobSpec{
+					RunnerPool:              "rp1",
+					PodName:                 name,
+					FinishedAt:              &metav1.Time{Time: finishedAt},
+					TTLSecondsAfterFinished: ttl,
+				},
+			}
+		}
+
+		By("creating RunnerJobs")
+		Expect(k8sClient.Create(ctx, makeRunnerJob("expired", time.Now().Add(-2*time.Hour), 3600))).To(Succeed())
+		Expect(k8sClient.Create(ctx, makeRunnerJob("expiring", time.Now(), 3))).To(Succeed())
+		Expect(k8sClient.Create(ctx, makeRunnerJob("alive", time.Now(), 3600))).To(Succeed())
+
+		By("checking the expired RunnerJob is deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "expired", Namespace: namespace}, &meowsv1alpha1.RunnerJob{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		By("checking the RunnerJob is deleted when its TTL passes")
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "expiring", Namespace: namespace}, &meowsv1alpha1.RunnerJob{})).To(Succeed())
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "expiring", Namespace: namespace}, &meowsv1alpha1.RunnerJob{})
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		By("checking the RunnerJob within its TTL is kept")
+		Consistently(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Name: "alive", Namespace: namespace}, &meowsv1alpha1.RunnerJob{})
+		}, 3*time.Second).Should(Succeed())
+	})
+})
@@ -7,7 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"slices"
 	"time"
 
 	constants "github.com/cybozu-go/meows"
@@ -18,16 +18,25 @@ import (
 	"github.com/google/go-cmp/cmp"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
+// drainCheckInterval is the interval to check the busy runners of a deleted RunnerPool.
+const drainCheckInterval = 5 * time.Second
+
 // RunnerPoolReconciler reconciles a RunnerPool object
 type RunnerPoolReconciler struct {
 	client.Client
@@ -38,13 +47,14 @@ type RunnerPoolReconciler struct {
 	secretUpdater      SecretUpdater
 	organizationRegexp *regexp.Regexp
 	repositoryRegexp   *regexp.Regexp
+	defaultNetwork     github.NetworkConfig
 }
 
 // NewRunnerPoolReconciler creates RunnerPoolReconciler
 func NewRunnerPoolReconciler(
 	log logr.Logger, client client.Client, scheme *runtime.Scheme, runnerImage string,
 	runnerManager RunnerManager, secretUpdater SecretUpdater,
-	organizationRegexp, repositoryRegexp *regexp.Regexp) *RunnerPoolReconciler {
+	organizationRegexp, repositoryRegexp *regexp.Regexp, defaultNetwork github.NetworkConfig) *RunnerPoolReconciler {
 	return &RunnerPoolReconciler{
 		Client:             client,
 		log:                log.WithName("RunnerPool"),
@@ -54,12 +64,15 @@ func NewRunnerPoolReconciler(
 		secretUpdater:      secretUpdater,
 		organizationRegexp: organizationRegexp,
 		repositoryRegexp:   repositoryRegexp,
+		defaultNetwork:     defaultNetwork,
 	}
 }
 
 //+kubebuilder:rbac:groups=meows.cybozu.com,resources=runnerpools,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=meows.cybozu.com,resources=runnerpools/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=core,resources=podtemplates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
@@ -78,6 +91,7 @@ func (r *RunnerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.Error(err, "unable to get RunnerPool")
 		return ctrl.Result{}, err
 	}
+	oldStatus := rp.Status.DeepCopy()
 
 	if rp.ObjectMeta.DeletionTimestamp != nil {
 		if !controllerutil.ContainsFinalizer(rp, constants.RunnerPoolFinalizer) {
@@ -86,8 +100,34 @@ func (r *RunnerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 		log.Info("start finalizing RunnerPool")
 
+		// Runners are registered only after the RunnerPool is bound.
+		if rp.Status.Bound {
+			drained, err := r.drain(ctx, log, rp)
+			if err != nil {
+				log.Error(err, "failed to drain runners")
+				return ctrl.Result{}, err
+			}
+			if !drained {
+				return ctrl.Result{RequeueAfter: drainCheckInterval}, nil
+			}
+		}
+
 		if err := r.runnerManager.Stop(rp); err != nil {
 			log.Error(err, "failed to stop runner manager")
+			if rp.Status.Drain != nil {
+				rp.Status.Drain.RemovalError = err.Error()
+				if err := r.Status().Update(ctx, rp); err != nil {
+					log.Error(err, "failed to update status")
+				}
+			}
+			return ctrl.Result{}, err
+		}
+
+		// The pods in the Deployment are garbage-collected with it, but the unlinked pods are deleted here
+		// not to be left after the finalizer is removed.
+		// In the direct pod management, no pods belong to a Deployment, so all the runner pods are deleted.
+		if err := r.deleteUnlinkedPods(ctx, log, rp); err != nil {
+			log.Error(err, "failed to delete unlinked pods")
 			return ctrl.Result{}, err
 		}
 
@@ -118,25 +158,50 @@ func (r *RunnerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	isContinuation, err := r.reconcileSecret(ctx, log, rp)
-	if err != nil {
-		log.Error(err, "failed to reconcile secret")
-		return ctrl.Result{}, err
-	}
-	if err := r.secretUpdater.Start(rp, cred); err != nil {
-		log.Error(err, "failed to start secret updater")
-		return ctrl.Result{}, err
+	if rp.Spec.JITConfig {
+		// The runner manager gives a configuration to each runner pod, so the registration token is not needed.
+		if err := r.secretUpdater.Stop(rp); err != nil {
+			log.Error(err, "failed to stop secret updater")
+			return ctrl.Result{}, err
+		}
+		if err := r.deleteSecret(ctx, rp); err != nil {
+			log.Error(err, "failed to delete secret")
+			return ctrl.Result{}, err
+		}
+	} else {
+		isContinuation, err := r.reconcileSecret(ctx, log, rp)
+		if err != nil {
+			log.Error(err, "failed to reconcile secret")
+			return ctrl.Result{}, err
+		}
+		if err := r.secretUpdater.Start(rp, cred); err != nil {
+			log.Error(err, "failed to start secret updater")
+			return ctrl.Result{}, err
+		}
+		if !isContinuation {
+			log.Info("wait for the secret to be issued by secret updater")
+			return ctrl.Result{
+				Requeue:      true,
+				RequeueAfter: 10 * time.Second,
+			}, nil
+		}
 	}
-	if !isContinuation {
-		log.Info("wait for the secret to be issued by secret updater")
-		return ctrl.Result{
-			Requeue:      true,
-			RequeueAfter: 10 * time.Second,
-		}, nil
+
+	if rp.IsDirectPodManagement() {
+		// The runner manager creates the runner pods from the PodTemplate.
+		if err := r.reconcilePodTemplate(ctx, log, rp, cred.Network); err != nil {
+			log.Error(err, "failed to reconcile pod template")
+			return ctrl.Result{}, err
+		}
+	} else {
+		if err := r.reconcileDeployment(ctx, log, rp, cred.Network); err != nil {
+			log.Error(err, "failed to reconcile deployment")
+			return ctrl.Result{}, err
+		}
 	}
 
-	if err := r.reconcileDeployment(ctx, log, rp); err != nil {
-		log.Error(err, "failed to reconcile deployment")
+	if err := r.reconcileBusyRunnerPDB(ctx, log, rp); err != nil {
+		log.Error(err, "failed to reconcile pdb")
 		return ctrl.Result{}, err
 	}
 
@@ -146,6 +211,17 @@ func (r *RunnerPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	rp.Status.Bound = true
+	if !rp.IsDirectPodManagement() {
+		unlinkedPods, err := r.listUnlinkedPods(ctx, rp)
+		if err != nil {
+			log.Error(err, "failed to list unlinked pods")
+			return ctrl.Result{}, err
+		}
+		rp.Status.UnlinkedPods = int32(len(unlinkedPods))
+	}
+	if equality.Semantic.DeepEqual(oldStatus, &rp.Status) {
+		return ctrl.Result{}, nil
+	}
 	if err := r.Status().Update(ctx, rp); err != nil {
 		log.Error(err, "failed to update status")
 		return ctrl.Result{}, err
@@ -159,9 +235,58 @@ func (r *RunnerPoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		For(&meowsv1alpha1.RunnerPool{}).
 		Owns(&corev1.Secret{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.PodTemplate{}).
+		Owns(&corev1.Pod{}, builder.WithPredicates(unlinkedPodPredicate)).
+		Owns(&policyv1.PodDisruptionBudget{}).
 		Complete(r)
 }
 
+// unlinkedPodPredicate passes only the events of the runner pods that change the number of the unlinked pods.
+// The runner pods are updated frequently by the runner manager, and the other updates do not need reconciliation.
+var unlinkedPodPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		_, oldLinked := e.ObjectOld.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]
+		_, newLinked := e.ObjectNew.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]
+		return oldLinked != newLinked
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// listUnlinkedPods lists the runner pods unlinked from the Deployment.
+func (r *RunnerPoolReconciler) listUnlinkedPods(ctx context.Context, rp *meowsv1alpha1.RunnerPool) ([]*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList, client.InNamespace(rp.Namespace), client.MatchingLabels(labelSet(rp)))
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []*corev1.Pod
+	for i := range podList.Items {
+		po := &podList.Items[i]
+		if _, ok := po.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+			continue
+		}
+		pods = append(pods, po)
+	}
+	return pods, nil
+}
+
+func (r *RunnerPoolReconciler) deleteUnlinkedPods(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) error {
+	pods, err := r.listUnlinkedPods(ctx, rp)
+	if err != nil {
+		return err
+	}
+	for _, po := range pods {
+		if err := r.Delete(ctx, po); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.Info("deleted unlinked pod", "pod", po.Name)
+	}
+	return nil
+}
+
 func labelSet(rp *meowsv1alpha1.RunnerPool) map[string]string {
 	labels := map[string]string{
 		constants.AppNameLabelKey:      constants.AppName,
@@ -185,59 +310,108 @@ func mergeMap(m1, m2 map[string]string) map[string]string {
 	return m
 }
 
-func readAppKeySecret(s *corev1.Secret) (*github.ClientCredential, error) {
-	appIDstr, ok := s.Data[constants.CredentialSecretDataAppID]
-	if !ok {
-		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppID)
+func (r *RunnerPoolReconciler) getGitHubCredential(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) (*github.ClientCredential, error) {
+	s := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{
+		Name:      rp.GetCredentialSecretName(),
+		Namespace: rp.Namespace,
+	}, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential secret; %w", err)
 	}
-	appID, err := strconv.Atoi(string(appIDstr))
+
+	cred, err := github.NewCredentialFromSecretData(s.Data)
 	if err != nil {
-		return nil, fmt.Errorf("invalid %s value; %w", constants.CredentialSecretDataAppID, err)
+		return nil, err
+	}
+
+	cred.Network = r.defaultNetwork.Merge(cred.Network)
+	if err := cred.Network.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid network configuration in credential secret; %w", err)
+	}
+	return cred, nil
+}
+
+// drain scales the runner pods of the deleted RunnerPool to zero and waits for the busy runners to finish their jobs.
+// It returns true when no runners are busy or the deletion grace period has passed.
+func (r *RunnerPoolReconciler) drain(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) (bool, error) {
+	if rp.Status.Drain == nil {
+		log.Info("start draining runners")
+		rp.Status.Drain = &meowsv1alpha1.DrainStatus{StartedAt: metav1.Now()}
+		if err := r.Status().Update(ctx, rp); err != nil {
+			return false, fmt.Errorf("failed to update status; %w", err)
+		}
 	}
+	drain := rp.Status.Drain
 
-	insIDstr, ok := s.Data[constants.CredentialSecretDataAppInstallationID]
-	if !ok {
-		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppInstallationID)
+	gracePeriod, _ := time.ParseDuration(rp.Spec.DeletionGracePeriod)
+	if time.Now().After(drain.StartedAt.Add(gracePeriod)) {
+		if len(drain.BusyRunners) != 0 && !drain.TimedOut {
+			log.Info("deletion grace period has passed; remove busy runners", "busy_runners", drain.BusyRunners)
+			drain.TimedOut = true
+			if err := r.Status().Update(ctx, rp); err != nil {
+				return false, fmt.Errorf("failed to update status; %w", err)
+			}
+		}
+		return true, nil
+	}
+
+	cred, err := r.getGitHubCredential(ctx, log, rp)
+	if apierrors.IsNotFound(err) {
+		// The runners cannot be checked without the credential.
+		log.Info("skip draining runners because the credential secret is not found")
+		return true, nil
 	}
-	insID, err := strconv.Atoi(string(insIDstr))
 	if err != nil {
-		return nil, fmt.Errorf("invalid %s value; %w", constants.CredentialSecretDataAppInstallationID, err)
+		return false, err
 	}
 
-	key, ok := s.Data[constants.CredentialSecretDataAppPrivateKey]
-	if !ok {
-		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppPrivateKey)
+	busyRunners, observed, err := r.runnerManager.Drain(rp, cred)
+	if err != nil {
+		return false, err
+	}
+	if !observed {
+		// The runner manager has not unlinked the busy runner pods from the Deployment yet.
+		return false, nil
 	}
 
-	return &github.ClientCredential{
-		AppID:             int64(appID),
-		AppInstallationID: int64(insID),
-		PrivateKey:        key,
-	}, nil
-}
+	if err := r.scaleDeploymentToZero(ctx, log, rp); err != nil {
+		return false, err
+	}
 
-func (r *RunnerPoolReconciler) getGitHubCredential(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) (*github.ClientCredential, error) {
-	secretName := constants.DefaultCredentialSecretName
-	if rp.Spec.CredentialSecretName != "" {
-		secretName = rp.Spec.CredentialSecretName
+	if !slices.Equal(drain.BusyRunners, busyRunners) {
+		drain.BusyRunners = busyRunners
+		if err := r.Status().Update(ctx, rp); err != nil {
+			return false, fmt.Errorf("failed to update status; %w", err)
+		}
 	}
+	if len(busyRunners) != 0 {
+		log.Info("waiting for busy runners to finish their jobs", "busy_runners", busyRunners)
+		return false, nil
+	}
+	log.Info("draining runners is completed")
+	return true, nil
+}
 
-	s := &corev1.Secret{}
-	err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      secretName,
-		Namespace: rp.Namespace,
-	}, s)
+func (r *RunnerPoolReconciler) scaleDeploymentToZero(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) error {
+	d := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: rp.Namespace, Name: rp.GetRunnerDeploymentName()}, d)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get credential secret; %w", err)
+		return err
 	}
-
-	if pat, ok := s.Data[constants.CredentialSecretDataPATToken]; ok {
-		return &github.ClientCredential{
-			PersonalAccessToken: string(pat),
-		}, nil
+	if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+		return nil
 	}
 
-	return readAppKeySecret(s)
+	d.Spec.Replicas = ptr.To[int32](0)
+	if err := r.Update(ctx, d); err != nil {
+		return err
+	}
+	log.Info("scaled deployment to zero")
+	return nil
 }
 
 func (r *RunnerPoolReconciler) validation(ctx context.Context, rp *meowsv1alpha1.RunnerPool) error {
@@ -276,7 +450,14 @@ func (r *RunnerPoolReconciler) reconcileSecret(ctx context.Context, log logr.Log
 	return false, r.Create(ctx, s)
 }
 
-func (r *RunnerPoolReconciler) reconcileDeployment(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) error {
+func (r *RunnerPoolReconciler) deleteSecret(ctx context.Context, rp *meowsv1alpha1.RunnerPool) error {
+	s := &corev1.Secret{}
+	s.SetName(rp.GetRunnerSecretName())
+	s.SetNamespace(rp.Namespace)
+	return client.IgnoreNotFound(r.Delete(ctx, s))
+}
+
+func (r *RunnerPoolReconciler) reconcileDeployment(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool, network github.NetworkConfig) error {
 	d := &appsv1.Deployment{}
 	d.SetNamespace(rp.GetNamespace())
 	d.SetName(rp.GetRunnerDeploymentName())
@@ -288,40 +469,134 @@ func (r *RunnerPoolReconciler) reconcileDeployment(ctx context.Context, log logr
 		d.Labels = mergeMap(d.GetLabels(), labelSet(rp))
 		d.Spec.Selector = &metav1.LabelSelector{MatchLabels: labelSet(rp)}
 
-		d.Spec.Template.Labels = mergeMap(d.Spec.Template.GetLabels(), rp.Spec.Template.ObjectMeta.Labels)
-		d.Spec.Template.Labels = mergeMap(d.Spec.Template.GetLabels(), labelSet(rp))
-		d.Spec.Template.Annotations = mergeMap(d.Spec.Template.GetAnnotations(), rp.Spec.Template.ObjectMeta.Annotations)
-
 		d.Spec.Replicas = ptr.To[int32](rp.Spec.Replicas)
-		d.Spec.Template.Spec.ServiceAccountName = rp.Spec.Template.ServiceAccountName
-		d.Spec.Template.Spec.ImagePullSecrets = rp.Spec.Template.ImagePullSecrets
-		if rp.Spec.Template.AutomountServiceAccountToken != nil {
-			d.Spec.Template.Spec.AutomountServiceAccountToken = rp.Spec.Template.AutomountServiceAccountToken
+		if err := r.updatePodTemplate(&d.Spec.Template, rp, network); err != nil {
+			return err
+		}
+
+		updated = d.Spec.DeepCopy()
+		return ctrl.SetControllerReference(rp, d, r.scheme)
+	})
+
+	if err != nil {
+		log.Error(err, "failed to reconcile deployment")
+		return err
+	}
+	switch op {
+	case controllerutil.OperationResultCreated:
+		log.Info("reconciled deployment", "operation", string(op))
+	case controllerutil.OperationResultUpdated:
+		// The deployment update should occur only when the users update their RunnerPool CR.
+		// If this log shows frequently, users need to review their RunnerPool CR.
+		log.Info("reconciled deployment", "operation", string(op), "diff", cmp.Diff(orig, updated))
+	}
+	return nil
+}
+
+// reconcileBusyRunnerPDB creates the PDB protecting the busy runner pods in the pool-level protection, and deletes it otherwise.
+// The runner manager gives the busy label to the busy runner pods.
+func (r *RunnerPoolReconciler) reconcileBusyRunnerPDB(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool) error {
+	pdb := &policyv1.PodDisruptionBudget{}
+	pdb.SetNamespace(rp.GetNamespace())
+	pdb.SetName(rp.GetBusyRunnerPDBName())
+
+	if !rp.IsPoolDisruptionProtection() {
+		err := r.Delete(ctx, pdb)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Info("deleted pdb")
+		return nil
+	}
+
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, pdb, func() error {
+		pdb.Labels = mergeMap(pdb.GetLabels(), labelSet(rp))
+		pdb.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: mergeMap(labelSet(rp), map[string]string{constants.BusyLabelKey: "true"}),
+		}
+		pdb.Spec.MinAvailable = nil
+		pdb.Spec.MaxUnavailable = ptr.To(intstr.FromInt32(0))
+		return ctrl.SetControllerReference(rp, pdb, r.scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("reconciled pdb", "operation", string(op))
+	}
+	return nil
+}
+
+func (r *RunnerPoolReconciler) reconcilePodTemplate(ctx context.Context, log logr.Logger, rp *meowsv1alpha1.RunnerPool, network github.NetworkConfig) error {
+	pt := &corev1.PodTemplate{}
+	pt.SetNamespace(rp.GetNamespace())
+	pt.SetName(rp.GetRunnerPodTemplateName())
+
+	var orig, updated *corev1.PodTemplateSpec
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, pt, func() error {
+		orig = pt.Template.DeepCopy()
+
+		pt.Labels = mergeMap(pt.GetLabels(), labelSet(rp))
+		if err := r.updatePodTemplate(&pt.Template, rp, network); err != nil {
+			return err
 		}
 
-		varDir := "var-dir"
-		workDir := "work-dir"
-		volumes := append(rp.Spec.Template.Volumes, corev1.Volume{
-			Name: varDir,
+		updated = pt.Template.DeepCopy()
+		return ctrl.SetControllerReference(rp, pt, r.scheme)
+	})
+
+	if err != nil {
+		log.Error(err, "failed to reconcile pod template")
+		return err
+	}
+	switch op {
+	case controllerutil.OperationResultCreated:
+		log.Info("reconciled pod template", "operation", string(op))
+	case controllerutil.OperationResultUpdated:
+		log.Info("reconciled pod template", "operation", string(op), "diff", cmp.Diff(orig, updated))
+	}
+	return nil
+}
+
+// updatePodTemplate updates the template of the runner pods according to the RunnerPool.
+func (r *RunnerPoolReconciler) updatePodTemplate(tmpl *corev1.PodTemplateSpec, rp *meowsv1alpha1.RunnerPool, network github.NetworkConfig) error {
+	tmpl.Labels = mergeMap(tmpl.GetLabels(), rp.Spec.Template.ObjectMeta.Labels)
+	tmpl.Labels = mergeMap(tmpl.GetLabels(), labelSet(rp))
+	tmpl.Annotations = mergeMap(tmpl.GetAnnotations(), rp.Spec.Template.ObjectMeta.Annotations)
+
+	tmpl.Spec.ServiceAccountName = rp.Spec.Template.ServiceAccountName
+	tmpl.Spec.ImagePullSecrets = rp.Spec.Template.ImagePullSecrets
+	if rp.Spec.Template.AutomountServiceAccountToken != nil {
+		tmpl.Spec.AutomountServiceAccountToken = rp.Spec.Template.AutomountServiceAccountToken
+	}
+
+	varDir := "var-dir"
+	workDir := "work-dir"
+	volumes := append(rp.Spec.Template.Volumes, corev1.Volume{
+		Name: varDir,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	})
+	if rp.Spec.WorkVolume == nil {
+		// use emptyDir (default)
+		volumes = append(volumes, corev1.Volume{
+			Name: workDir,
 			VolumeSource: corev1.VolumeSource{
 				EmptyDir: &corev1.EmptyDirVolumeSource{},
 			},
 		})
-		if rp.Spec.WorkVolume == nil {
-			// use emptyDir (default)
-			volumes = append(volumes, corev1.Volume{
-				Name: workDir,
-				VolumeSource: corev1.VolumeSource{
-					EmptyDir: &corev1.EmptyDirVolumeSource{},
-				},
-			})
-		} else {
-			volumes = append(volumes, corev1.Volume{
-				Name:         workDir,
-				VolumeSource: *rp.Spec.WorkVolume,
-			})
-		}
+	} else {
+		volumes = append(volumes, corev1.Volume{
+			Name:         workDir,
+			VolumeSource: *rp.Spec.WorkVolume,
+		})
+	}
 
+	if !rp.Spec.JITConfig {
 		volumes = append(volumes, corev1.Volume{
 			Name: rp.GetRunnerSecretName(),
 			VolumeSource: corev1.VolumeSource{
@@ -330,71 +605,58 @@ func (r *RunnerPoolReconciler) reconcileDeployment(ctx context.Context, log logr
 				},
 			},
 		})
-		d.Spec.Template.Spec.Volumes = volumes
+	}
+	tmpl.Spec.Volumes = volumes
 
-		d.Spec.Template.Spec.NodeSelector = rp.Spec.Template.NodeSelector
-		d.Spec.Template.Spec.Tolerations = rp.Spec.Template.Tolerations
+	tmpl.Spec.NodeSelector = rp.Spec.Template.NodeSelector
+	tmpl.Spec.Tolerations = rp.Spec.Template.Tolerations
 
-		r.addRunnerContainerIfNotExists(d)
-		runnerContainer := r.findRunnerContainer(d)
+	r.addRunnerContainerIfNotExists(&tmpl.Spec)
+	runnerContainer := r.findRunnerContainer(&tmpl.Spec)
 
-		// Update the runner container.
-		if rp.Spec.Template.RunnerContainer.Image != "" {
-			runnerContainer.Image = rp.Spec.Template.RunnerContainer.Image
-		} else {
-			runnerContainer.Image = r.runnerImage
-		}
-		if rp.Spec.Template.RunnerContainer.ImagePullPolicy != "" {
-			runnerContainer.ImagePullPolicy = rp.Spec.Template.RunnerContainer.ImagePullPolicy
-		}
-		runnerContainer.SecurityContext = rp.Spec.Template.RunnerContainer.SecurityContext
-		runnerContainer.Resources = rp.Spec.Template.RunnerContainer.Resources
-		runnerContainer.Ports = r.makeRunnerContainerPorts()
-
-		volumeMounts := append(rp.Spec.Template.RunnerContainer.VolumeMounts, corev1.VolumeMount{
-			Name:      varDir,
-			MountPath: constants.RunnerVarDirPath,
-		}, corev1.VolumeMount{
-			Name:      workDir,
-			MountPath: constants.RunnerWorkDirPath,
-		})
+	// Update the runner container.
+	if rp.Spec.Template.RunnerContainer.Image != "" {
+		runnerContainer.Image = rp.Spec.Template.RunnerContainer.Image
+	} else {
+		runnerContainer.Image = r.runnerImage
+	}
+	if rp.Spec.Template.RunnerContainer.ImagePullPolicy != "" {
+		runnerContainer.ImagePullPolicy = rp.Spec.Template.RunnerContainer.ImagePullPolicy
+	}
+	runnerContainer.SecurityContext = rp.Spec.Template.RunnerContainer.SecurityContext
+	runnerContainer.Resources = rp.Spec.Template.RunnerContainer.Resources
+	runnerContainer.Ports = r.makeRunnerContainerPorts()
+
+	volumeMounts := append(rp.Spec.Template.RunnerContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      varDir,
+		MountPath: constants.RunnerVarDirPath,
+	}, corev1.VolumeMount{
+		Name:      workDir,
+		MountPath: constants.RunnerWorkDirPath,
+	})
+	if !rp.Spec.JITConfig {
 		volumeMounts = append(volumeMounts, corev1.VolumeMount{
 			Name:      rp.GetRunnerSecretName(),
 			ReadOnly:  true,
 			MountPath: filepath.Join(constants.RunnerVarDirPath, constants.SecretsDirName),
 		})
-		runnerContainer.VolumeMounts = volumeMounts
-
-		runnerContainer.EnvFrom = rp.Spec.Template.RunnerContainer.EnvFrom
-
-		env, err := r.makeRunnerContainerEnv(rp)
-		if err != nil {
-			return err
-		}
-		runnerContainer.Env = env
+	}
+	runnerContainer.VolumeMounts = volumeMounts
 
-		updated = d.Spec.DeepCopy()
-		return ctrl.SetControllerReference(rp, d, r.scheme)
-	})
+	runnerContainer.EnvFrom = rp.Spec.Template.RunnerContainer.EnvFrom
 
+	env, err := r.makeRunnerContainerEnv(rp, network)
 	if err != nil {
-		log.Error(err, "failed to reconcile deployment")
 		return err
 	}
-	switch op {
-	case controllerutil.OperationResultCreated:
-		log.Info("reconciled deployment", "operation", string(op))
-	case controllerutil.OperationResultUpdated:
-		// The deployment update should occur only when the users update their RunnerPool CR.
-		// If this log shows frequently, users need to review their RunnerPool CR.
-		log.Info("reconciled deployment", "operation", string(op), "diff", cmp.Diff(orig, updated))
-	}
+	runnerContainer.Env = env
+
 	return nil
 }
 
-func (r *RunnerPoolReconciler) findRunnerContainer(d *appsv1.Deployment) *corev1.Container {
-	for i := range d.Spec.Template.Spec.Containers {
-		c := &d.Spec.Template.Spec.Containers[i]
+func (r *RunnerPoolReconciler) findRunnerContainer(spec *corev1.PodSpec) *corev1.Container {
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
 		if c.Name == constants.RunnerContainerName {
 			return c
 		}
@@ -402,8 +664,8 @@ func (r *RunnerPoolReconciler) findRunnerContainer(d *appsv1.Deployment) *corev1
 	return nil
 }
 
-func (r *RunnerPoolReconciler) addRunnerContainerIfNotExists(d *appsv1.Deployment) {
-	if c := r.findRunnerContainer(d); c != nil {
+func (r *RunnerPoolReconciler) addRunnerContainerIfNotExists(spec *corev1.PodSpec) {
+	if c := r.findRunnerContainer(spec); c != nil {
 		// When the runner container already exists, nothing to do.
 		return
 	}
@@ -412,12 +674,15 @@ func (r *RunnerPoolReconciler) addRunnerContainerIfNotExists(d *appsv1.Deploymen
 	c := corev1.Container{
 		Name: constants.RunnerContainerName,
 	}
-	d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, c)
+	spec.Containers = append(spec.Containers, c)
 }
 
-func (r *RunnerPoolReconciler) makeRunnerContainerEnv(rp *meowsv1alpha1.RunnerPool) ([]corev1.EnvVar, error) {
+func (r *RunnerPoolReconciler) makeRunnerContainerEnv(rp *meowsv1alpha1.RunnerPool, network github.NetworkConfig) ([]corev1.EnvVar, error) {
 	option := runner.Option{
 		SetupCommand: rp.Spec.SetupCommand,
+		SetupTimeout: rp.Spec.SetupTimeout,
+		SetupRetries: int(rp.Spec.SetupRetries),
+		JITConfig:    rp.Spec.JITConfig,
 	}
 	optionJson, err := json.Marshal(&option)
 	if err != nil {
@@ -465,6 +730,19 @@ func (r *RunnerPoolReconciler) makeRunnerContainerEnv(rp *meowsv1alpha1.RunnerPo
 		})
 	}
 
+	if len(network.CABundle) != 0 {
+		envs = append(envs, corev1.EnvVar{
+			Name:  constants.CABundleEnvName,
+			Value: string(network.CABundle),
+		})
+	}
+	// The proxy URL differs from the default only when it is given by the credential secret.
+	var proxySecretName string
+	if network.ProxyURL != r.defaultNetwork.ProxyURL {
+		proxySecretName = rp.GetCredentialSecretName()
+	}
+	envs = append(envs, makeProxyEnv(network, proxySecretName, rp.Spec.Template.RunnerContainer.Env)...)
+
 	// NOTE:
 	// We need not ignore the reserved environment variables here.
 	// Since the reserved environment variables are checked in the validating webhook.
@@ -473,6 +751,48 @@ func (r *RunnerPoolReconciler) makeRunnerContainerEnv(rp *meowsv1alpha1.RunnerPo
 	return envs, nil
 }
 
+// makeProxyEnv makes the environment variables for the proxy, so that jobs also access GitHub via the proxy.
+// The variables specified by users are not overridden.
+// If proxySecretName is not empty, the proxy URL is referred from the secret, because it may contain the credential of the proxy.
+func makeProxyEnv(network github.NetworkConfig, proxySecretName string, userEnv []corev1.EnvVar) []corev1.EnvVar {
+	if network.ProxyURL == "" {
+		return nil
+	}
+
+	userEnvNames := map[string]bool{}
+	for _, e := range userEnv {
+		userEnvNames[e.Name] = true
+	}
+
+	var envs []corev1.EnvVar
+	add := func(env corev1.EnvVar, names ...string) {
+		for _, name := range names {
+			if userEnvNames[name] {
+				continue
+			}
+			env.Name = name
+			envs = append(envs, env)
+		}
+	}
+	proxyEnv := corev1.EnvVar{Value: network.ProxyURL}
+	if proxySecretName != "" {
+		proxyEnv = corev1.EnvVar{
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: proxySecretName},
+					Key:                  constants.CredentialSecretDataProxyURL,
+				},
+			},
+		}
+	}
+	// Both upper and lower case names are added, because some tools only read one of them.
+	add(proxyEnv, "HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy")
+	if network.NoProxy != "" {
+		add(corev1.EnvVar{Value: network.NoProxy}, "NO_PROXY", "no_proxy")
+	}
+	return envs
+}
+
 func (r *RunnerPoolReconciler) makeRunnerContainerPorts() []corev1.ContainerPort {
 	return []corev1.ContainerPort{
 		{
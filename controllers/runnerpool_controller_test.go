@@ -2,9 +2,12 @@ package controllers
 
 import (
 	"context"
+	"encoding/pem"
 	"errors"
+	"net/http/httptest"
 	"path/filepath"
 	"regexp"
+	"sync"
 	"time"
 
 	constants "github.com/cybozu-go/meows"
@@ -15,43 +18,91 @@ import (
 	. "github.com/onsi/gomega/gstruct"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
 
 type runnerManagerMock struct {
+	mu          sync.Mutex
 	started     map[string]bool
 	githubCreds map[string]*github.ClientCredential
+	busyRunners map[string][]string
+	draining    map[string]bool
 }
 
 func newRunnerManagerMock() *runnerManagerMock {
 	return &runnerManagerMock{
 		started:     map[string]bool{},
 		githubCreds: map[string]*github.ClientCredential{},
+		busyRunners: map[string][]string{},
+		draining:    map[string]bool{},
 	}
 }
 
+func (m *runnerManagerMock) setBusyRunners(rpNamespacedName string, names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.busyRunners[rpNamespacedName] = names
+}
+
+func (m *runnerManagerMock) isStarted(rpNamespacedName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started[rpNamespacedName]
+}
+
 func (m *runnerManagerMock) StartOrUpdate(rp *meowsv1alpha1.RunnerPool, cred *github.ClientCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	rpNamespacedName := rp.Namespace + "/" + rp.Name
 	m.started[rpNamespacedName] = true
 	m.githubCreds[rpNamespacedName] = cred
 	return nil
 }
 
+func (m *runnerManagerMock) Drain(rp *meowsv1alpha1.RunnerPool, cred *github.ClientCredential) ([]string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rpNamespacedName := rp.Namespace + "/" + rp.Name
+	m.started[rpNamespacedName] = true
+	m.githubCreds[rpNamespacedName] = cred
+	m.draining[rpNamespacedName] = true
+	return m.busyRunners[rpNamespacedName], true, nil
+}
+
 func (m *runnerManagerMock) Stop(rp *meowsv1alpha1.RunnerPool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	rpNamespacedName := rp.Namespace + "/" + rp.Name
 	delete(m.started, rpNamespacedName)
 	delete(m.githubCreds, rpNamespacedName)
+	delete(m.draining, rpNamespacedName)
+	return nil
+}
+
+func (m *runnerManagerMock) StopAll(ctx context.Context) {
+}
+
+func (m *runnerManagerMock) Targets() []RunnerTarget {
 	return nil
 }
 
-func (m *runnerManagerMock) StopAll() {
+func (m *runnerManagerMock) ListRunners(ctx context.Context, t RunnerTarget) ([]*github.Runner, error) {
+	return nil, nil
+}
+
+func (m *runnerManagerMock) RemoveRunner(ctx context.Context, t RunnerTarget, runnerID int64) error {
+	return nil
 }
 
 type secretUpdaterMock struct {
@@ -138,6 +189,7 @@ var _ = Describe("RunnerPool reconciler", func() {
 			SecretUpdater(mockUpdater),
 			regexp.MustCompile(`^test-org$`),
 			regexp.MustCompile(`^test-org/.*`),
+			github.NetworkConfig{NoProxy: "localhost"},
 		)
 		Expect(r.SetupWithManager(mgr)).To(Succeed())
 
@@ -363,6 +415,8 @@ var _ = Describe("RunnerPool reconciler", func() {
 		rp.Spec.CredentialSecretName = "github-cred-foo"
 		rp.Spec.Replicas = 3
 		rp.Spec.SetupCommand = []string{"command", "arg1", "args2"}
+		rp.Spec.SetupTimeout = "10m"
+		rp.Spec.SetupRetries = 2
 		rp.Spec.Notification.Slack.Enable = true
 		rp.Spec.Notification.Slack.Channel = "#test"
 		rp.Spec.Notification.ExtendDuration = "20m"
@@ -564,7 +618,7 @@ var _ = Describe("RunnerPool reconciler", func() {
 				}),
 				"3": MatchFields(IgnoreExtras, Fields{
 					"Name":  Equal(constants.RunnerOptionEnvName),
-					"Value": Equal("{\"setup_command\":[\"command\",\"arg1\",\"args2\"]}"),
+					"Value": Equal("{\"setup_command\":[\"command\",\"arg1\",\"args2\"],\"setup_timeout\":\"10m\",\"setup_retries\":2}"),
 				}),
 				"4": MatchFields(IgnoreExtras, Fields{
 					"Name":  Equal(constants.RunnerOrgEnvName),
@@ -640,6 +694,322 @@ var _ = Describe("RunnerPool reconciler", func() {
 		Expect(mockUpdater.started).NotTo(HaveKey(namespace + "/" + runnerPoolName))
 	})
 
+	It("should drain busy runners before finalizing RunnerPool", func() {
+		rpNamespacedName := namespace + "/" + runnerPoolName
+
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.Replicas = 2
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("waiting the RunnerPool become Bound")
+		Eventually(func() error {
+			rp := new(meowsv1alpha1.RunnerPool)
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			if !rp.Status.Bound {
+				return errors.New(`status "bound" should be true`)
+			}
+			return nil
+		}).Should(Succeed())
+
+		By("creating a pod unlinked from the Deployment")
+		po := makePod("unlinked-pod", namespace, runnerPoolName)
+		Expect(ctrl.SetControllerReference(rp, po, scheme)).To(Succeed())
+		Expect(k8sClient.Create(ctx, po)).To(Succeed())
+		Eventually(func(g Gomega) {
+			rp := new(meowsv1alpha1.RunnerPool)
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp)).To(Succeed())
+			g.Expect(rp.Status.UnlinkedPods).To(BeNumerically("==", 1))
+		}).Should(Succeed())
+
+		By("deleting the RunnerPool while a runner is busy")
+		mockManager.setBusyRunners(rpNamespacedName, []string{"runner-1"})
+		Expect(k8sClient.Delete(ctx, rp)).To(Succeed())
+
+		By("checking the progress of draining")
+		Eventually(func(g Gomega) {
+			rp := new(meowsv1alpha1.RunnerPool)
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp)).To(Succeed())
+			g.Expect(rp.Status.Drain).To(PointTo(MatchFields(IgnoreExtras, Fields{
+				"BusyRunners": Equal([]string{"runner-1"}),
+				"TimedOut":    BeFalse(),
+			})))
+
+			d := new(appsv1.Deployment)
+			g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, d)).To(Succeed())
+			g.Expect(d.Spec.Replicas).To(PointTo(BeNumerically("==", 0)))
+		}).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, new(meowsv1alpha1.RunnerPool))
+		}, 3*time.Second).Should(Succeed())
+		Expect(mockManager.isStarted(rpNamespacedName)).To(BeTrue())
+
+		By("finishing the job")
+		mockManager.setBusyRunners(rpNamespacedName, nil)
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+		Expect(mockManager.isStarted(rpNamespacedName)).To(BeFalse())
+
+		By("checking the unlinked pod is deleted")
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: "unlinked-pod", Namespace: namespace}, new(corev1.Pod))
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should finalize RunnerPool after the deletion grace period even if runners are busy", func() {
+		rpNamespacedName := namespace + "/" + runnerPoolName
+
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.DeletionGracePeriod = "3s"
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("waiting the RunnerPool become Bound")
+		Eventually(func() error {
+			rp := new(meowsv1alpha1.RunnerPool)
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			if !rp.Status.Bound {
+				return errors.New(`status "bound" should be true`)
+			}
+			return nil
+		}).Should(Succeed())
+
+		By("deleting the RunnerPool while a runner is busy")
+		mockManager.setBusyRunners(rpNamespacedName, []string{"runner-1"})
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+		Expect(mockManager.isStarted(rpNamespacedName)).To(BeFalse())
+	})
+
+	It("should create Deployment without the registration token in just-in-time configuration mode", func() {
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.JITConfig = true
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("waiting the RunnerPool become Bound")
+		Eventually(func() error {
+			rp := new(meowsv1alpha1.RunnerPool)
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			if !rp.Status.Bound {
+				return errors.New(`status "bound" should be true`)
+			}
+			return nil
+		}).Should(Succeed())
+		time.Sleep(wait) // Wait for the reconciliation to run a few times. Please check the controller's log.
+
+		By("checking the Secret is not created")
+		s := new(corev1.Secret)
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, s)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		By("getting the created Deployment")
+		d := new(appsv1.Deployment)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, d)).To(Succeed())
+
+		By("confirming the Deployment's manifests")
+		Expect(d.Spec.Template.Spec.Volumes).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0": MatchFields(IgnoreExtras, Fields{
+				"Name": Equal("var-dir"),
+			}),
+			"1": MatchFields(IgnoreExtras, Fields{
+				"Name": Equal("work-dir"),
+			}),
+		}))
+		Expect(d.Spec.Template.Spec.Containers).To(HaveLen(1))
+		Expect(d.Spec.Template.Spec.Containers[0].VolumeMounts).To(MatchAllElementsWithIndex(IndexIdentity, Elements{
+			"0": MatchFields(IgnoreExtras, Fields{
+				"Name": Equal("var-dir"),
+			}),
+			"1": MatchFields(IgnoreExtras, Fields{
+				"Name": Equal("work-dir"),
+			}),
+		}))
+		Expect(d.Spec.Template.Spec.Containers[0].Env).To(ContainElement(MatchFields(IgnoreExtras, Fields{
+			"Name":  Equal(constants.RunnerOptionEnvName),
+			"Value": Equal(`{"jit_config":true}`),
+		})))
+
+		By("checking only the runner manager is started")
+		Expect(mockManager.started).To(HaveKey(namespace + "/" + runnerPoolName))
+		Expect(mockUpdater.started).NotTo(HaveKey(namespace + "/" + runnerPoolName))
+
+		By("deleting the created RunnerPool")
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+	})
+
+	It("should create PodTemplate instead of Deployment in the direct pod management", func() {
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.PodManagement = meowsv1alpha1.PodManagementDirect
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("waiting the RunnerPool become Bound")
+		Eventually(func() error {
+			rp := new(meowsv1alpha1.RunnerPool)
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			if !rp.Status.Bound {
+				return errors.New(`status "bound" should be true`)
+			}
+			return nil
+		}).Should(Succeed())
+		time.Sleep(wait) // Wait for the reconciliation to run a few times. Please check the controller's log.
+
+		By("checking the Deployment is not created")
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, new(appsv1.Deployment))
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		By("getting the created PodTemplate")
+		pt := new(corev1.PodTemplate)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, pt)).To(Succeed())
+		Expect(metav1.IsControlledBy(pt, rp)).To(BeTrue())
+		Expect(pt.Template.Labels).To(MatchAllKeys(Keys{
+			constants.AppNameLabelKey:      Equal(constants.AppName),
+			constants.AppComponentLabelKey: Equal(constants.AppComponentRunner),
+			constants.AppInstanceLabelKey:  Equal(runnerPoolName),
+		}))
+		Expect(pt.Template.Spec.Containers).To(HaveLen(1))
+		Expect(pt.Template.Spec.Containers[0].Name).To(Equal(constants.RunnerContainerName))
+		Expect(pt.Template.Spec.Containers[0].Image).To(Equal(defaultRunnerImage))
+
+		By("checking the runner manager is started")
+		Expect(mockManager.isStarted(namespace + "/" + runnerPoolName)).To(BeTrue())
+
+		By("deleting the created RunnerPool")
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+		Expect(k8sClient.Delete(ctx, pt)).To(Succeed())
+	})
+
+	It("should create PDB for busy runner pods in the pool-level disruption protection", func() {
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.DenyDisruption = true
+		rp.Spec.DisruptionProtection = meowsv1alpha1.DisruptionProtectionPool
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("checking the PDB is created")
+		pdb := new(policyv1.PodDisruptionBudget)
+		Eventually(func() error {
+			return k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName + "-busy", Namespace: namespace}, pdb)
+		}).Should(Succeed())
+		Expect(metav1.IsControlledBy(pdb, rp)).To(BeTrue())
+		Expect(pdb.Spec.Selector.MatchLabels).To(MatchAllKeys(Keys{
+			constants.AppNameLabelKey:      Equal(constants.AppName),
+			constants.AppComponentLabelKey: Equal(constants.AppComponentRunner),
+			constants.AppInstanceLabelKey:  Equal(runnerPoolName),
+			constants.BusyLabelKey:         Equal("true"),
+		}))
+		Expect(pdb.Spec.MaxUnavailable).To(PointTo(Equal(intstr.FromInt32(0))))
+
+		By("disabling the disruption protection")
+		Eventually(func() error {
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			rp.Spec.DenyDisruption = false
+			return k8sClient.Update(ctx, rp)
+		}).Should(Succeed())
+
+		By("checking the PDB is deleted")
+		Eventually(func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName + "-busy", Namespace: namespace}, new(policyv1.PodDisruptionBudget))
+			return apierrors.IsNotFound(err)
+		}).Should(BeTrue())
+
+		By("deleting the created RunnerPool")
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+	})
+
+	It("should create Deployment with the network configuration of the credential secret", func() {
+		By("creating credential secret with proxy and CA bundle")
+		ts := httptest.NewTLSServer(nil)
+		ts.Close()
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+		proxySecret := new(corev1.Secret)
+		proxySecret.SetName("github-cred-proxy")
+		proxySecret.SetNamespace(namespace)
+		proxySecret.StringData = map[string]string{
+			"token":     "dummy-pat",
+			"proxy-url": "http://proxy.example.com:3128",
+			"ca-bundle": string(caBundle),
+		}
+		Expect(k8sClient.Create(ctx, proxySecret)).To(Succeed())
+
+		By("deploying RunnerPool resource")
+		rp := makeRunnerPool(runnerPoolName, namespace)
+		rp.Spec.Repository = "test-org/test-repo"
+		rp.Spec.CredentialSecretName = "github-cred-proxy"
+		rp.Spec.Template.RunnerContainer.Env = []corev1.EnvVar{
+			{Name: "http_proxy", Value: "http://user-proxy.example.com:3128"},
+		}
+		Expect(k8sClient.Create(ctx, rp)).To(Succeed())
+
+		By("waiting the RunnerPool become Bound")
+		Eventually(func() error {
+			rp := new(meowsv1alpha1.RunnerPool)
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: runnerPoolName, Namespace: namespace}, rp); err != nil {
+				return err
+			}
+			if !rp.Status.Bound {
+				return errors.New(`status "bound" should be true`)
+			}
+			return nil
+		}).Should(Succeed())
+
+		By("checking the credential passed to the runner manager")
+		Expect(mockManager.githubCreds[namespace+"/"+runnerPoolName]).To(PointTo(MatchFields(IgnoreExtras, Fields{
+			"PersonalAccessToken": Equal("dummy-pat"),
+			"Network": MatchAllFields(Fields{
+				"ProxyURL": Equal("http://proxy.example.com:3128"),
+				"NoProxy":  Equal("localhost"),
+				"CABundle": Equal(caBundle),
+			}),
+		})))
+
+		By("confirming the environment variables of the runner container")
+		d := new(appsv1.Deployment)
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, d)).To(Succeed())
+		Expect(d.Spec.Template.Spec.Containers).To(HaveLen(1))
+		env := map[string]corev1.EnvVar{}
+		for _, e := range d.Spec.Template.Spec.Containers[0].Env {
+			Expect(env).NotTo(HaveKey(e.Name))
+			env[e.Name] = e
+		}
+		// The proxy URL is referred from the secret because it may contain the credential of the proxy.
+		proxyFromSecret := MatchAllFields(Fields{
+			"Name":  Ignore(),
+			"Value": BeEmpty(),
+			"ValueFrom": PointTo(MatchFields(IgnoreExtras, Fields{
+				"SecretKeyRef": PointTo(MatchFields(IgnoreExtras, Fields{
+					"LocalObjectReference": Equal(corev1.LocalObjectReference{Name: "github-cred-proxy"}),
+					"Key":                  Equal(constants.CredentialSecretDataProxyURL),
+				})),
+			})),
+		})
+		Expect(env).To(MatchKeys(IgnoreExtras, Keys{
+			constants.CABundleEnvName: HaveField("Value", string(caBundle)),
+			"HTTPS_PROXY":             proxyFromSecret,
+			"https_proxy":             proxyFromSecret,
+			"HTTP_PROXY":              proxyFromSecret,
+			"http_proxy":              HaveField("Value", "http://user-proxy.example.com:3128"),
+			"NO_PROXY":                HaveField("Value", "localhost"),
+			"no_proxy":                HaveField("Value", "localhost"),
+		}))
+
+		By("deleting the created RunnerPool")
+		deleteRunnerPool(ctx, runnerPoolName, namespace)
+	})
+
 	It("should not create Deployment from unpermitted repository", func() {
 		By("deploying RunnerPool resource")
 		rp := makeRunnerPool(runnerPoolName, namespace)
@@ -685,4 +1055,18 @@ var _ = Describe("RunnerPool reconciler", func() {
 		By("deleting the created RunnerPool")
 		deleteRunnerPool(ctx, runnerPoolName, namespace)
 	})
+
+	It("should pass only the pod events that change the number of the unlinked pods", func() {
+		linked := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Labels: map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: "foo"}}}
+		updated := linked.DeepCopy()
+		updated.Annotations = map[string]string{"foo": "bar"}
+		unlinked := linked.DeepCopy()
+		delete(unlinked.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+
+		Expect(unlinkedPodPredicate.Create(event.CreateEvent{Object: linked})).To(BeTrue())
+		Expect(unlinkedPodPredicate.Delete(event.DeleteEvent{Object: unlinked})).To(BeTrue())
+		Expect(unlinkedPodPredicate.Update(event.UpdateEvent{ObjectOld: linked, ObjectNew: unlinked})).To(BeTrue())
+		Expect(unlinkedPodPredicate.Update(event.UpdateEvent{ObjectOld: linked, ObjectNew: updated})).To(BeFalse())
+		Expect(unlinkedPodPredicate.Generic(event.GenericEvent{Object: linked})).To(BeFalse())
+	})
 })
@@ -35,6 +35,8 @@ type secretUpdater struct {
 	mu                  sync.Mutex
 	stopped             bool
 	processes           map[string]*updateProcess
+	// githubCreds are the credentials used by the processes. They are released to the factory when the processes stop.
+	githubCreds map[string]*github.ClientCredential
 }
 
 func NewSecretUpdater(log logr.Logger, k8sClient client.Client, githubClientFactory github.ClientFactory) SecretUpdater {
@@ -43,6 +45,7 @@ func NewSecretUpdater(log logr.Logger, k8sClient client.Client, githubClientFact
 		k8sClient:           k8sClient,
 		githubClientFactory: githubClientFactory,
 		processes:           map[string]*updateProcess{},
+		githubCreds:         map[string]*github.ClientCredential{},
 	}
 }
 
@@ -68,6 +71,7 @@ func (u *secretUpdater) Start(rp *meowsv1alpha1.RunnerPool, cred *github.ClientC
 		)
 		process.start()
 		u.processes[rpNamespacedName] = process
+		u.githubCreds[rpNamespacedName] = cred
 	}
 	return nil
 }
@@ -82,6 +86,8 @@ func (u *secretUpdater) Stop(rp *meowsv1alpha1.RunnerPool) error {
 			return err
 		}
 		delete(u.processes, rpNamespacedName)
+		u.githubClientFactory.Release(u.githubCreds[rpNamespacedName])
+		delete(u.githubCreds, rpNamespacedName)
 	}
 	return nil
 }
@@ -90,10 +96,12 @@ func (u *secretUpdater) StopAll() {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	for _, process := range u.processes {
+	for rpNamespacedName, process := range u.processes {
 		process.stop()
+		u.githubClientFactory.Release(u.githubCreds[rpNamespacedName])
 	}
 	u.processes = nil
+	u.githubCreds = nil
 	u.stopped = true
 }
 
@@ -187,9 +195,9 @@ func (p *updateProcess) run(ctx context.Context) {
 
 		expiresAt, err := p.updateSecret(ctx, s)
 		if err != nil {
-			p.log.Error(err, "failed to update secret, retry after 1 minutes")
+			waitTime = updateRetryWait(err)
+			p.log.Error(err, "failed to update secret", "retryAfter", waitTime.String())
 			p.retryCountMetrics.Inc()
-			waitTime = time.Minute
 			continue
 		}
 
@@ -198,6 +206,21 @@ func (p *updateProcess) run(ctx context.Context) {
 	}
 }
 
+// updateRetryWait returns the time to wait before retrying to update the secret.
+func updateRetryWait(err error) time.Duration {
+	if retryAfter, ok := github.RetryAfter(err); ok {
+		if d := time.Until(retryAfter); d > time.Minute {
+			return d
+		}
+		return time.Minute
+	}
+	if errors.Is(err, github.ErrUnauthorized) || errors.Is(err, github.ErrNotFound) {
+		// The credential or the RunnerPool should be fixed by users, so retrying soon does not help.
+		return 5 * time.Minute
+	}
+	return time.Minute
+}
+
 func (p *updateProcess) getSecret(ctx context.Context) (*corev1.Secret, error) {
 	s := new(corev1.Secret)
 	err := p.k8sClient.Get(ctx, types.NamespacedName{Namespace: p.rpNamespace, Name: p.secretName}, s)
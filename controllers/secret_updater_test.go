@@ -10,6 +10,7 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -146,4 +147,27 @@ var _ = Describe("SecretUpdater", func() {
 			secretUpdater.Stop(rp)
 		}
 	})
+
+	It("should share and release github clients", func() {
+		factory := github.NewCachingFactory(github.NewFakeClientFactory())
+		secretUpdater := NewSecretUpdater(ctrl.Log, k8sClient, factory)
+		runnerManager := NewRunnerManager(ctrl.Log, k8sClient, scheme, record.NewFakeRecorder(100), factory, nil, time.Hour)
+		cred := &github.ClientCredential{PersonalAccessToken: "token"}
+
+		rp1 := makeRunnerPoolWithOrganization("shared1", "secretupdater-test", "test-org")
+		rp2 := makeRunnerPoolWithOrganization("shared2", "secretupdater-test", "test-org")
+
+		By("starting processes with the same credential")
+		Expect(secretUpdater.Start(rp1, cred)).To(Succeed())
+		Expect(runnerManager.StartOrUpdate(rp1, cred)).To(Succeed())
+		Expect(secretUpdater.Start(rp2, cred)).To(Succeed())
+		Expect(factory.Len()).To(Equal(1))
+
+		By("stopping processes")
+		Expect(secretUpdater.Stop(rp1)).To(Succeed())
+		Expect(runnerManager.Stop(rp1)).To(Succeed())
+		Expect(factory.Len()).To(Equal(1))
+		Expect(secretUpdater.Stop(rp2)).To(Succeed())
+		Expect(factory.Len()).To(Equal(0))
+	})
 })
@@ -0,0 +1,101 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+)
+
+// Fingerprint returns a string that differs for each credential and network configuration.
+// Unlike Identity, it changes when the secret is rotated.
+func (c *ClientCredential) Fingerprint() string {
+	if c == nil {
+		return ""
+	}
+	h := sha256.New()
+	write := func(b []byte) {
+		binary.Write(h, binary.BigEndian, int64(len(b)))
+		h.Write(b)
+	}
+	write([]byte(c.PersonalAccessToken))
+	binary.Write(h, binary.BigEndian, c.AppID)
+	binary.Write(h, binary.BigEndian, c.AppInstallationID)
+	write(c.PrivateKey)
+	write([]byte(c.PrivateKeyPath))
+	write([]byte(c.Network.ProxyURL))
+	write([]byte(c.Network.NoProxy))
+	write(c.Network.CABundle)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CachingFactory is a ClientFactory that shares a Client among the users of the same credential.
+// The clients of a GitHub App share the transport, so an installation access token is issued and refreshed only once for them.
+//
+// Each call of New should be paired with a call of Release.
+// A Client is discarded when all of its users release it.
+type CachingFactory struct {
+	base ClientFactory
+
+	mu      sync.Mutex
+	entries map[string]*cachingFactoryEntry // key: fingerprint of the credential
+}
+
+type cachingFactoryEntry struct {
+	client Client
+	refs   int
+}
+
+// NewCachingFactory creates CachingFactory that creates Clients by base.
+func NewCachingFactory(base ClientFactory) *CachingFactory {
+	return &CachingFactory{
+		base:    base,
+		entries: map[string]*cachingFactoryEntry{},
+	}
+}
+
+// New returns the Client for the credential, creating it if it is not cached.
+func (f *CachingFactory) New(cred *ClientCredential) (Client, error) {
+	key := cred.Fingerprint()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.entries[key]
+	if !ok {
+		c, err := f.base.New(cred)
+		if err != nil {
+			return nil, err
+		}
+		e = &cachingFactoryEntry{client: c}
+		f.entries[key] = e
+	}
+	e.refs++
+	return e.client, nil
+}
+
+// Release discards the cached Client for the credential if no one uses it anymore.
+func (f *CachingFactory) Release(cred *ClientCredential) {
+	key := cred.Fingerprint()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.entries[key]
+	if !ok {
+		return
+	}
+	e.refs--
+	if e.refs <= 0 {
+		delete(f.entries, key)
+		f.base.Release(cred)
+	}
+}
+
+// Len returns the number of the cached Clients.
+func (f *CachingFactory) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.entries)
+}
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"testing"
+)
+
+type countingFactory struct {
+	*FakeClientFactory
+	newCount     int
+	releaseCount int
+}
+
+func (f *countingFactory) New(cred *ClientCredential) (Client, error) {
+	f.newCount++
+	return f.FakeClientFactory.New(cred)
+}
+
+func (f *countingFactory) Release(cred *ClientCredential) {
+	f.releaseCount++
+}
+
+func TestCachingFactory(t *testing.T) {
+	base := &countingFactory{FakeClientFactory: NewFakeClientFactory()}
+	f := NewCachingFactory(base)
+
+	pat := &ClientCredential{PersonalAccessToken: "token"}
+	app := &ClientCredential{AppID: 1, AppInstallationID: 2, PrivateKey: []byte("key")}
+
+	c1, _ := f.New(pat)
+	c2, _ := f.New(&ClientCredential{PersonalAccessToken: "token"})
+	if c1 != c2 {
+		t.Error("the client should be shared for the same credential")
+	}
+	c3, _ := f.New(app)
+	if c1 == c3 {
+		t.Error("the client should not be shared for different credentials")
+	}
+	if base.newCount != 2 || f.Len() != 2 {
+		t.Errorf("unexpected number of clients: created %d, cached %d", base.newCount, f.Len())
+	}
+
+	f.Release(pat)
+	if f.Len() != 2 || base.releaseCount != 0 {
+		t.Error("the client should not be evicted while it is used")
+	}
+	f.Release(pat)
+	if f.Len() != 1 || base.releaseCount != 1 {
+		t.Error("the client should be evicted when it is not used")
+	}
+	f.Release(pat)
+	if f.Len() != 1 || base.releaseCount != 1 {
+		t.Error("releasing an evicted client should do nothing")
+	}
+
+	c4, _ := f.New(pat)
+	if c1 == c4 || base.newCount != 3 {
+		t.Error("the evicted client should be created again")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	creds := []*ClientCredential{
+		{PersonalAccessToken: "token"},
+		{PersonalAccessToken: "token2"},
+		{AppID: 1, AppInstallationID: 2, PrivateKey: []byte("key")},
+		{AppID: 1, AppInstallationID: 2, PrivateKey: []byte("key2")},
+		{AppID: 1, AppInstallationID: 3, PrivateKey: []byte("key")},
+		{AppID: 1, AppInstallationID: 2, PrivateKeyPath: "key"},
+		{PersonalAccessToken: "token", Network: NetworkConfig{ProxyURL: "http://proxy.example.com"}},
+		{PersonalAccessToken: "token", Network: NetworkConfig{NoProxy: "localhost"}},
+		{PersonalAccessToken: "token", Network: NetworkConfig{CABundle: []byte("ca")}},
+	}
+
+	fingerprints := map[string]int{}
+	for i, cred := range creds {
+		fp := cred.Fingerprint()
+		if j, ok := fingerprints[fp]; ok {
+			t.Errorf("the fingerprints of %d and %d are the same", j, i)
+		}
+		fingerprints[fp] = i
+
+		copied := *cred
+		if copied.Fingerprint() != fp {
+			t.Errorf("the fingerprint of %d is not stable", i)
+		}
+	}
+}
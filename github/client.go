@@ -2,19 +2,38 @@ package github
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bradleyfalzon/ghinstallation"
+	constants "github.com/cybozu-go/meows"
+	"github.com/cybozu-go/meows/metrics"
 	"github.com/google/go-github/v41/github"
 	"golang.org/x/oauth2"
 )
 
 const statusOnline = "online"
 
+// Endpoint names used as the label of the API request metrics.
+// Organization and repository endpoints share the same name to keep the number of labels small.
+const (
+	endpointCreateRegistrationToken = "create_registration_token"
+	endpointListRunners             = "list_runners"
+	endpointRemoveRunner            = "remove_runner"
+	endpointGenerateJITConfig       = "generate_jitconfig"
+)
+
+// defaultRunnerGroupID is the ID of the "Default" runner group.
+// Repository level runners always belong to this group.
+const defaultRunnerGroupID = 1
+
 type Runner struct {
 	ID     int64
 	Name   string
@@ -37,7 +56,8 @@ func convert(ghRunner *github.Runner) *Runner {
 	}
 }
 
-func (r *Runner) hasLabels(labels []string) bool {
+// HasLabels returns true if the runner has all of the labels.
+func (r *Runner) HasLabels(labels []string) bool {
 	actualLabelMap := map[string]struct{}{}
 	for _, l := range r.Labels {
 		actualLabelMap[l] = struct{}{}
@@ -50,11 +70,20 @@ func (r *Runner) hasLabels(labels []string) bool {
 	return true
 }
 
+// RateLimit represents the rate limit status of GitHub API reported by the last response.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
 // Client generates token for GitHub Action selfhosted runner
 type Client interface {
 	CreateRegistrationToken(context.Context, string, string) (*github.RegistrationToken, error)
 	ListRunners(context.Context, string, string, []string) ([]*Runner, error)
 	RemoveRunner(context.Context, string, string, int64) error
+	GenerateJITConfig(context.Context, string, string, string, []string) (string, error)
+	RateLimit() RateLimit
 }
 
 type ClientCredential struct {
@@ -63,69 +92,143 @@ type ClientCredential struct {
 	AppInstallationID   int64
 	PrivateKey          []byte
 	PrivateKeyPath      string
+
+	// Network is the configuration of the network to access GitHub with this credential.
+	Network NetworkConfig
+}
+
+// Identity returns a string that identifies the credential without exposing the secret.
+// GitHub applies the rate limit per GitHub App installation or per user, so it is also used to group API usage.
+func (c *ClientCredential) Identity() string {
+	switch {
+	case c == nil:
+		return ""
+	case len(c.PersonalAccessToken) != 0:
+		sum := sha256.Sum256([]byte(c.PersonalAccessToken))
+		return "pat-" + hex.EncodeToString(sum[:])[:8]
+	default:
+		return fmt.Sprintf("app-%d-%d", c.AppID, c.AppInstallationID)
+	}
 }
 
 // ClientFactory is a factory of Clients.
 type ClientFactory interface {
 	New(*ClientCredential) (Client, error)
+	// Release tells the factory that the Client created for the credential is no longer used.
+	Release(*ClientCredential)
 }
 
-type defaultFactory struct{}
+type defaultFactory struct {
+	baseURL *url.URL
+}
 
-func NewFactory() ClientFactory {
-	return &defaultFactory{}
+// NewFactory creates a ClientFactory for the GitHub REST API at baseURL.
+// If baseURL is empty, the clients access https://api.github.com/.
+// It can be the API of GitHub Enterprise Server (e.g. https://github.example.com/api/v3/) or a fake server for testing.
+func NewFactory(baseURL string) (ClientFactory, error) {
+	if baseURL == "" {
+		return &defaultFactory{}, nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %s; %w", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %s; scheme and host are required", baseURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &defaultFactory{baseURL: u}, nil
 }
 
+func (f *defaultFactory) Release(_ *ClientCredential) {}
+
 func (f *defaultFactory) New(cred *ClientCredential) (Client, error) {
+	var c *clientWrapper
+	base, err := newTransport(cred.Network)
+	if err != nil {
+		return nil, err
+	}
 	switch {
 	case len(cred.PersonalAccessToken) != 0:
-		return newClientFromPAT(cred.PersonalAccessToken), nil
+		c = newClientFromPAT(f.baseURL, base, cred.PersonalAccessToken)
 	case len(cred.PrivateKey) != 0:
-		return newClientFromAppKey(cred.AppID, cred.AppInstallationID, cred.PrivateKey)
+		c, err = newClientFromAppKey(f.baseURL, base, cred.AppID, cred.AppInstallationID, cred.PrivateKey)
 	case len(cred.PrivateKeyPath) != 0:
-		return newClientFromAppKeyFile(cred.AppID, cred.AppInstallationID, cred.PrivateKeyPath)
+		c, err = newClientFromAppKeyFile(f.baseURL, base, cred.AppID, cred.AppInstallationID, cred.PrivateKeyPath)
 	default:
 		return nil, errors.New("invalid credential")
 	}
+	if err != nil {
+		return nil, err
+	}
+	c.credential = cred.Identity()
+	return c, nil
 }
 
 // clientWrapper is a wrapper of GitHub client.
 type clientWrapper struct {
 	client *github.Client
+	// credential is the identity of the credential used for the rate limit metrics.
+	credential string
+
+	mu   sync.Mutex
+	rate RateLimit
+}
+
+// newClientWrapper creates clientWrapper that accesses baseURL, or the default URL if baseURL is nil.
+func newClientWrapper(httpClient *http.Client, baseURL *url.URL) *clientWrapper {
+	client := github.NewClient(httpClient)
+	if baseURL != nil {
+		client.BaseURL = baseURL
+	}
+	return &clientWrapper{
+		client: client,
+	}
+}
+
+// ghinstallationBaseURL returns the base URL for ghinstallation, which does not have the trailing slash.
+func ghinstallationBaseURL(baseURL *url.URL) string {
+	return strings.TrimSuffix(baseURL.String(), "/")
 }
 
 // newClientFromPAT creates GitHub Actions Client from a personal access token (PAT).
-func newClientFromPAT(pat string) Client {
-	ctx := context.Background()
+func newClientFromPAT(baseURL *url.URL, base http.RoundTripper, pat string) *clientWrapper {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: pat},
 	)
-	tc := oauth2.NewClient(ctx, ts)
-	return &clientWrapper{
-		client: github.NewClient(tc),
+	tc := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: ts,
+			Base:   newRetryTransport(base),
+		},
 	}
+	return newClientWrapper(tc, baseURL)
 }
 
 // newClientFromAppKey creates GitHub Actions Client from a private key of a GitHub app.
-func newClientFromAppKey(appID, appInstallationID int64, privateKey []byte) (Client, error) {
-	rt, err := ghinstallation.New(http.DefaultTransport, appID, appInstallationID, privateKey)
+func newClientFromAppKey(baseURL *url.URL, base http.RoundTripper, appID, appInstallationID int64, privateKey []byte) (*clientWrapper, error) {
+	rt, err := ghinstallation.New(newRetryTransport(base), appID, appInstallationID, privateKey)
 	if err != nil {
 		return nil, err
 	}
-	return &clientWrapper{
-		client: github.NewClient(&http.Client{Transport: rt}),
-	}, nil
+	if baseURL != nil {
+		rt.BaseURL = ghinstallationBaseURL(baseURL)
+	}
+	return newClientWrapper(&http.Client{Transport: rt}, baseURL), nil
 }
 
 // newClientFromAPIKey creates GitHub Actions Client from a private key of a GitHub app.
-func newClientFromAppKeyFile(appID, appInstallationID int64, privateKeyPath string) (Client, error) {
-	rt, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, appID, appInstallationID, privateKeyPath)
+func newClientFromAppKeyFile(baseURL *url.URL, base http.RoundTripper, appID, appInstallationID int64, privateKeyPath string) (*clientWrapper, error) {
+	rt, err := ghinstallation.NewKeyFromFile(newRetryTransport(base), appID, appInstallationID, privateKeyPath)
 	if err != nil {
 		return nil, err
 	}
-	return &clientWrapper{
-		client: github.NewClient(&http.Client{Transport: rt}),
-	}, nil
+	if baseURL != nil {
+		rt.BaseURL = ghinstallationBaseURL(baseURL)
+	}
+	return newClientWrapper(&http.Client{Transport: rt}, baseURL), nil
 }
 
 // CreateRegistrationToken creates an Actions token to register self-hosted runner to the organization.
@@ -133,6 +236,7 @@ func (c *clientWrapper) CreateRegistrationToken(ctx context.Context, owner, repo
 	var token *github.RegistrationToken
 	var res *github.Response
 	var err error
+	start := time.Now()
 	if repo == "" {
 		token, res, err = c.client.Actions.CreateOrganizationRegistrationToken(
 			ctx,
@@ -145,12 +249,13 @@ func (c *clientWrapper) CreateRegistrationToken(ctx context.Context, owner, repo
 			repo,
 		)
 	}
+	c.record(endpointCreateRegistrationToken, start, res)
 	if e, ok := err.(*url.Error); ok {
 		// When url.Error came back, it was because the raw Responce leaked out as a string.
 		return nil, fmt.Errorf("failed to create registration token: %s %s", e.Op, e.URL)
 	}
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 	if res.StatusCode != http.StatusCreated {
 		return nil, fmt.Errorf("invalid status code %d", res.StatusCode)
@@ -168,6 +273,7 @@ func (c *clientWrapper) ListRunners(ctx context.Context, owner, repo string, lab
 		var list *github.Runners
 		var res *github.Response
 		var err error
+		start := time.Now()
 		if repo == "" {
 			list, res, err = c.client.Actions.ListOrganizationRunners(
 				ctx,
@@ -182,8 +288,9 @@ func (c *clientWrapper) ListRunners(ctx context.Context, owner, repo string, lab
 				&opts,
 			)
 		}
+		c.record(endpointListRunners, start, res)
 		if err != nil {
-			return nil, err
+			return nil, classifyError(err)
 		}
 		if res.StatusCode != http.StatusOK {
 			return nil, fmt.Errorf("invalid status code %d", res.StatusCode)
@@ -191,7 +298,7 @@ func (c *clientWrapper) ListRunners(ctx context.Context, owner, repo string, lab
 
 		for _, ghRunner := range list.Runners {
 			r := convert(ghRunner)
-			if !r.hasLabels(labels) {
+			if !r.HasLabels(labels) {
 				continue
 			}
 			runners = append(runners, r)
@@ -210,6 +317,7 @@ func (c *clientWrapper) ListRunners(ctx context.Context, owner, repo string, lab
 func (c *clientWrapper) RemoveRunner(ctx context.Context, owner, repo string, runnerID int64) error {
 	var res *github.Response
 	var err error
+	start := time.Now()
 	if repo == "" {
 		res, err = c.client.Actions.RemoveOrganizationRunner(
 			ctx,
@@ -224,11 +332,83 @@ func (c *clientWrapper) RemoveRunner(ctx context.Context, owner, repo string, ru
 			runnerID,
 		)
 	}
+	c.record(endpointRemoveRunner, start, res)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 	if res.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("invalid status code %d", res.StatusCode)
 	}
 	return nil
 }
+
+type jitConfigRequest struct {
+	Name          string   `json:"name"`
+	RunnerGroupID int64    `json:"runner_group_id"`
+	Labels        []string `json:"labels"`
+	WorkFolder    string   `json:"work_folder,omitempty"`
+}
+
+type jitConfigResponse struct {
+	EncodedJITConfig string `json:"encoded_jit_config"`
+}
+
+// GenerateJITConfig registers a just-in-time runner and returns the encoded configuration for it.
+// The runner is registered immediately, so it is listed as an offline runner until the listener starts with the configuration.
+// go-github does not support this API yet, so the request is built manually.
+func (c *clientWrapper) GenerateJITConfig(ctx context.Context, owner, repo, name string, labels []string) (string, error) {
+	u := fmt.Sprintf("repos/%s/%s/actions/runners/generate-jitconfig", owner, repo)
+	if repo == "" {
+		u = fmt.Sprintf("orgs/%s/actions/runners/generate-jitconfig", owner)
+	}
+	req, err := c.client.NewRequest(http.MethodPost, u, &jitConfigRequest{
+		Name:          name,
+		RunnerGroupID: defaultRunnerGroupID,
+		Labels:        labels,
+		WorkFolder:    constants.RunnerWorkDirPath,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	config := new(jitConfigResponse)
+	start := time.Now()
+	res, err := c.client.Do(ctx, req, config)
+	c.record(endpointGenerateJITConfig, start, res)
+	if err != nil {
+		return "", classifyError(err)
+	}
+	if res.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("invalid status code %d", res.StatusCode)
+	}
+	return config.EncodedJITConfig, nil
+}
+
+// RateLimit returns the rate limit status reported by the last response.
+func (c *clientWrapper) RateLimit() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// record updates the metrics and the rate limit status with the response of an API request started at start.
+func (c *clientWrapper) record(endpoint string, start time.Time, res *github.Response) {
+	var status int
+	if res != nil && res.Response != nil {
+		status = res.StatusCode
+	}
+	metrics.ObserveGitHubRequest(endpoint, status, time.Since(start))
+
+	if res == nil || res.Rate.Limit == 0 {
+		return
+	}
+	metrics.UpdateGitHubRateLimitMetrics(c.credential, res.Rate.Remaining, res.Rate.Reset.Time)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rate = RateLimit{
+		Limit:     res.Rate.Limit,
+		Remaining: res.Rate.Remaining,
+		Reset:     res.Rate.Reset.Time,
+	}
+}
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/meows/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestClientMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics.InitControllerMetrics(registry)
+
+	reset := time.Now().Add(time.Hour).Unix()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		switch r.URL.Path {
+		case "/repos/owner/repo/actions/runners":
+			w.Write([]byte(`{"total_count": 0, "runners": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	baseURL, _ := url.Parse(ts.URL + "/")
+	c := newClientFromPAT(baseURL, http.DefaultTransport, "token")
+	c.credential = "pat-test"
+
+	ctx := context.Background()
+	if _, err := c.ListRunners(ctx, "owner", "repo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.ListRunners(ctx, "owner", "repo", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.RemoveRunner(ctx, "owner", "repo", 1); err == nil {
+		t.Fatal("error should be returned")
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]*dto.MetricFamily{}
+	for _, mf := range families {
+		found[mf.GetName()] = mf
+	}
+
+	expectValue := func(name string, labels map[string]string, expected float64) {
+		t.Helper()
+		mf, ok := found[name]
+		if !ok {
+			t.Errorf("%s does not exist", name)
+			return
+		}
+	OUTER:
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue OUTER
+				}
+			}
+			var actual float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				actual = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				actual = m.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				actual = float64(m.GetHistogram().GetSampleCount())
+			}
+			if actual != expected {
+				t.Errorf("%s%v: expected %v, actual %v", name, labels, expected, actual)
+			}
+			return
+		}
+		t.Errorf("%s%v does not exist", name, labels)
+	}
+
+	expectValue("meows_controller_github_request_count", map[string]string{"endpoint": "list_runners", "status": "200"}, 2)
+	expectValue("meows_controller_github_request_count", map[string]string{"endpoint": "remove_runner", "status": "404"}, 1)
+	expectValue("meows_controller_github_request_duration_seconds", map[string]string{"endpoint": "list_runners", "status": "200"}, 2)
+	expectValue("meows_controller_github_ratelimit_remaining", map[string]string{"credential": "pat-test"}, 4999)
+	expectValue("meows_controller_github_ratelimit_reset_timestamp_seconds", map[string]string{"credential": "pat-test"}, float64(reset))
+}
@@ -0,0 +1,64 @@
+package github
+
+import (
+	"fmt"
+	"strconv"
+
+	constants "github.com/cybozu-go/meows"
+)
+
+// NewCredentialFromSecretData reads a ClientCredential from the data of a credential secret.
+// The secret has either a personal access token or the keys of a GitHub App.
+// The network configuration in the secret is also read, but it is not validated.
+func NewCredentialFromSecretData(data map[string][]byte) (*ClientCredential, error) {
+	var cred *ClientCredential
+	if pat, ok := data[constants.CredentialSecretDataPATToken]; ok {
+		cred = &ClientCredential{
+			PersonalAccessToken: string(pat),
+		}
+	} else {
+		var err error
+		cred, err = readAppKeySecretData(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cred.Network = NetworkConfig{
+		ProxyURL: string(data[constants.CredentialSecretDataProxyURL]),
+		NoProxy:  string(data[constants.CredentialSecretDataNoProxy]),
+		CABundle: data[constants.CredentialSecretDataCABundle],
+	}
+	return cred, nil
+}
+
+func readAppKeySecretData(data map[string][]byte) (*ClientCredential, error) {
+	appIDstr, ok := data[constants.CredentialSecretDataAppID]
+	if !ok {
+		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppID)
+	}
+	appID, err := strconv.Atoi(string(appIDstr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value; %w", constants.CredentialSecretDataAppID, err)
+	}
+
+	insIDstr, ok := data[constants.CredentialSecretDataAppInstallationID]
+	if !ok {
+		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppInstallationID)
+	}
+	insID, err := strconv.Atoi(string(insIDstr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value; %w", constants.CredentialSecretDataAppInstallationID, err)
+	}
+
+	key, ok := data[constants.CredentialSecretDataAppPrivateKey]
+	if !ok {
+		return nil, fmt.Errorf("missing %s key", constants.CredentialSecretDataAppPrivateKey)
+	}
+
+	return &ClientCredential{
+		AppID:             int64(appID),
+		AppInstallationID: int64(insID),
+		PrivateKey:        key,
+	}, nil
+}
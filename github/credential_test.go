@@ -0,0 +1,42 @@
+package github
+
+import (
+	"testing"
+)
+
+func TestNewCredentialFromSecretData(t *testing.T) {
+	cred, err := NewCredentialFromSecretData(map[string][]byte{
+		"token":     []byte("pat"),
+		"proxy-url": []byte("http://proxy.example.com:3128"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.PersonalAccessToken != "pat" || cred.Network.ProxyURL != "http://proxy.example.com:3128" {
+		t.Errorf("unexpected credential: %#v", cred)
+	}
+
+	cred, err = NewCredentialFromSecretData(map[string][]byte{
+		"app-id":              []byte("1234"),
+		"app-installation-id": []byte("5678"),
+		"app-private-key":     []byte("key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.AppID != 1234 || cred.AppInstallationID != 5678 || string(cred.PrivateKey) != "key" {
+		t.Errorf("unexpected credential: %#v", cred)
+	}
+
+	invalids := []map[string][]byte{
+		{},
+		{"app-id": []byte("1234"), "app-installation-id": []byte("5678")},
+		{"app-id": []byte("invalid"), "app-installation-id": []byte("5678"), "app-private-key": []byte("key")},
+		{"app-id": []byte("1234"), "app-installation-id": []byte("invalid"), "app-private-key": []byte("key")},
+	}
+	for _, data := range invalids {
+		if _, err := NewCredentialFromSecretData(data); err == nil {
+			t.Errorf("should fail: %v", data)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v41/github"
+)
+
+var (
+	// ErrRateLimited means that the request was rejected by the primary or secondary rate limit of GitHub API.
+	// Use RetryAfter to know when the request can be sent again.
+	ErrRateLimited = errors.New("rate limit of GitHub API is exceeded")
+
+	// ErrUnauthorized means that the credential is invalid or does not have the required permission.
+	ErrUnauthorized = errors.New("unauthorized by GitHub API")
+
+	// ErrNotFound means that the requested organization, repository or runner does not exist.
+	ErrNotFound = errors.New("not found in GitHub API")
+)
+
+// apiError associates an error returned by go-github with one of the errors above.
+type apiError struct {
+	kind       error
+	err        error
+	retryAfter time.Time
+}
+
+func (e *apiError) Error() string {
+	return e.kind.Error() + "; " + e.err.Error()
+}
+
+func (e *apiError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classifyError wraps err so that callers can check it with errors.Is.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return &apiError{kind: ErrRateLimited, err: err, retryAfter: rateLimitErr.Rate.Reset.Time}
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		retryAfter := time.Now().Add(defaultSecondaryRateLimitWait)
+		if abuseErr.RetryAfter != nil {
+			retryAfter = time.Now().Add(*abuseErr.RetryAfter)
+		}
+		return &apiError{kind: ErrRateLimited, err: err, retryAfter: retryAfter}
+	}
+
+	var errRes *github.ErrorResponse
+	if !errors.As(err, &errRes) || errRes.Response == nil {
+		return err
+	}
+	res := errRes.Response
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusForbidden:
+		if d, ok := retryAfter(res.Header); ok {
+			return &apiError{kind: ErrRateLimited, err: err, retryAfter: time.Now().Add(d)}
+		}
+		// go-github does not convert every rate limit response to RateLimitError or AbuseRateLimitError,
+		// so the headers and the message are checked before the response is regarded as a permission error.
+		if res.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, ok := rateLimitReset(res.Header); ok {
+				return &apiError{kind: ErrRateLimited, err: err, retryAfter: reset}
+			}
+			return &apiError{kind: ErrRateLimited, err: err, retryAfter: time.Now().Add(defaultSecondaryRateLimitWait)}
+		}
+		if res.StatusCode == http.StatusTooManyRequests || isRateLimitMessage(errRes.Message) {
+			return &apiError{kind: ErrRateLimited, err: err, retryAfter: time.Now().Add(defaultSecondaryRateLimitWait)}
+		}
+		return &apiError{kind: ErrUnauthorized, err: err}
+	case http.StatusUnauthorized:
+		return &apiError{kind: ErrUnauthorized, err: err}
+	case http.StatusNotFound:
+		return &apiError{kind: ErrNotFound, err: err}
+	}
+	return err
+}
+
+// RetryAfter returns the time until which requests should not be sent when err is ErrRateLimited.
+func RetryAfter(err error) (time.Time, bool) {
+	var e *apiError
+	if errors.As(err, &e) && e.kind == ErrRateLimited {
+		return e.retryAfter, true
+	}
+	return time.Time{}, false
+}
+
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(sec) * time.Second, true
+}
@@ -2,7 +2,7 @@ package github
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -13,6 +13,8 @@ type FakeClientFactory struct {
 	mu                sync.Mutex
 	runners           map[string][]*Runner
 	expiredAtDuration time.Duration
+	rateLimit         RateLimit
+	listRunnersCount  int
 }
 
 func NewFakeClientFactory() *FakeClientFactory {
@@ -33,6 +35,8 @@ func (f *FakeClientFactory) New(_ *ClientCredential) (Client, error) {
 	return &FakeClient{parent: f}, nil
 }
 
+func (f *FakeClientFactory) Release(_ *ClientCredential) {}
+
 func (f *FakeClientFactory) createRegistrationToken(ctx context.Context, owner, repo string) (*github.RegistrationToken, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -56,7 +60,7 @@ func (f *FakeClientFactory) ListRunners(ctx context.Context, owner, repo string,
 	ret := []*Runner{}
 	runners := f.runners[key]
 	for _, r := range runners {
-		if r.hasLabels(labels) {
+		if r.HasLabels(labels) {
 			ret = append(ret, r)
 		}
 	}
@@ -78,7 +82,31 @@ func (f *FakeClientFactory) RemoveRunner(ctx context.Context, owner, repo string
 			return nil
 		}
 	}
-	return errors.New("not exist")
+	return ErrNotFound
+}
+
+// GenerateJITConfig registers an offline runner and returns a dummy configuration.
+func (f *FakeClientFactory) GenerateJITConfig(ctx context.Context, owner, repo, name string, labels []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := genKey(owner, repo)
+
+	var maxID int64
+	for _, r := range f.runners[key] {
+		if r.Name == name {
+			return "", fmt.Errorf("runner %s already exists", name)
+		}
+		if r.ID > maxID {
+			maxID = r.ID
+		}
+	}
+	f.runners[key] = append(f.runners[key], &Runner{
+		ID:     maxID + 1,
+		Name:   name,
+		Labels: labels,
+	})
+	return "fake-jitconfig-" + name, nil
 }
 
 func (f *FakeClientFactory) SetRunners(runners map[string][]*Runner) {
@@ -95,6 +123,35 @@ func (f *FakeClientFactory) SetExpiredAtDuration(d time.Duration) {
 	f.expiredAtDuration = d
 }
 
+func (f *FakeClientFactory) SetRateLimit(rate RateLimit) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rateLimit = rate
+}
+
+// ListRunnersCount returns the number of times the clients called ListRunners.
+func (f *FakeClientFactory) ListRunnersCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.listRunnersCount
+}
+
+func (f *FakeClientFactory) getRateLimit() RateLimit {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rateLimit
+}
+
+func (f *FakeClientFactory) countListRunners() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.listRunnersCount++
+}
+
 // FakeClient is a fake client
 type FakeClient struct {
 	parent *FakeClientFactory
@@ -107,6 +164,7 @@ func (c *FakeClient) CreateRegistrationToken(ctx context.Context, owner, repo st
 
 // ListRunners returns dummy list.
 func (c *FakeClient) ListRunners(ctx context.Context, owner, repo string, labels []string) ([]*Runner, error) {
+	c.parent.countListRunners()
 	return c.parent.ListRunners(ctx, owner, repo, labels)
 }
 
@@ -114,3 +172,13 @@ func (c *FakeClient) ListRunners(ctx context.Context, owner, repo string, labels
 func (c *FakeClient) RemoveRunner(ctx context.Context, owner, repo string, runnerID int64) error {
 	return c.parent.RemoveRunner(ctx, owner, repo, runnerID)
 }
+
+// GenerateJITConfig registers an offline runner and returns a dummy configuration.
+func (c *FakeClient) GenerateJITConfig(ctx context.Context, owner, repo, name string, labels []string) (string, error) {
+	return c.parent.GenerateJITConfig(ctx, owner, repo, name, labels)
+}
+
+// RateLimit returns the rate limit set by SetRateLimit.
+func (c *FakeClient) RateLimit() RateLimit {
+	return c.parent.getRateLimit()
+}
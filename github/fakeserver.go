@@ -0,0 +1,300 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FakeServerError is an error response that FakeServer returns instead of handling a request.
+type FakeServerError struct {
+	StatusCode int
+	Header     http.Header
+	Message    string
+}
+
+// FakeServer is a fake of the GitHub REST API used by Client.
+// Unlike FakeClientFactory, it allows tests to use the real Client with NewFactory(url),
+// so that pagination, status code checks and the organization/repository endpoints are also tested.
+//
+// It implements the following endpoints.
+//   - Creating registration tokens
+//   - Listing runners with pagination
+//   - Removing runners
+//   - Generating just-in-time runner configurations
+//   - Creating installation access tokens for GitHub Apps
+//
+// Run it with httptest.NewServer, or any http.Server for kindtest.
+type FakeServer struct {
+	mux *http.ServeMux
+
+	mu             sync.Mutex
+	runners        map[string][]*Runner // key: "<Owner>" or "<Owner>/<Repository>"
+	nextRunnerID   int64
+	rateLimit      RateLimit
+	injectedErrors []FakeServerError
+	requests       []string
+}
+
+// NewFakeServer creates FakeServer without any runners. The rate limit headers are not returned until SetRateLimit is called.
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{
+		mux:          http.NewServeMux(),
+		runners:      map[string][]*Runner{},
+		nextRunnerID: 1,
+	}
+	for _, prefix := range []string{"/orgs/{owner}", "/repos/{owner}/{repo}"} {
+		s.mux.HandleFunc("POST "+prefix+"/actions/runners/registration-token", s.handleCreateRegistrationToken)
+		s.mux.HandleFunc("GET "+prefix+"/actions/runners", s.handleListRunners)
+		s.mux.HandleFunc("DELETE "+prefix+"/actions/runners/{id}", s.handleRemoveRunner)
+		s.mux.HandleFunc("POST "+prefix+"/actions/runners/generate-jitconfig", s.handleGenerateJITConfig)
+	}
+	s.mux.HandleFunc("POST /app/installations/{id}/access_tokens", s.handleCreateInstallationToken)
+	return s
+}
+
+func (s *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if len(s.injectedErrors) != 0 {
+		e := s.injectedErrors[0]
+		s.injectedErrors = s.injectedErrors[1:]
+		s.mu.Unlock()
+		for k, v := range e.Header {
+			w.Header()[k] = v
+		}
+		writeFakeError(w, e.StatusCode, e.Message)
+		return
+	}
+
+	if s.rateLimit.Limit != 0 {
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit.Limit))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(s.rateLimit.Reset.Unix(), 10))
+		if s.rateLimit.Remaining == 0 {
+			s.mu.Unlock()
+			h.Set("X-RateLimit-Remaining", "0")
+			writeFakeError(w, http.StatusForbidden, "API rate limit exceeded")
+			return
+		}
+		s.rateLimit.Remaining--
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(s.rateLimit.Remaining))
+	}
+	s.mu.Unlock()
+
+	if r.Header.Get("Authorization") == "" {
+		writeFakeError(w, http.StatusUnauthorized, "Requires authentication")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetRunners replaces the registered runners. The key is "<Owner>" or "<Owner>/<Repository>".
+func (s *FakeServer) SetRunners(runners map[string][]*Runner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runners = map[string][]*Runner{}
+	for key, list := range runners {
+		for _, r := range list {
+			copied := *r
+			s.runners[key] = append(s.runners[key], &copied)
+			if r.ID >= s.nextRunnerID {
+				s.nextRunnerID = r.ID + 1
+			}
+		}
+	}
+}
+
+// Runners returns the runners registered for the organization or the repository.
+func (s *FakeServer) Runners(owner, repo string) []*Runner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ret []*Runner
+	for _, r := range s.runners[genKey(owner, repo)] {
+		copied := *r
+		ret = append(ret, &copied)
+	}
+	return ret
+}
+
+// SetRateLimit sets the rate limit. Each request decreases rate.Remaining,
+// and requests are rejected as GitHub does while it is 0.
+func (s *FakeServer) SetRateLimit(rate RateLimit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimit = rate
+}
+
+// InjectErrors makes the server return the errors for the following requests in order.
+func (s *FakeServer) InjectErrors(errs ...FakeServerError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.injectedErrors = append(s.injectedErrors, errs...)
+}
+
+// Requests returns the received requests in the form of "<Method> <Path>".
+func (s *FakeServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+func writeFakeError(w http.ResponseWriter, code int, message string) {
+	writeFakeJSON(w, code, map[string]string{"message": message})
+}
+
+func writeFakeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *FakeServer) handleCreateRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      "faketoken",
+		"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+}
+
+func (s *FakeServer) handleCreateInstallationToken(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      "fake-installation-token",
+		"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+}
+
+type fakeRunnerLabel struct {
+	Name string `json:"name"`
+}
+
+type fakeRunner struct {
+	ID     int64             `json:"id"`
+	Name   string            `json:"name"`
+	OS     string            `json:"os"`
+	Status string            `json:"status"`
+	Busy   bool              `json:"busy"`
+	Labels []fakeRunnerLabel `json:"labels"`
+}
+
+func toFakeRunner(r *Runner) *fakeRunner {
+	status := "offline"
+	if r.Online {
+		status = statusOnline
+	}
+	labels := []fakeRunnerLabel{}
+	for _, l := range r.Labels {
+		labels = append(labels, fakeRunnerLabel{Name: l})
+	}
+	return &fakeRunner{
+		ID:     r.ID,
+		Name:   r.Name,
+		OS:     "linux",
+		Status: status,
+		Busy:   r.Busy,
+		Labels: labels,
+	}
+}
+
+func (s *FakeServer) handleListRunners(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	perPage := 30
+	if v := q.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeFakeError(w, http.StatusUnprocessableEntity, "invalid per_page")
+			return
+		}
+		perPage = n
+	}
+	page := 1
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeFakeError(w, http.StatusUnprocessableEntity, "invalid page")
+			return
+		}
+		page = n
+	}
+
+	s.mu.Lock()
+	all := s.runners[genKey(r.PathValue("owner"), r.PathValue("repo"))]
+	runners := []*fakeRunner{}
+	for i := (page - 1) * perPage; i < len(all) && i < page*perPage; i++ {
+		runners = append(runners, toFakeRunner(all[i]))
+	}
+	total := len(all)
+	s.mu.Unlock()
+
+	if page*perPage < total {
+		next := r.URL.Query()
+		next.Set("page", strconv.Itoa(page+1))
+		next.Set("per_page", strconv.Itoa(perPage))
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?%s>; rel="next"`, r.Host, r.URL.Path, next.Encode()))
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": total,
+		"runners":     runners,
+	})
+}
+
+func (s *FakeServer) handleRemoveRunner(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := genKey(r.PathValue("owner"), r.PathValue("repo"))
+	runners := s.runners[key]
+	for i, runner := range runners {
+		if runner.ID == id {
+			s.runners[key] = append(runners[:i:i], runners[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeFakeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *FakeServer) handleGenerateJITConfig(w http.ResponseWriter, r *http.Request) {
+	var req jitConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeFakeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := genKey(r.PathValue("owner"), r.PathValue("repo"))
+	for _, runner := range s.runners[key] {
+		if runner.Name == req.Name {
+			writeFakeError(w, http.StatusConflict, "Already exists - A runner with the name "+req.Name+" already exists.")
+			return
+		}
+	}
+	runner := &Runner{
+		ID:     s.nextRunnerID,
+		Name:   req.Name,
+		Labels: req.Labels,
+	}
+	s.nextRunnerID++
+	s.runners[key] = append(s.runners[key], runner)
+
+	writeFakeJSON(w, http.StatusCreated, map[string]interface{}{
+		"runner":             toFakeRunner(runner),
+		"encoded_jit_config": "fake-jitconfig-" + req.Name,
+	})
+}
@@ -0,0 +1,190 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakeServerClient(t *testing.T, cred *ClientCredential) (*FakeServer, Client) {
+	t.Helper()
+
+	fake := NewFakeServer()
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+
+	factory, err := NewFactory(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := factory.New(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, c
+}
+
+func TestFakeServerRunners(t *testing.T) {
+	testCases := []struct {
+		title string
+		owner string
+		repo  string
+	}{
+		{title: "organization", owner: "org"},
+		{title: "repository", owner: "owner", repo: "repo"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			fake, c := newFakeServerClient(t, &ClientCredential{PersonalAccessToken: "token"})
+			ctx := context.Background()
+
+			// ListRunners requests 100 runners per page, so 3 pages are required.
+			var runners []*Runner
+			for i := 1; i <= 250; i++ {
+				labels := []string{"self-hosted"}
+				if i%2 == 0 {
+					labels = append(labels, "even")
+				}
+				runners = append(runners, &Runner{ID: int64(i), Name: fmt.Sprintf("runner-%d", i), Online: true, Labels: labels})
+			}
+			fake.SetRunners(map[string][]*Runner{genKey(tt.owner, tt.repo): runners})
+
+			list, err := c.ListRunners(ctx, tt.owner, tt.repo, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(list) != 250 {
+				t.Errorf("expected 250 runners, actual %d", len(list))
+			}
+			list, err = c.ListRunners(ctx, tt.owner, tt.repo, []string{"even"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(list) != 125 {
+				t.Errorf("expected 125 runners, actual %d", len(list))
+			}
+			if !list[0].Online || list[0].Name != "runner-2" {
+				t.Errorf("unexpected runner: %#v", list[0])
+			}
+
+			if _, err := c.CreateRegistrationToken(ctx, tt.owner, tt.repo); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if err := c.RemoveRunner(ctx, tt.owner, tt.repo, 1); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if err := c.RemoveRunner(ctx, tt.owner, tt.repo, 1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("ErrNotFound should be returned: %v", err)
+			}
+			if len(fake.Runners(tt.owner, tt.repo)) != 249 {
+				t.Errorf("the runner is not removed")
+			}
+
+			config, err := c.GenerateJITConfig(ctx, tt.owner, tt.repo, "jit-runner", []string{"self-hosted"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if config != "fake-jitconfig-jit-runner" {
+				t.Errorf("unexpected config: %s", config)
+			}
+			if _, err := c.GenerateJITConfig(ctx, tt.owner, tt.repo, "jit-runner", []string{"self-hosted"}); err == nil {
+				t.Error("error should be returned for the existing name")
+			}
+			list = fake.Runners(tt.owner, tt.repo)
+			if last := list[len(list)-1]; last.ID != 251 || last.Name != "jit-runner" || last.Online {
+				t.Errorf("unexpected runner: %#v", last)
+			}
+		})
+	}
+}
+
+func TestFakeServerErrors(t *testing.T) {
+	fake, c := newFakeServerClient(t, &ClientCredential{PersonalAccessToken: "token"})
+	ctx := context.Background()
+
+	fake.InjectErrors(FakeServerError{StatusCode: http.StatusUnauthorized, Message: "Bad credentials"})
+	if _, err := c.ListRunners(ctx, "org", "", nil); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("ErrUnauthorized should be returned: %v", err)
+	}
+	if _, err := c.ListRunners(ctx, "org", "", nil); err != nil {
+		t.Errorf("injected errors should be returned only once: %v", err)
+	}
+
+	// The request should not wait for the secondary rate limit.
+	fake.InjectErrors(FakeServerError{StatusCode: http.StatusForbidden, Header: http.Header{"Retry-After": []string{"600"}}, Message: "You have exceeded a secondary rate limit."})
+	start := time.Now()
+	_, err := c.ListRunners(ctx, "org", "", nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("ErrRateLimited should be returned: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited for the rate limit: %s", elapsed)
+	}
+	if retryAfter, ok := RetryAfter(err); !ok || retryAfter.Before(start.Add(590*time.Second)) {
+		t.Errorf("RetryAfter should return the time given by Retry-After: %s", retryAfter)
+	}
+
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	fake.SetRateLimit(RateLimit{Limit: 5000, Remaining: 1, Reset: reset})
+	if _, err := c.ListRunners(ctx, "org", "", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate := c.RateLimit(); rate.Limit != 5000 || rate.Remaining != 0 || !rate.Reset.Equal(reset) {
+		t.Errorf("unexpected rate limit: %#v", rate)
+	}
+
+	_, err = c.ListRunners(ctx, "org", "", nil)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("ErrRateLimited should be returned: %v", err)
+	}
+	if retryAfter, ok := RetryAfter(err); !ok || !retryAfter.Equal(reset) {
+		t.Errorf("RetryAfter should return the reset time: %s", retryAfter)
+	}
+}
+
+func TestFakeServerApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	fake, c := newFakeServerClient(t, &ClientCredential{AppID: 1, AppInstallationID: 2, PrivateKey: privateKey})
+	if _, err := c.ListRunners(context.Background(), "org", "", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := fake.Requests()
+	expected := []string{"POST /app/installations/2/access_tokens", "GET /orgs/org/actions/runners"}
+	if len(requests) != len(expected) {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+	for i := range expected {
+		if requests[i] != expected[i] {
+			t.Errorf("unexpected requests: %v", requests)
+		}
+	}
+}
+
+func TestNewFactory(t *testing.T) {
+	for _, u := range []string{"", "https://github.example.com/api/v3", "http://localhost:8080/"} {
+		if _, err := NewFactory(u); err != nil {
+			t.Errorf("unexpected error for %q: %v", u, err)
+		}
+	}
+	for _, u := range []string{"github.example.com", "/api/v3", "://"} {
+		if _, err := NewFactory(u); err == nil {
+			t.Errorf("error should be returned for %q", u)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NetworkConfig is the configuration of the network to access GitHub.
+type NetworkConfig struct {
+	// ProxyURL is the URL of the HTTP proxy.
+	// If this is empty, the proxy is taken from the environment variables (HTTPS_PROXY, HTTP_PROXY and NO_PROXY).
+	ProxyURL string
+
+	// NoProxy is a comma-separated list of hosts that are accessed without the proxy specified by ProxyURL.
+	// The format is the same as the NO_PROXY environment variable.
+	NoProxy string
+
+	// CABundle is PEM-encoded CA certificates trusted in addition to the system roots.
+	CABundle []byte
+}
+
+// Merge returns the NetworkConfig overridden by the non-empty fields of other.
+// The CA bundles are concatenated, so the certificates in both of them are trusted.
+func (n NetworkConfig) Merge(other NetworkConfig) NetworkConfig {
+	ret := n
+	if other.ProxyURL != "" {
+		ret.ProxyURL = other.ProxyURL
+	}
+	if other.NoProxy != "" {
+		ret.NoProxy = other.NoProxy
+	}
+	switch {
+	case len(other.CABundle) == 0:
+	case len(n.CABundle) == 0:
+		ret.CABundle = other.CABundle
+	default:
+		ret.CABundle = append(append(append([]byte{}, n.CABundle...), '\n'), other.CABundle...)
+	}
+	return ret
+}
+
+// Validate checks that the proxy URL and the CA bundle can be used.
+func (n NetworkConfig) Validate() error {
+	_, err := newTransport(n)
+	return err
+}
+
+// newTransport creates a base transport for GitHub API requests according to the NetworkConfig.
+func newTransport(n NetworkConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if n.ProxyURL != "" {
+		u, err := url.Parse(n.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL; %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New("invalid proxy URL; scheme and host are required")
+		}
+		proxy := (&httpproxy.Config{
+			HTTPProxy:  n.ProxyURL,
+			HTTPSProxy: n.ProxyURL,
+			NoProxy:    n.NoProxy,
+		}).ProxyFunc()
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+
+	if len(n.CABundle) != 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(n.CABundle) {
+			return nil, errors.New("invalid CA bundle; no certificates are found")
+		}
+		t.TLSClientConfig = &tls.Config{
+			RootCAs: pool,
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNetworkConfigMerge(t *testing.T) {
+	defaults := NetworkConfig{
+		ProxyURL: "http://proxy.example.com:3128",
+		NoProxy:  "localhost",
+		CABundle: []byte("default"),
+	}
+
+	merged := defaults.Merge(NetworkConfig{})
+	if merged.ProxyURL != defaults.ProxyURL || merged.NoProxy != defaults.NoProxy || string(merged.CABundle) != "default" {
+		t.Errorf("empty fields should not override: %#v", merged)
+	}
+
+	merged = defaults.Merge(NetworkConfig{
+		ProxyURL: "http://proxy2.example.com:3128",
+		NoProxy:  "github.example.com",
+		CABundle: []byte("credential"),
+	})
+	if merged.ProxyURL != "http://proxy2.example.com:3128" || merged.NoProxy != "github.example.com" {
+		t.Errorf("non-empty fields should override: %#v", merged)
+	}
+	if string(merged.CABundle) != "default\ncredential" {
+		t.Errorf("CA bundles should be concatenated: %s", merged.CABundle)
+	}
+	if string(defaults.CABundle) != "default" {
+		t.Errorf("the original CA bundle is modified: %s", defaults.CABundle)
+	}
+}
+
+func TestNetworkConfigValidate(t *testing.T) {
+	testCases := []struct {
+		title   string
+		input   NetworkConfig
+		isError bool
+	}{
+		{title: "empty", input: NetworkConfig{}},
+		{title: "proxy", input: NetworkConfig{ProxyURL: "http://proxy.example.com:3128", NoProxy: "localhost,.svc"}},
+		{title: "proxy without scheme", input: NetworkConfig{ProxyURL: "proxy.example.com"}, isError: true},
+		{title: "invalid CA bundle", input: NetworkConfig{CABundle: []byte("invalid")}, isError: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			err := tt.input.Validate()
+			if tt.isError != (err != nil) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNetworkConfigCABundle(t *testing.T) {
+	fake := NewFakeServer()
+	ts := httptest.NewTLSServer(fake)
+	defer ts.Close()
+
+	factory, err := NewFactory(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	c, err := factory.New(&ClientCredential{PersonalAccessToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListRunners(ctx, "org", "", nil); err == nil {
+		t.Error("the certificate of the server should not be trusted")
+	}
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	c, err = factory.New(&ClientCredential{PersonalAccessToken: "token", Network: NetworkConfig{CABundle: caBundle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListRunners(ctx, "org", "", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNetworkConfigProxy(t *testing.T) {
+	// FakeServer serves the requests for any host, so it can behave as an HTTP proxy.
+	fake := NewFakeServer()
+	proxy := httptest.NewServer(fake)
+	defer proxy.Close()
+
+	factory, err := NewFactory("http://github.invalid/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	c, err := factory.New(&ClientCredential{PersonalAccessToken: "token", Network: NetworkConfig{ProxyURL: proxy.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListRunners(ctx, "org", "", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(fake.Requests()) != 1 {
+		t.Errorf("the request should be sent via the proxy: %v", fake.Requests())
+	}
+
+	c, err = factory.New(&ClientCredential{PersonalAccessToken: "token", Network: NetworkConfig{ProxyURL: proxy.URL, NoProxy: "github.invalid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The host cannot be resolved. Do not wait for the retries.
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListRunners(ctx, "org", "", nil); err == nil {
+		t.Error("the request should not be sent via the proxy")
+	}
+	if len(fake.Requests()) != 1 {
+		t.Errorf("the request should not be sent via the proxy: %v", fake.Requests())
+	}
+}
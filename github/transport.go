@@ -0,0 +1,171 @@
+package github
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 5
+	defaultBaseDelay  = time.Second
+	defaultMaxDelay   = time.Minute
+
+	// Rate limits longer than this are not waited for, so that the callers are not blocked until the reset time.
+	defaultMaxRateLimitWait = time.Minute
+
+	// GitHub asks clients to wait at least one minute when a secondary rate limit is hit without Retry-After.
+	// ref: https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api#exceeding-the-rate-limit
+	defaultSecondaryRateLimitWait = time.Minute
+)
+
+// retryTransport is an http.RoundTripper that retries requests failed by transient errors of GitHub API.
+//   - Server errors (5xx) and network errors are retried with exponential backoff and jitter.
+//   - Secondary rate limits (abuse detection) are retried after Retry-After, or one minute if not specified.
+//   - Primary rate limits are retried after the reset time given by X-RateLimit-Reset.
+//
+// Rate limits longer than maxRateLimitWait are not waited for.
+// The response is returned as is, so that go-github reports the error and the callers can see when to retry by RetryAfter.
+// Waits are aborted when the request context is done, or will be done before the wait ends.
+// When the retries are exhausted, the last response is returned as is, so that go-github can report the error.
+type retryTransport struct {
+	base             http.RoundTripper
+	maxRetries       int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	maxRateLimitWait time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		base:             base,
+		maxRetries:       defaultMaxRetries,
+		baseDelay:        defaultBaseDelay,
+		maxDelay:         defaultMaxDelay,
+		maxRateLimitWait: defaultMaxRateLimitWait,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				// The body cannot be sent twice.
+				return t.base.RoundTrip(req)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		res, err := t.base.RoundTrip(r)
+		if attempt >= t.maxRetries || ctx.Err() != nil {
+			return res, err
+		}
+
+		wait, retry := t.retryWait(res, err, attempt)
+		if !retry {
+			return res, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryWait decides whether the request should be retried and how long to wait before that.
+func (t *retryTransport) retryWait(res *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		// The certificate of the server will not change soon.
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return 0, false
+		}
+		return t.backoff(attempt), true
+	}
+
+	switch {
+	case res.StatusCode >= http.StatusInternalServerError:
+		return t.backoff(attempt), true
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests:
+		if d, ok := retryAfter(res.Header); ok {
+			return d, d <= t.maxRateLimitWait
+		}
+		if res.Header.Get("X-RateLimit-Remaining") == "0" {
+			reset, ok := rateLimitReset(res.Header)
+			if !ok {
+				return 0, false
+			}
+			d := time.Until(reset)
+			if d < 0 {
+				d = 0
+			}
+			return d, d <= t.maxRateLimitWait
+		}
+		if res.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimit(res) {
+			return defaultSecondaryRateLimitWait, defaultSecondaryRateLimitWait <= t.maxRateLimitWait
+		}
+	}
+	return 0, false
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.baseDelay << attempt
+	if d <= 0 || d > t.maxDelay {
+		d = t.maxDelay
+	}
+	// Add jitter to spread the retries of the concurrent requests.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func rateLimitReset(h http.Header) (time.Time, bool) {
+	v := h.Get("X-RateLimit-Reset")
+	if v == "" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// isSecondaryRateLimit checks the error message because GitHub does not always return Retry-After for secondary rate limits.
+// The body is restored so that it can be read again by go-github.
+func isSecondaryRateLimit(res *http.Response) bool {
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return isRateLimitMessage(string(data))
+}
+
+func isRateLimitMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "rate limit") || strings.Contains(msg, "abuse")
+}
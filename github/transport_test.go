@@ -0,0 +1,326 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v41/github"
+)
+
+func newTestRetryTransport() *retryTransport {
+	t := newRetryTransport(http.DefaultTransport)
+	t.maxRetries = 3
+	t.baseDelay = 10 * time.Millisecond
+	t.maxDelay = 100 * time.Millisecond
+	t.maxRateLimitWait = 3 * time.Second
+	return t
+}
+
+func TestRetryTransport(t *testing.T) {
+	testCases := []struct {
+		title string
+
+		// responses are returned in order. The last one is repeated.
+		responses []func(w http.ResponseWriter)
+
+		expectedStatus   int
+		expectedRequests int32
+	}{
+		{
+			title: "success",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 1,
+		},
+		{
+			title: "retry server errors",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 3,
+		},
+		{
+			title: "give up server errors",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) },
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 4,
+		},
+		{
+			title: "retry secondary rate limit after Retry-After",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "1")
+					w.WriteHeader(http.StatusForbidden)
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		{
+			title: "do not wait too long Retry-After",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "3600")
+					w.WriteHeader(http.StatusTooManyRequests)
+				},
+			},
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedRequests: 1,
+		},
+		{
+			title: "retry primary rate limit after X-RateLimit-Reset",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+					w.WriteHeader(http.StatusForbidden)
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			expectedStatus:   http.StatusOK,
+			expectedRequests: 2,
+		},
+		{
+			title: "do not wait too long X-RateLimit-Reset",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+					w.WriteHeader(http.StatusForbidden)
+				},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedRequests: 1,
+		},
+		{
+			title: "do not wait too long secondary rate limit without Retry-After",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message": "You have exceeded a secondary rate limit."}`))
+				},
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedRequests: 1,
+		},
+		{
+			title: "do not retry permission errors",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+				},
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedRequests: 1,
+		},
+		{
+			title: "do not retry not found",
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedRequests: 1,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			var count int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&count, 1)) - 1
+				if i >= len(tt.responses) {
+					i = len(tt.responses) - 1
+				}
+				tt.responses[i](w)
+			}))
+			defer ts.Close()
+
+			client := &http.Client{Transport: newTestRetryTransport()}
+			res, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.expectedStatus {
+				t.Errorf("status: expected %d, actual %d", tt.expectedStatus, res.StatusCode)
+			}
+			if actual := atomic.LoadInt32(&count); actual != tt.expectedRequests {
+				t.Errorf("requests: expected %d, actual %d", tt.expectedRequests, actual)
+			}
+		})
+	}
+}
+
+func TestRetryTransportContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The wait for the backoff exceeds the deadline of the context, so the response should be returned immediately.
+	start := time.Now()
+	transport := newTestRetryTransport()
+	transport.baseDelay = time.Second
+	client := &http.Client{Transport: transport}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status: expected %d, actual %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waited too long: %s", elapsed)
+	}
+}
+
+func TestRetryTransportRateLimitContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The wait for Retry-After should be aborted when the context is canceled.
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	client := &http.Client{Transport: newTestRetryTransport()}
+	_, err = client.Do(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("context.Canceled should be returned: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited too long: %s", elapsed)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	newErrorResponse := func(code int, header http.Header) error {
+		return &github.ErrorResponse{
+			Response: &http.Response{StatusCode: code, Header: header},
+		}
+	}
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		title string
+		input error
+
+		expected       error
+		expectedRetry  bool
+		expectedMinute bool
+	}{
+		{
+			title:         "primary rate limit",
+			input:         &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(time.Hour)}}},
+			expected:      ErrRateLimited,
+			expectedRetry: true,
+		},
+		{
+			title:          "secondary rate limit",
+			input:          &github.AbuseRateLimitError{},
+			expected:       ErrRateLimited,
+			expectedRetry:  true,
+			expectedMinute: true,
+		},
+		{
+			title:          "too many requests",
+			input:          newErrorResponse(http.StatusTooManyRequests, http.Header{}),
+			expected:       ErrRateLimited,
+			expectedRetry:  true,
+			expectedMinute: true,
+		},
+		{
+			title:    "unauthorized",
+			input:    newErrorResponse(http.StatusUnauthorized, http.Header{}),
+			expected: ErrUnauthorized,
+		},
+		{
+			title: "forbidden by primary rate limit",
+			input: newErrorResponse(http.StatusForbidden, http.Header{
+				"X-Ratelimit-Remaining": []string{"0"},
+				"X-Ratelimit-Reset":     []string{strconv.FormatInt(reset.Unix(), 10)},
+			}),
+			expected:      ErrRateLimited,
+			expectedRetry: true,
+		},
+		{
+			title: "forbidden by secondary rate limit",
+			input: &github.ErrorResponse{
+				Response: &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}},
+				Message:  "You have exceeded a secondary rate limit.",
+			},
+			expected:       ErrRateLimited,
+			expectedRetry:  true,
+			expectedMinute: true,
+		},
+		{
+			title:    "forbidden",
+			input:    newErrorResponse(http.StatusForbidden, http.Header{}),
+			expected: ErrUnauthorized,
+		},
+		{
+			title:    "not found",
+			input:    newErrorResponse(http.StatusNotFound, http.Header{}),
+			expected: ErrNotFound,
+		},
+		{
+			title:    "other errors",
+			input:    newErrorResponse(http.StatusUnprocessableEntity, http.Header{}),
+			expected: nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			err := classifyError(tt.input)
+			if !errors.Is(err, tt.input) {
+				t.Errorf("the original error is not wrapped: %v", err)
+			}
+			for _, kind := range []error{ErrRateLimited, ErrUnauthorized, ErrNotFound} {
+				if errors.Is(err, kind) != (kind == tt.expected) {
+					t.Errorf("errors.Is(%v, %v) should be %v", err, kind, kind == tt.expected)
+				}
+			}
+
+			retryAfter, ok := RetryAfter(err)
+			if ok != tt.expectedRetry {
+				t.Fatalf("RetryAfter should return %v", tt.expectedRetry)
+			}
+			if tt.expectedMinute && time.Until(retryAfter).Round(time.Second) != time.Minute {
+				t.Errorf("RetryAfter should return one minute later: %s", retryAfter)
+			}
+		})
+	}
+}
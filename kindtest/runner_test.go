@@ -52,13 +52,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": Equal("#test2"),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     Equal("#test2"),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -83,13 +86,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("cancelled"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("cancelled"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -116,13 +122,16 @@ func testRunner() {
 		Eventually(func(g Gomega) {
 			_, status = waitJobCompletion(repoRunner1NS, repoRunnerPool1Name)
 			g.Expect(status).To(PointTo(MatchAllFields(Fields{
-				"State":        Equal("debugging"),
-				"Result":       Equal("failure"),
-				"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-				"DeletionTime": Not(BeNil()),
-				"Extend":       PointTo(BeTrue()),
-				"JobInfo":      Not(BeNil()),
-				"SlackChannel": BeEmpty(),
+				"State":            Equal("debugging"),
+				"Result":           Equal("failure"),
+				"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+				"DeletionTime":     Not(BeNil()),
+				"Extend":           PointTo(BeTrue()),
+				"JobInfo":          Not(BeNil()),
+				"SlackChannel":     BeEmpty(),
+				"JobStartedAt":     Not(BeNil()),
+				"WaitingJITConfig": BeFalse(),
+				"SetupFailure":     BeNil(),
 			})))
 		}).Should(Succeed())
 
@@ -151,6 +160,38 @@ func testRunner() {
 		waitDeletion("pod", assignedPod.Namespace, assignedPod.Name)
 	})
 
+	It("should record the job result by the job hooks and delete the pod immediately", func() {
+		By("running 'job-hooks' workflow")
+		waitRepositoryRunnerPods(repoRunner2NS, repoRunnerPool2Name, repoRunnerPool2Replicas)
+		pushWorkflowFile("job-hooks.tmpl.yaml", repoRunner2NS, repoRunnerPool2Name)
+		assignedPod, status := waitJobCompletion(repoRunner2NS, repoRunnerPool2Name)
+		finishedAt := time.Now()
+
+		By("checking status")
+		Expect(status).To(PointTo(MatchAllFields(Fields{
+			"State":            Equal("debugging"),
+			"Result":           Equal("failure"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
+		})))
+
+		By("confirming the pod terminating")
+		deletedAt := waitRunnerPodTerminating(assignedPod.Namespace, assignedPod.Name)
+		Expect(deletedAt).To(BeTemporally(">", *status.FinishedAt))
+
+		By("confirming a slack message is successfully sent")
+		slackMessageShouldBeSent(assignedPod, "#test2")
+
+		By("waiting for the pod deleted")
+		waitDeletion("pod", assignedPod.Namespace, assignedPod.Name)
+	})
+
 	It("should extend pod with the deletion time API", func() {
 		By("running 'job-failure' workflow")
 		waitRepositoryRunnerPods(repoRunner2NS, repoRunnerPool2Name, repoRunnerPool2Replicas)
@@ -162,13 +203,16 @@ func testRunner() {
 		Eventually(func(g Gomega) {
 			_, status = waitJobCompletion(repoRunner2NS, repoRunnerPool2Name)
 			g.Expect(status).To(PointTo(MatchAllFields(Fields{
-				"State":        Equal("debugging"),
-				"Result":       Equal("failure"),
-				"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-				"DeletionTime": Not(BeNil()),
-				"Extend":       PointTo(BeTrue()),
-				"JobInfo":      Not(BeNil()),
-				"SlackChannel": BeEmpty(),
+				"State":            Equal("debugging"),
+				"Result":           Equal("failure"),
+				"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+				"DeletionTime":     Not(BeNil()),
+				"Extend":           PointTo(BeTrue()),
+				"JobInfo":          Not(BeNil()),
+				"SlackChannel":     BeEmpty(),
+				"JobStartedAt":     Not(BeNil()),
+				"WaitingJITConfig": BeFalse(),
+				"SetupFailure":     BeNil(),
 			})))
 		}).Should(Succeed())
 
@@ -181,13 +225,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("failure"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": PointTo(BeTemporally("==", extendTo)),
-			"Extend":       PointTo(BeTrue()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("failure"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     PointTo(BeTemporally("==", extendTo)),
+			"Extend":           PointTo(BeTrue()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -213,13 +260,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -244,13 +294,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -275,13 +328,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": Equal("#test2"),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     Equal("#test2"),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -306,13 +362,16 @@ func testRunner() {
 
 		By("checking status")
 		Expect(status).To(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      Not(BeNil()),
-			"SlackChannel": Equal("#test1"),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 3*time.Second)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          Not(BeNil()),
+			"SlackChannel":     Equal("#test1"),
+			"JobStartedAt":     Not(BeNil()),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("confirming the pod terminating")
@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -10,8 +12,18 @@ import (
 var (
 	RunnerPoolSecretRetryCount *prometheus.CounterVec
 	runnerPoolReplicas         *prometheus.GaugeVec
+	registrationTimeoutCount   *prometheus.CounterVec
+	nodeDrainBlockingPods      *prometheus.GaugeVec
+	offlineRunnersPending      *prometheus.GaugeVec
 	runnerOnlineVec            *prometheus.GaugeVec
 	runnerBusyVec              *prometheus.GaugeVec
+	runnerCacheHitCount        prometheus.Counter
+	runnerCacheMissCount       prometheus.Counter
+	orphanedRunnerRemovedCount prometheus.Counter
+	githubRequestCount         *prometheus.CounterVec
+	githubRequestDuration      *prometheus.HistogramVec
+	githubRateLimitRemaining   *prometheus.GaugeVec
+	githubRateLimitReset       *prometheus.GaugeVec
 	runnerLabelSet             map[string]map[string]struct{} // runnerpool -> runner -> struct{}
 	runnerLabelSetMutex        sync.Mutex
 )
@@ -37,6 +49,36 @@ func InitControllerMetrics(registry prometheus.Registerer) {
 		[]string{"runnerpool"},
 	)
 
+	registrationTimeoutCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: runnerPoolSubsystem,
+			Name:      "registration_timeout_count",
+			Help:      "The number of runner pods deleted because the runners did not start within the registration timeout",
+		},
+		[]string{"runnerpool", "reason"},
+	)
+
+	nodeDrainBlockingPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: runnerPoolSubsystem,
+			Name:      "node_drain_blocking_pods",
+			Help:      "The number of busy or debugging runner pods on cordoned or draining nodes",
+		},
+		[]string{"runnerpool"},
+	)
+
+	offlineRunnersPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: runnerPoolSubsystem,
+			Name:      "offline_runners_pending_removal",
+			Help:      "The number of offline runners without runner pods waiting for the grace period before they are removed",
+		},
+		[]string{"runnerpool"},
+	)
+
 	runnerOnlineVec = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricsNamespace,
@@ -57,22 +99,152 @@ func InitControllerMetrics(registry prometheus.Registerer) {
 		[]string{"runnerpool", "runner"},
 	)
 
+	runnerCacheHitCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "runner_cache_hit_count",
+			Help:      "The number of times the runner list was served from the cache",
+		},
+	)
+
+	runnerCacheMissCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "runner_cache_miss_count",
+			Help:      "The number of times the runner list was fetched from GitHub",
+		},
+	)
+
+	orphanedRunnerRemovedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "orphaned_runner_removed_count",
+			Help:      "The number of offline runners removed because their RunnerPools do not exist",
+		},
+	)
+
+	githubRequestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "github_request_count",
+			Help:      "The number of requests to GitHub API",
+		},
+		[]string{"endpoint", "status"},
+	)
+
+	githubRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "github_request_duration_seconds",
+			Help:      "The latency of requests to GitHub API including retries",
+			Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+		},
+		[]string{"endpoint", "status"},
+	)
+
+	githubRateLimitRemaining = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "github_ratelimit_remaining",
+			Help:      "The number of requests remaining in the current rate limit window of GitHub API",
+		},
+		[]string{"credential"},
+	)
+
+	githubRateLimitReset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: controllerSubsystem,
+			Name:      "github_ratelimit_reset_timestamp_seconds",
+			Help:      "The time when the current rate limit window of GitHub API resets, in seconds since the epoch",
+		},
+		[]string{"credential"},
+	)
+
 	runnerLabelSet = map[string]map[string]struct{}{}
 
 	registry.MustRegister(
 		RunnerPoolSecretRetryCount,
 		runnerPoolReplicas,
+		registrationTimeoutCount,
+		nodeDrainBlockingPods,
+		offlineRunnersPending,
 		runnerOnlineVec,
 		runnerBusyVec,
+		runnerCacheHitCount,
+		runnerCacheMissCount,
+		orphanedRunnerRemovedCount,
+		githubRequestCount,
+		githubRequestDuration,
+		githubRateLimitRemaining,
+		githubRateLimitReset,
 	)
 }
 
+func IncrementRunnerCache(hit bool) {
+	if hit {
+		runnerCacheHitCount.Inc()
+	} else {
+		runnerCacheMissCount.Inc()
+	}
+}
+
+func IncrementOrphanedRunnerRemoved() {
+	orphanedRunnerRemovedCount.Inc()
+}
+
+// ObserveGitHubRequest records a request to GitHub API.
+// The status is "error" if no response was received.
+// It does nothing unless InitControllerMetrics is called, because the GitHub client is also used by the CLI.
+func ObserveGitHubRequest(endpoint string, status int, duration time.Duration) {
+	if githubRequestCount == nil {
+		return
+	}
+	statusLabel := "error"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	githubRequestCount.WithLabelValues(endpoint, statusLabel).Inc()
+	githubRequestDuration.WithLabelValues(endpoint, statusLabel).Observe(duration.Seconds())
+}
+
+// UpdateGitHubRateLimitMetrics records the rate limit status of GitHub API for the credential.
+// It does nothing unless InitControllerMetrics is called.
+func UpdateGitHubRateLimitMetrics(credential string, remaining int, reset time.Time) {
+	if githubRateLimitRemaining == nil {
+		return
+	}
+	githubRateLimitRemaining.WithLabelValues(credential).Set(float64(remaining))
+	githubRateLimitReset.WithLabelValues(credential).Set(float64(reset.Unix()))
+}
+
 func UpdateRunnerPoolMetrics(runnerpool string, replicas int) {
 	runnerPoolReplicas.WithLabelValues(runnerpool).Set(float64(replicas))
 }
 
+func IncrementRegistrationTimeout(runnerpool, reason string) {
+	registrationTimeoutCount.WithLabelValues(runnerpool, reason).Inc()
+}
+
+func UpdateNodeDrainBlockingPods(runnerpool string, pods int) {
+	nodeDrainBlockingPods.WithLabelValues(runnerpool).Set(float64(pods))
+}
+
+func UpdateOfflineRunnersPendingRemoval(runnerpool string, runners int) {
+	offlineRunnersPending.WithLabelValues(runnerpool).Set(float64(runners))
+}
+
 func DeleteRunnerPoolMetrics(runnerpool string) {
 	runnerPoolReplicas.DeleteLabelValues(runnerpool)
+	nodeDrainBlockingPods.DeleteLabelValues(runnerpool)
+	offlineRunnersPending.DeleteLabelValues(runnerpool)
+	registrationTimeoutCount.DeletePartialMatch(prometheus.Labels{"runnerpool": runnerpool})
 }
 
 func UpdateRunnerMetrics(runnerpool, runner string, online, busy bool) {
@@ -17,6 +17,7 @@ var allRunnerPodState = []string{
 	constants.RunnerPodStateRunning,
 	constants.RunnerPodStateDebugging,
 	constants.RunnerPodStateStale,
+	constants.RunnerPodStateSetupFailed,
 }
 
 // Runner pod related metrics
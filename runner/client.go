@@ -15,6 +15,7 @@ import (
 type Client interface {
 	PutDeletionTime(ctx context.Context, ip string, tm time.Time) error
 	GetStatus(ctx context.Context, ip string) (*Status, error)
+	PutJITConfig(ctx context.Context, ip string, encodedJITConfig string) error
 }
 
 type clientImpl struct {
@@ -78,6 +79,33 @@ func (c *clientImpl) GetStatus(ctx context.Context, ip string) (*Status, error)
 	return &s, nil
 }
 
+func (c *clientImpl) PutJITConfig(ctx context.Context, ip string, encodedJITConfig string) error {
+	b, err := json.Marshal(JITConfigPayload{
+		EncodedJITConfig: encodedJITConfig,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, getJITConfigURL(ip), bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Do not include the request body in the error because it contains the credential of the runner.
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("runner pod (%s) return %d", ip, res.StatusCode)
+	}
+	return nil
+}
+
 func getStatusURL(ip string) string {
 	return fmt.Sprintf("http://%s:%d/%s", ip, constants.RunnerListenPort, constants.StatusEndPoint)
 }
@@ -86,14 +114,20 @@ func getDeletionTimeURL(ip string) string {
 	return fmt.Sprintf("http://%s:%d/%s", ip, constants.RunnerListenPort, constants.DeletionTimeEndpoint)
 }
 
+func getJITConfigURL(ip string) string {
+	return fmt.Sprintf("http://%s:%d/%s", ip, constants.RunnerListenPort, constants.JITConfigEndpoint)
+}
+
 // FakeClient is a fake client
 type FakeClient struct {
-	statuses map[string]*Status
+	statuses   map[string]*Status
+	jitConfigs map[string]string
 }
 
 func NewFakeClient() *FakeClient {
 	return &FakeClient{
-		statuses: map[string]*Status{},
+		statuses:   map[string]*Status{},
+		jitConfigs: map[string]string{},
 	}
 }
 
@@ -115,3 +149,21 @@ func (c *FakeClient) GetStatus(ctx context.Context, ip string) (*Status, error)
 func (c *FakeClient) SetStatus(ip string, st *Status) {
 	c.statuses[ip] = st
 }
+
+func (c *FakeClient) PutJITConfig(ctx context.Context, ip string, encodedJITConfig string) error {
+	st, ok := c.statuses[ip]
+	if !ok {
+		return fmt.Errorf("[FakeClient.PutJITConfig] runner pod (%s) status is not defined", ip)
+	}
+	if _, ok := c.jitConfigs[ip]; ok {
+		return fmt.Errorf("[FakeClient.PutJITConfig] runner pod (%s) already has a jit config", ip)
+	}
+	c.jitConfigs[ip] = encodedJITConfig
+	st.WaitingJITConfig = false
+	return nil
+}
+
+// GetJITConfig returns the jit config given by PutJITConfig.
+func (c *FakeClient) GetJITConfig(ip string) string {
+	return c.jitConfigs[ip]
+}
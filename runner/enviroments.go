@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	constants "github.com/cybozu-go/meows"
 )
@@ -11,6 +12,12 @@ import (
 // Omittable options
 type Option struct {
 	SetupCommand []string `json:"setup_command,omitempty"`
+	// JITConfig makes the runner wait for a just-in-time configuration given by the controller instead of reading the registration token.
+	JITConfig bool `json:"jit_config,omitempty"`
+	// SetupTimeout is the time limit for each attempt of the setup command. It is parsed by time.ParseDuration.
+	SetupTimeout string `json:"setup_timeout,omitempty"`
+	// SetupRetries is the number of times the setup command is retried after it fails.
+	SetupRetries int `json:"setup_retries,omitempty"`
 }
 
 type environments struct {
@@ -20,6 +27,10 @@ type environments struct {
 	runnerRepo     string
 	runnerPoolName string
 	setupCommand   []string
+	setupTimeout   time.Duration
+	setupRetries   int
+	jitConfig      bool
+	caBundle       string
 }
 
 func newRunnerEnvs() (*environments, error) {
@@ -29,6 +40,7 @@ func newRunnerEnvs() (*environments, error) {
 		runnerOrg:      os.Getenv(constants.RunnerOrgEnvName),
 		runnerRepo:     os.Getenv(constants.RunnerRepoEnvName),
 		runnerPoolName: os.Getenv(constants.RunnerPoolNameEnvName),
+		caBundle:       os.Getenv(constants.CABundleEnvName),
 	}
 	if err := envs.validateRequiredEnvs(); err != nil {
 		return nil, err
@@ -40,6 +52,15 @@ func newRunnerEnvs() (*environments, error) {
 		return nil, fmt.Errorf("failed to unmarshal %s; %w", constants.RunnerOptionEnvName, err)
 	}
 	envs.setupCommand = opt.SetupCommand
+	if opt.SetupTimeout != "" {
+		d, err := time.ParseDuration(opt.SetupTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse setup timeout; %w", err)
+		}
+		envs.setupTimeout = d
+	}
+	envs.setupRetries = opt.SetupRetries
+	envs.jitConfig = opt.JITConfig
 
 	return envs, nil
 }
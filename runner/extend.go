@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrExtendNotAllowed is returned when the RunnerPool does not allow extending the runner pods.
+var ErrExtendNotAllowed = errors.New("the RunnerPool does not allow extending runner pods")
+
+// ParseExtendUntil parses the time until which a debugging runner pod is extended.
+// The value is a time in RFC 3339 format or a positive duration from now.
+// The time is limited to extendDuration from now, which is given by the RunnerPool.
+// If extendDuration is 0, it returns ErrExtendNotAllowed.
+func ParseExtendUntil(value string, extendDuration time.Duration, now time.Time) (time.Time, error) {
+	if extendDuration == 0 {
+		return time.Time{}, ErrExtendNotAllowed
+	}
+
+	tm, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is neither a time in RFC 3339 format nor a duration", value)
+		}
+		if d <= 0 {
+			return time.Time{}, errors.New("the duration should be positive")
+		}
+		tm = now.Add(d)
+	}
+	tm = tm.UTC()
+	if limit := now.Add(extendDuration); tm.After(limit) {
+		tm = limit
+	}
+	return tm, nil
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseExtendUntil(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		title          string
+		value          string
+		extendDuration time.Duration
+		expected       time.Time
+		expectedErr    error // nil if any error is expected.
+		errorCase      bool
+	}{
+		{
+			title:          "duration",
+			value:          "2h",
+			extendDuration: 3 * time.Hour,
+			expected:       now.Add(2 * time.Hour),
+		},
+		{
+			title:          "time",
+			value:          "2024-01-01T10:00:00+09:00",
+			extendDuration: 3 * time.Hour,
+			expected:       now.Add(time.Hour),
+		},
+		{
+			title:          "limited-duration",
+			value:          "5h",
+			extendDuration: 3 * time.Hour,
+			expected:       now.Add(3 * time.Hour),
+		},
+		{
+			title:          "limited-time",
+			value:          "2024-01-02T00:00:00Z",
+			extendDuration: 3 * time.Hour,
+			expected:       now.Add(3 * time.Hour),
+		},
+		{
+			title:          "not-allowed",
+			value:          "2h",
+			extendDuration: 0,
+			expectedErr:    ErrExtendNotAllowed,
+			errorCase:      true,
+		},
+		{
+			title:          "zero-duration",
+			value:          "0s",
+			extendDuration: 3 * time.Hour,
+			errorCase:      true,
+		},
+		{
+			title:          "negative-duration",
+			value:          "-1h",
+			extendDuration: 3 * time.Hour,
+			errorCase:      true,
+		},
+		{
+			title:          "invalid",
+			value:          "tomorrow",
+			extendDuration: 3 * time.Hour,
+			errorCase:      true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			tm, err := ParseExtendUntil(tt.value, tt.extendDuration, now)
+			if tt.errorCase {
+				if err == nil {
+					t.Fatalf("error should occur: %v", tm)
+				}
+				if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+					t.Errorf("expected %v, but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tm.Equal(tt.expected) {
+				t.Errorf("expected %v, but got %v", tt.expected, tm)
+			}
+		})
+	}
+}
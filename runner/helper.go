@@ -2,23 +2,81 @@ package runner
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	constants "github.com/cybozu-go/meows"
 )
 
+// followInterval is the interval to check the output of the commands.
+const followInterval = 100 * time.Millisecond
+
 func runCommand(ctx context.Context, workDir, commandStr string, args ...string) (int, error) {
+	return runCommandWithOutput(ctx, nil, workDir, commandStr, args...)
+}
+
+// runCommandWithOutput runs the command like runCommand, and also writes the stdout and stderr to output if it is not nil.
+//
+// The output is written to a temporary file instead of a pipe and followed while the command runs.
+// Background processes started by the command inherit the file, so they never keep a pipe open,
+// which would make the command look failed even if it exits with 0.
+// The file is removed when the command exits, and the later output of the background processes is discarded.
+func runCommandWithOutput(ctx context.Context, output io.Writer, workDir, commandStr string, args ...string) (int, error) {
 	command := exec.CommandContext(ctx, commandStr, args...)
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
 	command.Dir = workDir
 	command.Env = removedEnv()
-	err := command.Run()
+	if output == nil {
+		err := command.Run()
+		return command.ProcessState.ExitCode(), err
+	}
+
+	f, err := os.CreateTemp("", "meows-output-")
+	if err != nil {
+		return -1, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	r, err := os.Open(f.Name())
+	if err != nil {
+		return -1, err
+	}
+	defer r.Close()
+	command.Stdout = f
+	command.Stderr = f
+	if err := command.Start(); err != nil {
+		return -1, err
+	}
+
+	done := make(chan struct{})
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		followFile(r, io.MultiWriter(os.Stdout, output), done)
+	}()
+	err = command.Wait()
+	close(done)
+	<-followed
 	return command.ProcessState.ExitCode(), err
 }
 
+// followFile copies the data appended to the file to w until done is closed.
+func followFile(f *os.File, w io.Writer, done <-chan struct{}) {
+	for {
+		io.Copy(w, f)
+		select {
+		case <-done:
+			io.Copy(w, f)
+			return
+		case <-time.After(followInterval):
+		}
+	}
+}
+
 func removedEnv() []string {
 	rmList := []string{
 		constants.PodNameEnvName,
@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+)
+
+func TestRunCommandWithOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	output := &bytes.Buffer{}
+	code, err := runCommandWithOutput(context.Background(), output, t.TempDir(), "sh", "-c", "echo hello; sleep 5 &")
+	if err != nil || code != 0 {
+		t.Fatalf("the command should succeed: code=%d, err=%v", code, err)
+	}
+	if output.String() != "hello\n" {
+		t.Errorf("unexpected output: %q", output.String())
+	}
+
+	// The temporary file for the output should be removed even though the background process is running.
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("the temporary file should be removed: %v", entries)
+	}
+}
@@ -1,10 +1,14 @@
 package runner
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sync"
 	"time"
 
 	constants "github.com/cybozu-go/meows"
@@ -14,20 +18,23 @@ import (
 
 type Listener interface {
 	configure(ctx context.Context, configArgs []string) error
-	listen(ctx context.Context) error
+	// listen runs the listener. If jitConfig is not empty, the listener runs with the just-in-time configuration without configure.
+	listen(ctx context.Context, jitConfig string) error
 }
 
 type listenerImpl struct {
 	runnerDir       string
 	configCommand   string
 	listenerCommand string
+	conclusionFile  string
 }
 
-func NewListener(runnerDir string) Listener {
+func NewListener(runnerDir, varDir string) Listener {
 	return &listenerImpl{
 		runnerDir:       runnerDir,
 		configCommand:   filepath.Join(runnerDir, "config.sh"),
 		listenerCommand: filepath.Join(runnerDir, "bin", "Runner.Listener"),
+		conclusionFile:  filepath.Join(varDir, "job_conclusion"),
 	}
 }
 
@@ -36,10 +43,15 @@ func (l *listenerImpl) configure(ctx context.Context, configArgs []string) error
 	return err
 }
 
-func (l *listenerImpl) listen(ctx context.Context) error {
+func (l *listenerImpl) listen(ctx context.Context, jitConfig string) error {
 	logger := log.FromContext(ctx)
+	args := []string{"run", "--startuptype", "service"}
+	if jitConfig != "" {
+		args = append(args, "--jitconfig", jitConfig)
+	}
+	output := newJobConclusionWriter(l.conclusionFile)
 	for {
-		code, err := runCommand(ctx, l.runnerDir, l.listenerCommand, "run", "--startuptype", "service")
+		code, err := runCommandWithOutput(ctx, output, l.runnerDir, l.listenerCommand, args...)
 		if _, ok := err.(*exec.ExitError); !ok {
 			return err
 		}
@@ -64,8 +76,68 @@ func (l *listenerImpl) listen(ctx context.Context) error {
 			logger.Info("Runner listener exit with undefined return code, re-launch runner in 10 seconds.")
 			metrics.IncrementListenerExitState(constants.ListenerExitStateUndefined)
 		}
+		if jitConfig != "" {
+			// A just-in-time configuration can be used only once, so re-launching the listener always fails.
+			// Exit to let the pod become stale and be recreated.
+			return fmt.Errorf("runner listener exited with code %d and cannot be re-launched with the just-in-time configuration", code)
+		}
 
 		// Sleep 10 seconds to wait for the update process finish.
 		time.Sleep(10 * time.Second)
 	}
 }
+
+// jobConclusionPattern matches the line that the listener prints when a job finished.
+// ref: https://github.com/actions/runner/blob/v2.309.0/src/Misc/layoutbin/en-US/strings.json ("JobCompleted")
+var jobConclusionPattern = regexp.MustCompile(`Job .* completed with result: (\w+)`)
+
+// jobConclusions maps the results of the listener to the job results.
+// The other results such as Skipped and Abandoned are not recorded.
+var jobConclusions = map[string]string{
+	"Succeeded": JobResultSuccess,
+	"Failed":    JobResultFailure,
+	"Canceled":  JobResultCancelled,
+}
+
+// jobConclusionWriter is an io.Writer that records the conclusion of the job printed by the listener into a file.
+// The runner does not give the conclusion to the job hooks, so it is taken from the output.
+// It is safe to write stdout and stderr to it concurrently.
+type jobConclusionWriter struct {
+	file string
+	mu   sync.Mutex
+	buf  []byte
+}
+
+func newJobConclusionWriter(file string) *jobConclusionWriter {
+	return &jobConclusionWriter{file: file}
+}
+
+func (w *jobConclusionWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.parseLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	// Do not return errors. They stop the output of the listener.
+	return len(p), nil
+}
+
+func (w *jobConclusionWriter) parseLine(line []byte) {
+	m := jobConclusionPattern.FindSubmatch(line)
+	if m == nil {
+		return
+	}
+	result, ok := jobConclusions[string(m[1])]
+	if !ok {
+		return
+	}
+	if err := os.WriteFile(w.file, []byte(result), 0664); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the job conclusion: %v\n", err)
+	}
+}
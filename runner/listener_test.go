@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/meows/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestJobConclusionWriter(t *testing.T) {
+	testCases := []struct {
+		title    string
+		writes   []string
+		expected string // empty if the conclusion is not recorded.
+	}{
+		{
+			title:    "succeeded",
+			writes:   []string{"2024-01-01 00:00:00Z: Running job: build\n", "2024-01-01 00:01:00Z: Job build completed with result: Succeeded\n"},
+			expected: JobResultSuccess,
+		},
+		{
+			title:    "failed",
+			writes:   []string{"2024-01-01 00:01:00Z: Job build completed with result: Failed\n"},
+			expected: JobResultFailure,
+		},
+		{
+			title:    "canceled",
+			writes:   []string{"2024-01-01 00:01:00Z: Job build completed with result: Canceled\n"},
+			expected: JobResultCancelled,
+		},
+		{
+			title:    "split-line",
+			writes:   []string{"2024-01-01 00:01:00Z: Job build comp", "leted with result: Fai", "led\n"},
+			expected: JobResultFailure,
+		},
+		{
+			title:  "incomplete-line",
+			writes: []string{"2024-01-01 00:01:00Z: Job build completed with result: Succeeded"},
+		},
+		{
+			title:  "unknown-result",
+			writes: []string{"2024-01-01 00:01:00Z: Job build completed with result: Abandoned\n"},
+		},
+		{
+			title:  "no-job",
+			writes: []string{"2024-01-01 00:00:00Z: Listening for Jobs\n"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.title, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "job_conclusion")
+			w := newJobConclusionWriter(file)
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil || n != len(s) {
+					t.Fatalf("unexpected write result: n = %d, err = %v", n, err)
+				}
+			}
+
+			data, err := os.ReadFile(file)
+			if tt.expected == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("the conclusion should not be recorded: data = %q, err = %v", data, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestListenerJITConfig(t *testing.T) {
+	runnerDir := t.TempDir()
+	countFile := filepath.Join(runnerDir, "count")
+	script := "#!/bin/sh\necho run >> " + countFile + "\nexit 2\n"
+	if err := os.MkdirAll(filepath.Join(runnerDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runnerDir, "bin", "Runner.Listener"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.InitRunnerPodMetrics(prometheus.NewRegistry(), "ns/rp")
+
+	// The listener exits with a retryable error, but it should not be re-launched with the same just-in-time configuration.
+	l := NewListener(runnerDir, t.TempDir())
+	if err := l.listen(context.Background(), "jitconfig"); err == nil {
+		t.Fatal("listen should return an error")
+	}
+	data, err := os.ReadFile(countFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "run\n" {
+		t.Errorf("the listener should run only once: %q", data)
+	}
+}
+
+func TestListenerBackgroundProcess(t *testing.T) {
+	runnerDir := t.TempDir()
+	varDir := t.TempDir()
+	// The listener leaves a process started by the job, which inherits the output.
+	script := "#!/bin/sh\nsleep 10 &\necho 'Job build completed with result: Failed'\nexit 0\n"
+	if err := os.MkdirAll(filepath.Join(runnerDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runnerDir, "bin", "Runner.Listener"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.InitRunnerPodMetrics(prometheus.NewRegistry(), "ns/rp")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	l := NewListener(runnerDir, varDir)
+	if err := l.listen(ctx, ""); err != nil {
+		t.Fatalf("listen should succeed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("listen should not wait for the background process: %v", elapsed)
+	}
+	data, err := os.ReadFile(filepath.Join(varDir, "job_conclusion"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != JobResultFailure {
+		t.Errorf("expected %q, but got %q", JobResultFailure, data)
+	}
+}
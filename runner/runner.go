@@ -3,8 +3,10 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -26,6 +28,8 @@ const (
 	JobResultFailure   = "failure"
 	JobResultCancelled = "cancelled"
 	JobResultUnknown   = "unknown"
+	// JobResultTimeout is given by the runner manager when the job exceeds the maximum duration.
+	JobResultTimeout = "timeout"
 )
 
 type Runner struct {
@@ -38,10 +42,17 @@ type Runner struct {
 	state        string
 	result       string
 	finishedAt   *time.Time
+	jobStartedAt *time.Time
 	deletionTime *time.Time
 	extend       *bool
 	jobInfo      *JobInfo
 	slackChannel string
+	setupFailure *SetupFailure
+
+	// Just-in-time configuration
+	waitingJITConfig bool
+	jitConfig        string
+	jitConfigCh      chan struct{}
 
 	// Directory/File Paths
 	runnerDir         string
@@ -49,11 +60,15 @@ type Runner struct {
 	tokenPath         string
 	jobInfoFile       string
 	slackChannelFile  string
+	jobStartedFile    string
+	conclusionFile    string
 	startedFlagFile   string
 	extendFlagFile    string
 	failureFlagFile   string
 	cancelledFlagFile string
 	successFlagFile   string
+	caBundleFile      string
+	extraCAFile       string
 }
 
 type Status struct {
@@ -64,12 +79,22 @@ type Status struct {
 	Extend       *bool      `json:"extend,omitempty"`
 	JobInfo      *JobInfo   `json:"job_info,omitempty"`
 	SlackChannel string     `json:"slack_channel,omitempty"`
+	// JobStartedAt is the time when the listener picked up a job. It is set after the job-started command runs.
+	JobStartedAt *time.Time `json:"job_started_at,omitempty"`
+	// WaitingJITConfig is true while the runner waits for a just-in-time configuration.
+	WaitingJITConfig bool `json:"waiting_jitconfig,omitempty"`
+	// SetupFailure is set when the state is setup_failed.
+	SetupFailure *SetupFailure `json:"setup_failure,omitempty"`
 }
 
 type DeletionTimePayload struct {
 	DeletionTime time.Time `json:"deletion_time"`
 }
 
+type JITConfigPayload struct {
+	EncodedJITConfig string `json:"encoded_jit_config"`
+}
+
 func NewRunner(listener Listener, listenAddr, runnerDir, workDir, varDir string) (*Runner, error) {
 	envs, err := newRunnerEnvs()
 	if err != nil {
@@ -80,16 +105,21 @@ func NewRunner(listener Listener, listenAddr, runnerDir, workDir, varDir string)
 		envs:              envs,
 		listenAddr:        listenAddr,
 		listener:          listener,
+		jitConfigCh:       make(chan struct{}),
 		runnerDir:         runnerDir,
 		workDir:           workDir,
 		tokenPath:         filepath.Join(varDir, constants.SecretsDirName, constants.RunnerTokenFileName),
 		jobInfoFile:       filepath.Join(varDir, "github.env"),
 		slackChannelFile:  filepath.Join(varDir, "slack_channel"),
+		jobStartedFile:    filepath.Join(varDir, "job_started"),
+		conclusionFile:    filepath.Join(varDir, "job_conclusion"),
 		startedFlagFile:   filepath.Join(varDir, "started"),
 		extendFlagFile:    filepath.Join(varDir, "extend"),
 		failureFlagFile:   filepath.Join(varDir, "failure"),
 		cancelledFlagFile: filepath.Join(varDir, "cancelled"),
 		successFlagFile:   filepath.Join(varDir, "success"),
+		caBundleFile:      filepath.Join(varDir, "ca-certificates.crt"),
+		extraCAFile:       filepath.Join(varDir, "extra-ca.crt"),
 	}
 	return &r, nil
 }
@@ -105,6 +135,7 @@ func (r *Runner) Run(ctx context.Context) error {
 	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(registry, promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
 	mux.Handle("/"+constants.DeletionTimeEndpoint, http.HandlerFunc(r.deletionTimeHandler))
 	mux.Handle("/"+constants.StatusEndPoint, http.HandlerFunc(r.statusHandler))
+	mux.Handle("/"+constants.JITConfigEndpoint, http.HandlerFunc(r.jitConfigHandler))
 	serv := &well.HTTPServer{
 		Env: env,
 		Server: &http.Server{
@@ -135,12 +166,141 @@ func (r *Runner) runListener(ctx context.Context) error {
 
 	metrics.UpdateRunnerPodState(constants.RunnerPodStateInitializing)
 	r.updateState(constants.RunnerPodStateInitializing)
+	if r.envs.caBundle != "" {
+		if err := r.installCABundle(); err != nil {
+			return err
+		}
+	}
+	if err := configureJobHooks(logger); err != nil {
+		return err
+	}
 	if len(r.envs.setupCommand) != 0 {
-		if _, err := runCommand(ctx, r.runnerDir, r.envs.setupCommand[0], r.envs.setupCommand[1:]...); err != nil {
+		if failure := r.runSetupCommand(ctx); failure != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// Do not return the error. The container would be restarted and the pod would become stale.
+			// Keep reporting the failure until the runner manager deletes the pod.
+			metrics.UpdateRunnerPodState(constants.RunnerPodStateSetupFailed)
+			logger.Info("setup command failed; waiting for deletion", "attempts", failure.Attempts, "exit_code", failure.ExitCode)
+			r.mu.Lock()
+			r.state = constants.RunnerPodStateSetupFailed
+			r.setupFailure = failure
+			r.mu.Unlock()
+			<-ctx.Done()
+			return nil
+		}
+	}
+
+	var jitConfig string
+	if r.envs.jitConfig {
+		logger.Info("waiting for a just-in-time configuration")
+		r.mu.Lock()
+		r.waitingJITConfig = true
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.jitConfigCh:
+		}
+		r.mu.Lock()
+		r.waitingJITConfig = false
+		jitConfig = r.jitConfig
+		r.mu.Unlock()
+	} else if err := r.configure(ctx); err != nil {
+		return err
+	}
+
+	metrics.UpdateRunnerPodState(constants.RunnerPodStateRunning)
+	r.updateState(constants.RunnerPodStateRunning)
+	if err := r.listener.listen(ctx, jitConfig); err != nil {
+		return err
+	}
+
+	metrics.UpdateRunnerPodState(constants.RunnerPodStateDebugging)
+	r.updateToDebuggingState(logger)
+
+	<-ctx.Done()
+	return nil
+}
+
+// systemCABundlePaths are the candidates of the CA bundle of the system.
+var systemCABundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian and Ubuntu
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora and RHEL
+}
+
+// installCABundle makes the setup command, the listener and jobs trust the CA bundle given by the controller.
+// SSL_CERT_FILE replaces the CA bundle of the system, so the given one is appended to it.
+// NODE_EXTRA_CA_CERTS is for the actions written in JavaScript, which do not read SSL_CERT_FILE.
+func (r *Runner) installCABundle() error {
+	extra := []byte(r.envs.caBundle)
+	if err := os.WriteFile(r.extraCAFile, extra, 0644); err != nil {
+		return err
+	}
+
+	var bundle []byte
+	for _, p := range append([]string{os.Getenv("SSL_CERT_FILE")}, systemCABundlePaths...) {
+		if p == "" {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
 			return err
 		}
+		bundle = append(data, '\n')
+		break
+	}
+	bundle = append(bundle, extra...)
+	if err := os.WriteFile(r.caBundleFile, bundle, 0644); err != nil {
+		return err
 	}
 
+	// The commands do not run in the current directory.
+	caBundleFile, err := filepath.Abs(r.caBundleFile)
+	if err != nil {
+		return err
+	}
+	extraCAFile, err := filepath.Abs(r.extraCAFile)
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv("SSL_CERT_FILE", caBundleFile); err != nil {
+		return err
+	}
+	return os.Setenv("NODE_EXTRA_CA_CERTS", extraCAFile)
+}
+
+// configureJobHooks makes the runner record the job information without calling job-started in the workflows.
+// The hooks given by the users in the pod template are kept.
+func configureJobHooks(logger logr.Logger) error {
+	hooks := []struct {
+		env  string
+		path string
+	}{
+		{env: constants.JobStartedHookEnvName, path: constants.JobStartedHookPath},
+		{env: constants.JobCompletedHookEnvName, path: constants.JobCompletedHookPath},
+	}
+	for _, h := range hooks {
+		if v := os.Getenv(h.env); v != "" {
+			logger.Info("job hook is given; meows does not record the job information by the hook", "env", h.env, "hook", v)
+			continue
+		}
+		if !isFileExists(h.path) {
+			continue
+		}
+		if err := os.Setenv(h.env, h.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configure registers the runner with the registration token.
+func (r *Runner) configure(ctx context.Context) error {
 	b, err := os.ReadFile(r.tokenPath)
 	if err != nil {
 		return fmt.Errorf("failed load %s; %w", r.tokenPath, err)
@@ -157,28 +317,14 @@ func (r *Runner) runListener(ctx context.Context) error {
 		"--unattended",
 		"--replace",
 		"--name", r.envs.podName,
-		"--labels", r.envs.podNamespace + "/" + r.envs.runnerPoolName,
+		"--labels", r.envs.podNamespace + "/" + r.envs.runnerPoolName + "," + constants.ManagedRunnerLabel,
 		"--url", configURL,
 		"--token", string(b),
 		"--work", r.workDir,
 		"--ephemeral",
 		"--disableupdate",
 	}
-	if err := r.listener.configure(ctx, configArgs); err != nil {
-		return err
-	}
-
-	metrics.UpdateRunnerPodState(constants.RunnerPodStateRunning)
-	r.updateState(constants.RunnerPodStateRunning)
-	if err := r.listener.listen(ctx); err != nil {
-		return err
-	}
-
-	metrics.UpdateRunnerPodState(constants.RunnerPodStateDebugging)
-	r.updateToDebuggingState(logger)
-
-	<-ctx.Done()
-	return nil
+	return r.listener.configure(ctx, configArgs)
 }
 
 func (r *Runner) updateState(state string) {
@@ -197,7 +343,8 @@ func (r *Runner) updateToDebuggingState(logger logr.Logger) {
 	case isFileExists(r.successFlagFile):
 		result = JobResultSuccess
 	default:
-		result = JobResultUnknown
+		// The scripts are not called in the workflow. Take the conclusion reported by the listener.
+		result = r.readJobConclusion()
 	}
 	extend := isFileExists(r.extendFlagFile)
 
@@ -213,9 +360,17 @@ func (r *Runner) updateToDebuggingState(logger logr.Logger) {
 		logger.Error(err, "failed to read file for slack channel")
 	}
 
+	jobStartedAt, err := r.readJobStartedAt()
+	if err != nil {
+		logger.Error(err, "failed to read the time when the job started")
+	}
+
 	r.mu.Lock()
 	r.state = constants.RunnerPodStateDebugging
 	r.result = result
+	if r.jobStartedAt == nil {
+		r.jobStartedAt = jobStartedAt
+	}
 	r.finishedAt = &finishedAt
 	r.extend = &extend
 	r.jobInfo = jobInfo
@@ -237,6 +392,38 @@ func (r *Runner) readSlackChannel() (string, error) {
 	return strings.TrimRight(string(s), "\n"), nil
 }
 
+// readJobConclusion reads the conclusion of the job recorded from the output of the listener.
+// It returns JobResultUnknown if the conclusion is not recorded.
+func (r *Runner) readJobConclusion() string {
+	data, err := os.ReadFile(r.conclusionFile)
+	if err != nil {
+		return JobResultUnknown
+	}
+	switch result := strings.TrimSpace(string(data)); result {
+	case JobResultSuccess, JobResultFailure, JobResultCancelled:
+		return result
+	}
+	return JobResultUnknown
+}
+
+// readJobStartedAt reads the time written by the job-started command.
+// It returns nil if the job has not started yet.
+func (r *Runner) readJobStartedAt() (*time.Time, error) {
+	data, err := os.ReadFile(r.jobStartedFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func (r *Runner) deletionTimeHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPut {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -268,6 +455,19 @@ func (r *Runner) statusHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	r.mu.Lock()
+	needJobStartedAt := r.state == constants.RunnerPodStateRunning && r.jobStartedAt == nil
+	r.mu.Unlock()
+	if needJobStartedAt {
+		// The file may be being written. Ignore the error and read it again in the next request.
+		jobStartedAt, err := r.readJobStartedAt()
+		if err == nil {
+			r.mu.Lock()
+			r.jobStartedAt = jobStartedAt
+			r.mu.Unlock()
+		}
+	}
+
 	var st Status
 	r.mu.Lock()
 	st.State = r.state
@@ -277,6 +477,9 @@ func (r *Runner) statusHandler(w http.ResponseWriter, req *http.Request) {
 	st.Extend = r.extend
 	st.JobInfo = r.jobInfo
 	st.SlackChannel = r.slackChannel
+	st.JobStartedAt = r.jobStartedAt
+	st.WaitingJITConfig = r.waitingJITConfig
+	st.SetupFailure = r.setupFailure
 	r.mu.Unlock()
 
 	res, err := json.Marshal(st)
@@ -289,3 +492,41 @@ func (r *Runner) statusHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(res)
 }
+
+func (r *Runner) jitConfigHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload JITConfigPayload
+	if req.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	err := json.NewDecoder(req.Body).Decode(&payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.EncodedJITConfig == "" {
+		http.Error(w, "encoded_jit_config is empty", http.StatusBadRequest)
+		return
+	}
+	if !r.envs.jitConfig {
+		http.Error(w, "just-in-time configuration is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	// The configuration is accepted only once, because it cannot be used for another runner.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jitConfig != "" {
+		http.Error(w, "just-in-time configuration is already given", http.StatusConflict)
+		return
+	}
+	r.jitConfig = payload.EncodedJITConfig
+	close(r.jitConfigCh)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -40,13 +41,16 @@ var _ = Describe("Runner", func() {
 		By("checking initializing state")
 		flagFileShouldExist("started")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("initializing"),
-			"Result":       BeEmpty(),
-			"FinishedAt":   BeNil(),
-			"DeletionTime": BeNil(),
-			"Extend":       BeNil(),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("initializing"),
+			"Result":           BeEmpty(),
+			"FinishedAt":       BeNil(),
+			"DeletionTime":     BeNil(),
+			"Extend":           BeNil(),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -63,6 +67,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -77,13 +85,16 @@ var _ = Describe("Runner", func() {
 
 		flagFileShouldExist("started")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("running"),
-			"Result":       BeEmpty(),
-			"FinishedAt":   BeNil(),
-			"DeletionTime": BeNil(),
-			"Extend":       BeNil(),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("running"),
+			"Result":           BeEmpty(),
+			"FinishedAt":       BeNil(),
+			"DeletionTime":     BeNil(),
+			"Extend":           BeNil(),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -100,6 +111,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 1.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -128,7 +143,10 @@ var _ = Describe("Runner", func() {
 				"Repository": Equal("meows"),
 				"GitRef":     Equal("branch"),
 			})),
-			"SlackChannel": BeEmpty(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -145,6 +163,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -166,13 +188,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("unknown"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeTrue()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("unknown"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeTrue()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -189,6 +214,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -210,13 +239,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("failure"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeTrue()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("failure"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeTrue()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -233,6 +265,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -246,13 +282,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("failure"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": PointTo(BeTemporally("~", extendTo, 500*time.Millisecond)),
-			"Extend":       PointTo(BeTrue()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("failure"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     PointTo(BeTemporally("~", extendTo, 500*time.Millisecond)),
+			"Extend":           PointTo(BeTrue()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -269,6 +308,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -287,13 +330,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("stale"),
-			"Result":       BeEmpty(),
-			"FinishedAt":   BeNil(),
-			"DeletionTime": BeNil(),
-			"Extend":       BeNil(),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("stale"),
+			"Result":           BeEmpty(),
+			"FinishedAt":       BeNil(),
+			"DeletionTime":     BeNil(),
+			"Extend":           BeNil(),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -310,6 +356,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 1.0),
 				})),
@@ -337,13 +387,16 @@ var _ = Describe("Runner", func() {
 
 		flagFileShouldExist("started")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("initializing"),
-			"Result":       BeEmpty(),
-			"FinishedAt":   BeNil(),
-			"DeletionTime": BeNil(),
-			"Extend":       BeNil(),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("initializing"),
+			"Result":           BeEmpty(),
+			"FinishedAt":       BeNil(),
+			"DeletionTime":     BeNil(),
+			"Extend":           BeNil(),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 		metricsShouldHaveValue("meows_runner_pod_state",
 			MatchAllElementsWithIndex(IndexIdentity, Elements{
@@ -360,6 +413,10 @@ var _ = Describe("Runner", func() {
 					"Value": BeNumerically("==", 0.0),
 				})),
 				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"4": PointTo(MatchAllFields(Fields{
 					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("stale")}),
 					"Value": BeNumerically("==", 0.0),
 				})),
@@ -368,6 +425,118 @@ var _ = Describe("Runner", func() {
 		metricsShouldNotExist("meows_runner_listener_exit_state")
 	})
 
+	It("should run setup command that leaves a background process", func() {
+		By("starting runner with setup command starting a daemon")
+		resetEnv(false)
+		opt, err := json.Marshal(&Option{
+			SetupCommand: []string{"sh", "-c", "sleep 5 &"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		os.Setenv(constants.RunnerOptionEnvName, string(opt))
+
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+
+		By("checking outputs")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":        Equal("initializing"),
+			"SetupFailure": BeNil(),
+		})))
+	})
+
+	It("should install the CA bundle before running setup command", func() {
+		By("starting runner with CA bundle")
+		resetEnv(false)
+		opt, err := json.Marshal(&Option{
+			SetupCommand: []string{"bash", "-c", "cat $NODE_EXTRA_CA_CERTS > ./extra; tail -n 1 $SSL_CERT_FILE > ./bundle"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		os.Setenv(constants.RunnerOptionEnvName, string(opt))
+		os.Setenv(constants.CABundleEnvName, "dummy-ca")
+
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+
+		By("checking outputs")
+		extra, err := os.ReadFile(filepath.Join(testRunnerDir, "extra"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(extra)).To(Equal("dummy-ca"))
+		bundle, err := os.ReadFile(filepath.Join(testRunnerDir, "bundle"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(bundle)).To(Equal("dummy-ca"))
+	})
+
+	It("should report the failure of setup command", func() {
+		By("starting runner with failing setup command")
+		resetEnv(false)
+		setupRetryInterval = 100 * time.Millisecond
+		opt, err := json.Marshal(&Option{
+			SetupCommand: []string{"bash", "-c", "echo attempt >> ./attempts; echo error; exit 3"},
+			SetupRetries: 2,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		os.Setenv(constants.RunnerOptionEnvName, string(opt))
+
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+
+		By("checking outputs")
+		attempts, err := os.ReadFile(filepath.Join(testRunnerDir, "attempts"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(attempts)).To(Equal("attempt\nattempt\nattempt\n"))
+
+		flagFileShouldExist("started")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State": Equal("setup_failed"),
+			"SetupFailure": PointTo(MatchAllFields(Fields{
+				"ExitCode": Equal(3),
+				"Output":   Equal("error\n"),
+				"Attempts": Equal(3),
+				"FailedAt": BeTemporally("~", time.Now(), 3*time.Second),
+			})),
+		})))
+		metricsShouldHaveValue("meows_runner_pod_state",
+			MatchElementsWithIndex(IndexIdentity, IgnoreExtras, Elements{
+				"1": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("initializing")}),
+					"Value": BeNumerically("==", 0.0),
+				})),
+				"3": PointTo(MatchAllFields(Fields{
+					"Label": MatchAllKeys(Keys{"runnerpool": Equal("fake-pod-ns/fake-runnerpool"), "state": Equal("setup_failed")}),
+					"Value": BeNumerically("==", 1.0),
+				})),
+			}),
+		)
+	})
+
+	It("should kill setup command that exceeds the timeout", func() {
+		By("starting runner with slow setup command")
+		resetEnv(false)
+		opt, err := json.Marshal(&Option{
+			SetupCommand: []string{"bash", "-c", "echo sleeping; sleep 10"},
+			SetupTimeout: "500ms",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		os.Setenv(constants.RunnerOptionEnvName, string(opt))
+
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+
+		By("checking outputs")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State": Equal("setup_failed"),
+			"SetupFailure": PointTo(MatchFields(IgnoreExtras, Fields{
+				"ExitCode": Equal(-1),
+				"Output":   Equal("sleeping\n"),
+				"Attempts": Equal(1),
+			})),
+		})))
+	})
+
 	It("should become success status when success file is created", func() {
 		By("starting runner with creating success file")
 		resetEnv(false)
@@ -381,13 +550,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 	})
 
@@ -404,13 +576,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("failure"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"State":            Equal("debugging"),
+			"Result":           Equal("failure"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 	})
 
@@ -427,13 +602,86 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
+			"State":            Equal("debugging"),
+			"Result":           Equal("cancelled"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     BeEmpty(),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
+		})))
+	})
+
+	It("should take the conclusion reported by the listener when no result file is created", func() {
+		By("starting runner")
+		resetEnv(false)
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+		listener.configureCh <- nil
+		Expect(os.WriteFile(filepath.Join(testVarDir, "job_conclusion"), []byte("failure"), 0664)).To(Succeed())
+		listener.listenCh <- nil
+		time.Sleep(time.Second)
+
+		By("checking outputs")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":  Equal("debugging"),
+			"Result": Equal("failure"),
+			"Extend": PointTo(BeFalse()),
+		})))
+	})
+
+	It("should prefer the result file to the conclusion reported by the listener", func() {
+		By("starting runner with creating success file")
+		resetEnv(false)
+		listener := newListenerMock("success")
+		cancel := startRunner(listener)
+		defer cancel()
+		listener.configureCh <- nil
+		Expect(os.WriteFile(filepath.Join(testVarDir, "job_conclusion"), []byte("cancelled"), 0664)).To(Succeed())
+		listener.listenCh <- nil
+		time.Sleep(time.Second)
+
+		By("checking outputs")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":  Equal("debugging"),
+			"Result": Equal("success"),
+		})))
+	})
+
+	It("should report the time when the job started", func() {
+		By("starting runner")
+		resetEnv(false)
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+		listener.configureCh <- nil
+		time.Sleep(time.Second)
+
+		By("checking the job has not started")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":        Equal("running"),
+			"JobStartedAt": BeNil(),
+		})))
+
+		By("creating job_started file")
+		jobStartedAt := time.Now().UTC().Truncate(time.Second)
+		err := os.WriteFile(filepath.Join(testVarDir, "job_started"), []byte(jobStartedAt.Format(time.RFC3339)), 0664)
+		Expect(err).NotTo(HaveOccurred())
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":        Equal("running"),
+			"JobStartedAt": PointTo(BeTemporally("==", jobStartedAt)),
+		})))
+
+		By("checking the time is kept after the job finished")
+		listener.listenCh <- nil
+		time.Sleep(time.Second)
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
 			"State":        Equal("debugging"),
-			"Result":       Equal("cancelled"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": BeEmpty(),
+			"JobStartedAt": PointTo(BeTemporally("==", jobStartedAt)),
 		})))
 	})
 
@@ -456,13 +704,16 @@ var _ = Describe("Runner", func() {
 
 		By("checking outputs")
 		statusShouldHaveValue(PointTo(MatchAllFields(Fields{
-			"State":        Equal("debugging"),
-			"Result":       Equal("success"),
-			"FinishedAt":   PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
-			"DeletionTime": BeNil(),
-			"Extend":       PointTo(BeFalse()),
-			"JobInfo":      BeNil(),
-			"SlackChannel": Equal("#test1"),
+			"State":            Equal("debugging"),
+			"Result":           Equal("success"),
+			"FinishedAt":       PointTo(BeTemporally("~", finishedAt, 500*time.Millisecond)),
+			"DeletionTime":     BeNil(),
+			"Extend":           PointTo(BeFalse()),
+			"JobInfo":          BeNil(),
+			"SlackChannel":     Equal("#test1"),
+			"JobStartedAt":     BeNil(),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
 		})))
 
 		By("remove slack_channel file")
@@ -471,6 +722,70 @@ var _ = Describe("Runner", func() {
 	})
 })
 
+var _ = Describe("Runner with just-in-time configuration", func() {
+	AfterEach(func() {
+		time.Sleep(time.Second)
+	})
+
+	It("should run listener with the given configuration", func() {
+		By("starting runner")
+		resetEnv(false)
+		os.Setenv(constants.RunnerOptionEnvName, `{"jit_config": true}`)
+		listener := newListenerMock("success")
+		cancel := startRunner(listener)
+		defer cancel()
+
+		By("checking the runner waits for the configuration")
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":            Equal("initializing"),
+			"WaitingJITConfig": BeTrue(),
+			"SetupFailure":     BeNil(),
+		})))
+
+		By("giving the configuration")
+		runnerClient := NewClient()
+		Expect(runnerClient.PutJITConfig(context.Background(), "localhost", "fake-jitconfig")).To(Succeed())
+		time.Sleep(time.Second)
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":            Equal("running"),
+			"WaitingJITConfig": BeFalse(),
+			"SetupFailure":     BeNil(),
+		})))
+		listener.mu.Lock()
+		Expect(listener.jitConfig).To(Equal("fake-jitconfig"))
+		listener.mu.Unlock()
+
+		By("giving the configuration twice")
+		Expect(runnerClient.PutJITConfig(context.Background(), "localhost", "fake-jitconfig2")).NotTo(Succeed())
+
+		By("finishing the job")
+		listener.listenCh <- nil
+		time.Sleep(time.Second)
+		statusShouldHaveValue(PointTo(MatchFields(IgnoreExtras, Fields{
+			"State":  Equal("debugging"),
+			"Result": Equal("success"),
+		})))
+	})
+
+	It("should reject the configuration when it is not enabled", func() {
+		By("starting runner")
+		resetEnv(false)
+		listener := newListenerMock()
+		cancel := startRunner(listener)
+		defer cancel()
+
+		runnerClient := NewClient()
+		Expect(runnerClient.PutJITConfig(context.Background(), "localhost", "fake-jitconfig")).NotTo(Succeed())
+
+		listener.configureCh <- nil
+		listener.listenCh <- nil
+		time.Sleep(time.Second)
+		listener.mu.Lock()
+		Expect(listener.jitConfig).To(BeEmpty())
+		listener.mu.Unlock()
+	})
+})
+
 func TestRunner(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -486,6 +801,9 @@ type listenerMock struct {
 	flagFiles   []string
 	configureCh chan error
 	listenCh    chan error
+
+	mu        sync.Mutex
+	jitConfig string
 }
 
 func newListenerMock(flagFiles ...string) *listenerMock {
@@ -501,7 +819,10 @@ func (l *listenerMock) configure(ctx context.Context, configArgs []string) error
 	return <-l.configureCh
 }
 
-func (l *listenerMock) listen(ctx context.Context) error {
+func (l *listenerMock) listen(ctx context.Context, jitConfig string) error {
+	l.mu.Lock()
+	l.jitConfig = jitConfig
+	l.mu.Unlock()
 	ret := <-l.listenCh
 	for _, file := range l.flagFiles {
 		createFlagFile(file)
@@ -522,6 +843,11 @@ func resetEnv(orgRunner bool) {
 	os.Setenv(constants.PodNamespaceEnvName, "fake-pod-ns")
 	os.Setenv(constants.RunnerPoolNameEnvName, "fake-runnerpool")
 	os.Setenv(constants.RunnerOptionEnvName, "{}")
+	os.Unsetenv(constants.CABundleEnvName)
+	os.Unsetenv("SSL_CERT_FILE")
+	os.Unsetenv("NODE_EXTRA_CA_CERTS")
+	os.Unsetenv(constants.JobStartedHookEnvName)
+	os.Unsetenv(constants.JobCompletedHookEnvName)
 	if orgRunner {
 		os.Setenv(constants.RunnerOrgEnvName, "fake-org")
 		os.Unsetenv(constants.RunnerRepoEnvName)
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// setupOutputTailSize is the maximum size of the output of the setup command reported in the status.
+const setupOutputTailSize = 4096
+
+// setupRetryInterval is the interval between the attempts of the setup command.
+var setupRetryInterval = 10 * time.Second
+
+// SetupFailure is the result of the setup command that failed in all attempts.
+type SetupFailure struct {
+	// ExitCode is the exit code of the last attempt. It is -1 if the command was killed by the timeout.
+	ExitCode int `json:"exit_code"`
+	// Output is the tail of the stdout and stderr of the last attempt.
+	// It is served on the status API as is, so the setup command should not print secrets.
+	Output string `json:"output,omitempty"`
+	// Attempts is the number of times the setup command ran.
+	Attempts int `json:"attempts"`
+	// FailedAt is the time when the last attempt finished.
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// runSetupCommand runs the setup command until it succeeds or the retries are exhausted.
+// It returns nil if the command succeeds.
+func (r *Runner) runSetupCommand(ctx context.Context) *SetupFailure {
+	logger := log.FromContext(ctx)
+	command := r.envs.setupCommand
+
+	var failure *SetupFailure
+	for attempt := 1; attempt <= r.envs.setupRetries+1; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return failure
+			case <-time.After(setupRetryInterval):
+			}
+		}
+
+		cmdCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.envs.setupTimeout != 0 {
+			cmdCtx, cancel = context.WithTimeout(ctx, r.envs.setupTimeout)
+		}
+		output := newTailBuffer(setupOutputTailSize)
+		exitCode, err := runCommandWithOutput(cmdCtx, output, r.runnerDir, command[0], command[1:]...)
+		timedOut := errors.Is(cmdCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		logger.Error(err, "setup command failed", "attempt", attempt, "exit_code", exitCode, "timed_out", timedOut)
+		failure = &SetupFailure{
+			ExitCode: exitCode,
+			Output:   output.String(),
+			Attempts: attempt,
+			FailedAt: time.Now().UTC(),
+		}
+	}
+	return failure
+}
+
+// tailBuffer is an io.Writer that keeps only the last bytes written to it.
+// It is safe to write stdout and stderr to it concurrently.
+type tailBuffer struct {
+	size int
+	mu   sync.Mutex
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// The head may be cut in the middle of a multi-byte character.
+	return strings.ToValidUTF8(string(b.buf), "")
+}
@@ -0,0 +1,5 @@
+#!/bin/sh
+
+# This script is run by the runner at the end of a job via ACTIONS_RUNNER_HOOK_JOB_COMPLETED.
+# It records the job information if it has not been recorded at the beginning of the job.
+exec job-started --hook --completed
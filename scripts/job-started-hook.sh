@@ -0,0 +1,5 @@
+#!/bin/sh
+
+# This script is run by the runner at the beginning of a job via ACTIONS_RUNNER_HOOK_JOB_STARTED.
+# It must not fail the job, so job-started only reports errors in the hook mode.
+exec job-started --hook